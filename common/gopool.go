@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"github.com/bytedance/gopkg/util/gopool"
 	"math"
+	"sync/atomic"
 )
 
 var relayGoPool gopool.Pool
 
+// relayGoPoolPending 已提交但还未开始执行的任务数
+var relayGoPoolPending atomic.Int64
+
 func init() {
 	relayGoPool = gopool.NewPool("gopool.RelayPool", math.MaxInt32, gopool.NewConfig())
 	relayGoPool.SetPanicHandler(func(ctx context.Context, i interface{}) {
@@ -20,5 +24,17 @@ func init() {
 }
 
 func RelayCtxGo(ctx context.Context, f func()) {
-	relayGoPool.CtxGo(ctx, f)
+	relayGoPoolPending.Add(1)
+	relayGoPool.CtxGo(ctx, func() {
+		relayGoPoolPending.Add(-1)
+		f()
+	})
+}
+
+// RelayGoPoolStats 返回 relay 协程池排队中的任务数与工作协程数
+func RelayGoPoolStats() (pending int64, workers int32) {
+	if p, ok := relayGoPool.(interface{ WorkerCount() int32 }); ok {
+		workers = p.WorkerCount()
+	}
+	return relayGoPoolPending.Load(), workers
 }
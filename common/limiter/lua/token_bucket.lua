@@ -0,0 +1,37 @@
+-- 支持小数速率和预扣对账的令牌桶，返回 {是否允许, 剩余令牌数}
+-- KEYS[1]: 限流器唯一标识
+-- ARGV[1]: 请求令牌数，为负数时表示退还
+-- ARGV[2]: 令牌生成速率 (每秒，可以是小数)
+-- ARGV[3]: 桶容量
+-- ARGV[4]: 为 1 时无论余量是否充足都扣除，用于按实际用量对账
+
+local key = KEYS[1]
+local requested = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local capacity = tonumber(ARGV[3])
+local force = tonumber(ARGV[4])
+
+local now = redis.call('TIME')
+local nowInSeconds = tonumber(now[1]) + tonumber(now[2]) / 1000000
+
+local bucket = redis.call('HMGET', key, 'tokens', 'last_time')
+local tokens = tonumber(bucket[1])
+local last_time = tonumber(bucket[2])
+
+if not tokens or not last_time then
+    tokens = capacity
+else
+    local elapsed = math.max(0, nowInSeconds - last_time)
+    tokens = math.min(capacity, tokens + elapsed * rate)
+end
+
+local allowed = 0
+if force == 1 or tokens >= requested then
+    tokens = math.min(capacity, tokens - requested)
+    allowed = 1
+end
+
+redis.call('HMSET', key, 'tokens', tostring(tokens), 'last_time', tostring(nowInSeconds))
+redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60)
+
+return {allowed, math.floor(tokens)}
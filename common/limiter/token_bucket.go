@@ -0,0 +1,163 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+//go:embed lua/token_bucket.lua
+var tokenBucketScript string
+
+var (
+	tokenBucketSHA  string
+	tokenBucketOnce sync.Once
+)
+
+// 令牌桶与并发计数的入口，启用 Redis 时在多个节点间共享，否则只在当前节点内存中生效
+
+// TakeTokens 从桶中取出 requested 个令牌，返回是否允许以及剩余令牌数。
+// requested 为负数时表示退还；force 为 true 时即使余量不足也扣除，用于按实际用量对账
+func TakeTokens(ctx context.Context, key string, requested int64, capacity int64, ratePerSecond float64, force bool) (bool, int64, error) {
+	if capacity <= 0 || ratePerSecond <= 0 {
+		return true, 0, nil
+	}
+	if !common.RedisEnabled {
+		allowed, remaining := memoryTakeTokens(key, requested, capacity, ratePerSecond, force)
+		return allowed, remaining, nil
+	}
+	rdb := common.RDB
+	tokenBucketOnce.Do(func() {
+		sha, err := rdb.ScriptLoad(ctx, tokenBucketScript).Result()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("Failed to load token bucket script: %v", err))
+		}
+		tokenBucketSHA = sha
+	})
+	forceArg := 0
+	if force {
+		forceArg = 1
+	}
+	result, err := rdb.EvalSha(ctx, tokenBucketSHA, []string{key}, requested, ratePerSecond, capacity, forceArg).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("token bucket failed: %w", err)
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("token bucket returned %d values", len(result))
+	}
+	return result[0] == 1, result[1], nil
+}
+
+type memoryBucket struct {
+	tokens   float64
+	lastTime time.Time
+	capacity int64
+	rate     float64
+}
+
+var (
+	memoryBuckets     = make(map[string]*memoryBucket)
+	memoryBucketsLock sync.Mutex
+	memoryCleanOnce   sync.Once
+)
+
+func memoryTakeTokens(key string, requested int64, capacity int64, ratePerSecond float64, force bool) (bool, int64) {
+	memoryCleanOnce.Do(func() {
+		go cleanMemoryBuckets()
+	})
+	now := time.Now()
+	memoryBucketsLock.Lock()
+	defer memoryBucketsLock.Unlock()
+	b, ok := memoryBuckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(capacity), lastTime: now}
+		memoryBuckets[key] = b
+	} else {
+		b.tokens = math.Min(float64(capacity), b.tokens+now.Sub(b.lastTime).Seconds()*ratePerSecond)
+		b.lastTime = now
+	}
+	b.capacity = capacity
+	b.rate = ratePerSecond
+	allowed := false
+	if force || b.tokens >= float64(requested) {
+		b.tokens = math.Min(float64(capacity), b.tokens-float64(requested))
+		allowed = true
+	}
+	return allowed, int64(math.Floor(b.tokens))
+}
+
+// cleanMemoryBuckets 定期删除已经回满的桶，回满后与新建的桶没有区别
+func cleanMemoryBuckets() {
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now()
+		memoryBucketsLock.Lock()
+		for key, b := range memoryBuckets {
+			if b.tokens+now.Sub(b.lastTime).Seconds()*b.rate >= float64(b.capacity) {
+				delete(memoryBuckets, key)
+			}
+		}
+		memoryBucketsLock.Unlock()
+	}
+}
+
+// concurrencyKeyTTL 进程异常退出时未释放的并发计数在该时间后自动清除
+const concurrencyKeyTTL = 10 * time.Minute
+
+var (
+	memoryConcurrency     = make(map[string]int)
+	memoryConcurrencyLock sync.Mutex
+)
+
+// AcquireConcurrency 占用一个并发名额，limit 不大于 0 时不限制
+func AcquireConcurrency(ctx context.Context, key string, limit int) (bool, error) {
+	if limit <= 0 {
+		return true, nil
+	}
+	if !common.RedisEnabled {
+		memoryConcurrencyLock.Lock()
+		defer memoryConcurrencyLock.Unlock()
+		if memoryConcurrency[key] >= limit {
+			return false, nil
+		}
+		memoryConcurrency[key]++
+		return true, nil
+	}
+	rdb := common.RDB
+	count, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	rdb.Expire(ctx, key, concurrencyKeyTTL)
+	if count > int64(limit) {
+		rdb.Decr(ctx, key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func ReleaseConcurrency(ctx context.Context, key string) {
+	if !common.RedisEnabled {
+		memoryConcurrencyLock.Lock()
+		defer memoryConcurrencyLock.Unlock()
+		if memoryConcurrency[key] <= 1 {
+			delete(memoryConcurrency, key)
+		} else {
+			memoryConcurrency[key]--
+		}
+		return
+	}
+	count, err := common.RDB.Decr(ctx, key).Result()
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to release concurrency %s: %v", key, err))
+		return
+	}
+	// 计数已过期被清除时会减为负数
+	if count < 0 {
+		common.RDB.Del(ctx, key)
+	}
+}
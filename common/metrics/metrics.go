@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"net/http"
+	"one-api/common"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "new_api"
+
+// relayLabels 中继相关指标共用的标签，channel_id 为 0 表示请求没有经过任何渠道（例如命中响应缓存）
+var relayLabels = []string{"channel_id", "model", "group", "relay_format"}
+
+var registry = prometheus.NewRegistry()
+
+var (
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Relay requests by final channel and response status code.",
+	}, append(relayLabels, "status_code"))
+
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "Total relay request latency including retries.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, relayLabels)
+
+	relayFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_time_to_first_token_seconds",
+		Help:      "Time to first token of streaming relay requests.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60},
+	}, relayLabels)
+
+	upstreamResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_responses_total",
+		Help:      "Upstream attempts by channel and resulting status code.",
+	}, append(relayLabels, "status_code"))
+
+	relayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Retries triggered by a failed attempt on the labelled channel.",
+	}, relayLabels)
+
+	channelAutoDisabled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_auto_disabled_total",
+		Help:      "Channels or channel keys disabled automatically.",
+	}, []string{"channel_id"})
+
+	quotaConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_consumed_total",
+		Help:      "Quota consumed by billed requests.",
+	}, relayLabels)
+
+	tokensConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_consumed_total",
+		Help:      "Prompt and completion tokens of billed requests.",
+	}, append(relayLabels, "type"))
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		relayRequests,
+		relayDuration,
+		relayFirstToken,
+		upstreamResponses,
+		relayRetries,
+		channelAutoDisabled,
+		quotaConsumed,
+		tokensConsumed,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "relay_gopool_pending_tasks",
+			Help:      "Tasks queued in the relay goroutine pool but not yet started.",
+		}, func() float64 {
+			pending, _ := common.RelayGoPoolStats()
+			return float64(pending)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "relay_gopool_workers",
+			Help:      "Running workers of the relay goroutine pool.",
+		}, func() float64 {
+			_, workers := common.RelayGoPoolStats()
+			return float64(workers)
+		}),
+	)
+}
+
+// Handler 返回 /metrics 的处理函数
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterGaugeFunc 供其他包注册在抓取时才计算的指标
+func RegisterGaugeFunc(name string, help string, f func() float64) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, f))
+}
+
+func RecordRelayRequest(channelId int, model, group, relayFormat string, statusCode int, duration time.Duration, firstToken time.Duration) {
+	channel := strconv.Itoa(channelId)
+	relayRequests.WithLabelValues(channel, model, group, relayFormat, strconv.Itoa(statusCode)).Inc()
+	relayDuration.WithLabelValues(channel, model, group, relayFormat).Observe(duration.Seconds())
+	if firstToken > 0 {
+		relayFirstToken.WithLabelValues(channel, model, group, relayFormat).Observe(firstToken.Seconds())
+	}
+}
+
+func RecordUpstreamResponse(channelId int, model, group, relayFormat string, statusCode int) {
+	upstreamResponses.WithLabelValues(strconv.Itoa(channelId), model, group, relayFormat, strconv.Itoa(statusCode)).Inc()
+}
+
+func RecordRetry(channelId int, model, group, relayFormat string) {
+	relayRetries.WithLabelValues(strconv.Itoa(channelId), model, group, relayFormat).Inc()
+}
+
+func RecordChannelAutoDisabled(channelId int) {
+	channelAutoDisabled.WithLabelValues(strconv.Itoa(channelId)).Inc()
+}
+
+func RecordQuotaConsumed(channelId int, model, group, relayFormat string, quota int, promptTokens int, completionTokens int) {
+	channel := strconv.Itoa(channelId)
+	quotaConsumed.WithLabelValues(channel, model, group, relayFormat).Add(float64(quota))
+	tokensConsumed.WithLabelValues(channel, model, group, relayFormat, "prompt").Add(float64(promptTokens))
+	tokensConsumed.WithLabelValues(channel, model, group, relayFormat, "completion").Add(float64(completionTokens))
+}
@@ -17,6 +17,10 @@ const (
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenRpmLimit          ContextKey = "token_rpm_limit"
+	ContextKeyTokenTpmLimit          ContextKey = "token_tpm_limit"
+	ContextKeyTokenMaxStreams        ContextKey = "token_max_concurrent_streams"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -47,4 +51,22 @@ const (
 	ContextKeyUserName    ContextKey = "username"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
+	ContextKeyRelayFormat          ContextKey = "relay_format"
+
+	/* batch related keys */
+	ContextKeyBatchId            ContextKey = "batch_id"
+	ContextKeyBatchDiscountRatio ContextKey = "batch_discount_ratio"
+
+	ContextKeyResponseCacheHit    ContextKey = "response_cache_hit"
+	ContextKeyTokenRateLimitLease ContextKey = "token_rate_limit_lease"
+	ContextKeyBodyCapture         ContextKey = "body_capture"
+	// 管理员回放请求，不计费、不重试且不影响渠道状态
+	ContextKeyReplay ContextKey = "replay"
+
+	ContextKeyTokenModerationPolicy ContextKey = "token_moderation_policy"
+	ContextKeyModerationVerdicts    ContextKey = "moderation_verdicts"
+
+	ContextKeyTokenPIIScrub ContextKey = "token_pii_scrub"
+	ContextKeyTokenOrgId    ContextKey = "token_org_id"
+	ContextKeyPIIScrubber   ContextKey = "pii_scrubber"
 )
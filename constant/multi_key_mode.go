@@ -3,6 +3,9 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom            MultiKeyMode = "random"              // 随机
+	MultiKeyModePolling           MultiKeyMode = "polling"             // 轮询
+	MultiKeyModeWeighted          MultiKeyMode = "weighted"            // 按权重随机
+	MultiKeyModeLeastRecentlyUsed MultiKeyMode = "least_recently_used" // 最久未使用的key优先
+	MultiKeyModeLeastTokens       MultiKeyMode = "least_tokens"        // 当前分钟 token 用量（按权重折算）最少的key优先
 )
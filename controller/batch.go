@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// batchEndpointFormats 批处理支持的端点及其对应的 relay 格式
+var batchEndpointFormats = map[string]types.RelayFormat{
+	"/v1/chat/completions": types.RelayFormatOpenAI,
+	"/v1/completions":      types.RelayFormatOpenAI,
+	"/v1/moderations":      types.RelayFormatOpenAI,
+	"/v1/embeddings":       types.RelayFormatEmbedding,
+	"/v1/responses":        types.RelayFormatOpenAIResponses,
+}
+
+const batchCompletionWindow = "24h"
+
+func optionalTimestamp(ts int64) *int64 {
+	if ts == 0 {
+		return nil
+	}
+	return &ts
+}
+
+func batchToOpenAIBatch(batch *model.Batch) dto.OpenAIBatch {
+	openaiBatch := dto.OpenAIBatch{
+		Id:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optionalTimestamp(batch.InProgressAt),
+		ExpiresAt:        optionalTimestamp(batch.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(batch.FinalizingAt),
+		CompletedAt:      optionalTimestamp(batch.CompletedAt),
+		FailedAt:         optionalTimestamp(batch.FailedAt),
+		ExpiredAt:        optionalTimestamp(batch.ExpiredAt),
+		CancellingAt:     optionalTimestamp(batch.CancellingAt),
+		CancelledAt:      optionalTimestamp(batch.CancelledAt),
+		RequestCounts: dto.BatchRequestCounts{
+			Total:     batch.RequestTotal,
+			Completed: batch.RequestCompleted,
+			Failed:    batch.RequestFailed,
+		},
+	}
+	if batch.OutputFileId != "" {
+		openaiBatch.OutputFileId = &batch.OutputFileId
+	}
+	if batch.ErrorFileId != "" {
+		openaiBatch.ErrorFileId = &batch.ErrorFileId
+	}
+	if batch.Errors != "" {
+		var batchErrors []dto.BatchError
+		if err := common.Unmarshal([]byte(batch.Errors), &batchErrors); err == nil {
+			openaiBatch.Errors = &dto.BatchErrors{Object: "list", Data: batchErrors}
+		}
+	}
+	if batch.Metadata != "" {
+		_ = common.Unmarshal([]byte(batch.Metadata), &openaiBatch.Metadata)
+	}
+	return openaiBatch
+}
+
+func getUserBatch(c *gin.Context) (*model.Batch, bool) {
+	batch, err := model.GetUserBatchByBatchId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondOpenAIError(c, http.StatusNotFound, "batch_not_found", fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		} else {
+			respondOpenAIError(c, http.StatusInternalServerError, "query_data_error", err.Error())
+		}
+		return nil, false
+	}
+	return batch, true
+}
+
+func CreateBatch(c *gin.Context) {
+	if !operation_setting.GetBatchSetting().Enabled {
+		respondOpenAIError(c, http.StatusForbidden, "batch_disabled", "batch api is disabled")
+		return
+	}
+	var req dto.BatchRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if _, ok := batchEndpointFormats[req.Endpoint]; !ok {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", fmt.Sprintf("unsupported endpoint: %s", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = batchCompletionWindow
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", "completion_window must be 24h")
+		return
+	}
+	inputFile, err := model.GetUserFileByFileId(c.GetInt("id"), req.InputFileId)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", fmt.Sprintf("No such File object: %s", req.InputFileId))
+		return
+	}
+	if inputFile.Purpose != "batch" {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", "input file purpose must be batch")
+		return
+	}
+
+	batch := &model.Batch{
+		BatchId:          model.GenerateBatchId(),
+		UserId:           c.GetInt("id"),
+		TokenId:          c.GetInt("token_id"),
+		Group:            common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		Endpoint:         req.Endpoint,
+		CompletionWindow: req.CompletionWindow,
+		InputFileId:      req.InputFileId,
+		Status:           model.BatchStatusValidating,
+		ExpiresAt:        common.GetTimestamp() + 24*60*60,
+	}
+	if len(req.Metadata) > 0 {
+		metadata, _ := common.Marshal(req.Metadata)
+		batch.Metadata = string(metadata)
+	}
+	if err = batch.Insert(); err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "insert_data_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, batchToOpenAIBatch(batch))
+}
+
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	batches, err := model.GetUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	resp := dto.OpenAIBatchList{
+		Object: "list",
+		Data:   make([]dto.OpenAIBatch, 0, len(batches)),
+	}
+	if len(batches) > limit {
+		resp.HasMore = true
+		batches = batches[:limit]
+	}
+	for _, batch := range batches {
+		resp.Data = append(resp.Data, batchToOpenAIBatch(batch))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batch, ok := getUserBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, batchToOpenAIBatch(batch))
+}
+
+func CancelBatch(c *gin.Context) {
+	batch, ok := getUserBatch(c)
+	if !ok {
+		return
+	}
+	if err := model.CancelBatch(batch); err != nil {
+		respondOpenAIError(c, http.StatusConflict, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, batchToOpenAIBatch(batch))
+}
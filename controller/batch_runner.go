@@ -0,0 +1,431 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const batchProgressInterval = 5 * time.Second
+
+// RunBatchJobs 在主节点上调度排队中的批处理任务
+func RunBatchJobs() {
+	interrupted, err := model.GetInterruptedBatches()
+	if err != nil {
+		common.SysLog(fmt.Sprintf("get interrupted batches failed: %s", err.Error()))
+	}
+	for _, batch := range interrupted {
+		failBatch(batch, []dto.BatchError{{Code: "batch_interrupted", Message: "batch execution was interrupted by a server restart"}})
+	}
+	for {
+		time.Sleep(batchProgressInterval)
+		running, err := model.CountRunningBatches()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("count running batches failed: %s", err.Error()))
+			continue
+		}
+		if int(running) >= operation_setting.GetBatchSetting().MaxRunningBatches {
+			continue
+		}
+		batch, err := model.ClaimNextBatch()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("claim batch failed: %s", err.Error()))
+			continue
+		}
+		if batch == nil {
+			continue
+		}
+		gopool.Go(func() {
+			runBatch(batch)
+		})
+	}
+}
+
+func failBatch(batch *model.Batch, batchErrors []dto.BatchError) {
+	data, _ := common.Marshal(batchErrors)
+	batch.Errors = string(data)
+	batch.Status = model.BatchStatusFailed
+	batch.FailedAt = common.GetTimestamp()
+	if err := batch.Update(); err != nil {
+		common.SysLog(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+}
+
+// readBatchInput 读取并校验输入文件，任何一行不合法都会使整个批处理失败
+func readBatchInput(batch *model.Batch) ([]*dto.BatchRequestInput, []dto.BatchError) {
+	inputFile, err := model.GetUserFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		return nil, []dto.BatchError{{Code: "invalid_input_file", Message: err.Error()}}
+	}
+	storage, err := service.GetFileStorage()
+	if err != nil {
+		return nil, []dto.BatchError{{Code: "file_storage_error", Message: err.Error()}}
+	}
+	reader, err := storage.Open(inputFile.StorageKey)
+	if err != nil {
+		return nil, []dto.BatchError{{Code: "invalid_input_file", Message: err.Error()}}
+	}
+	defer reader.Close()
+
+	maxRequests := operation_setting.GetBatchSetting().MaxRequests
+	var inputs []*dto.BatchRequestInput
+	var batchErrors []dto.BatchError
+	customIds := make(map[string]bool)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		addError := func(code string, message string) {
+			l := lineNo
+			batchErrors = append(batchErrors, dto.BatchError{Code: code, Message: message, Line: &l})
+		}
+		var input dto.BatchRequestInput
+		if err := common.Unmarshal(line, &input); err != nil {
+			addError("invalid_json_line", err.Error())
+			continue
+		}
+		if input.CustomId == "" || customIds[input.CustomId] {
+			addError("invalid_custom_id", "custom_id must be present and unique")
+			continue
+		}
+		if input.Method != http.MethodPost {
+			addError("invalid_method", "only POST is supported")
+			continue
+		}
+		if input.Url != batch.Endpoint {
+			addError("mismatched_endpoint", fmt.Sprintf("url %s does not match batch endpoint %s", input.Url, batch.Endpoint))
+			continue
+		}
+		customIds[input.CustomId] = true
+		inputs = append(inputs, &input)
+		if maxRequests > 0 && len(inputs) > maxRequests {
+			return nil, []dto.BatchError{{Code: "too_many_requests", Message: fmt.Sprintf("batch input exceeds %d requests", maxRequests)}}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, []dto.BatchError{{Code: "invalid_input_file", Message: err.Error()}}
+	}
+	if len(batchErrors) == 0 && len(inputs) == 0 {
+		batchErrors = append(batchErrors, dto.BatchError{Code: "empty_file", Message: "batch input file is empty"})
+	}
+	return inputs, batchErrors
+}
+
+// setupBatchContext 按 TokenAuth 的方式为单行请求准备上下文，每行都会重新校验令牌与用户状态
+func setupBatchContext(c *gin.Context, batch *model.Batch, tokenKey string) error {
+	token, err := model.ValidateUserToken(tokenKey)
+	if err != nil {
+		return err
+	}
+	userCache, err := model.GetUserCache(batch.UserId)
+	if err != nil {
+		return err
+	}
+	if userCache.Status != common.UserStatusEnabled {
+		return errors.New("用户已被封禁")
+	}
+	userCache.WriteContext(c)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, batch.Group)
+	if err = middleware.SetupContextForToken(c, token); err != nil {
+		return err
+	}
+	common.SetContextKey(c, constant.ContextKeyBatchId, batch.BatchId)
+	common.SetContextKey(c, constant.ContextKeyBatchDiscountRatio, operation_setting.GetBatchDiscountRatio())
+	return nil
+}
+
+// executeBatchRequest 通过 Distribute 与 Relay 执行一行请求，返回状态码、响应体与请求 id
+func executeBatchRequest(batch *model.Batch, tokenKey string, input *dto.BatchRequestInput) (int, []byte, string) {
+	requestId := common.GetTimeString() + common.GetRandomString(8)
+	body := input.Body
+	// 批处理不支持流式输出
+	var fields map[string]json.RawMessage
+	if err := common.Unmarshal(input.Body, &fields); err == nil {
+		delete(fields, "stream")
+		delete(fields, "stream_options")
+		body, _ = common.Marshal(fields)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req, err := http.NewRequestWithContext(context.WithValue(context.Background(), common.RequestIdKey, requestId), http.MethodPost, input.Url, bytes.NewReader(body))
+	if err != nil {
+		return http.StatusBadRequest, batchErrorBody(err.Error()), requestId
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	c.Set(common.RequestIdKey, requestId)
+
+	if err = setupBatchContext(c, batch, tokenKey); err != nil {
+		return http.StatusUnauthorized, batchErrorBody(err.Error()), requestId
+	}
+	middleware.Distribute()(c)
+	if !c.IsAborted() {
+		Relay(c, batchEndpointFormats[batch.Endpoint])
+	}
+	respBody := w.Body.Bytes()
+	if !json.Valid(respBody) {
+		respBody = batchErrorBody(string(respBody))
+	}
+	return w.Code, respBody, requestId
+}
+
+func batchErrorBody(message string) []byte {
+	body, _ := common.Marshal(map[string]any{
+		"error": dto.OpenAIError{
+			Message: message,
+			Type:    "new_api_error",
+		},
+	})
+	return body
+}
+
+type batchResultWriter struct {
+	mu     sync.Mutex
+	output *os.File
+	errors *os.File
+}
+
+func (w *batchResultWriter) write(failed bool, result *dto.BatchRequestOutput) {
+	data, err := common.Marshal(result)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	target := w.output
+	if failed {
+		target = w.errors
+	}
+	_, _ = target.Write(append(data, '\n'))
+}
+
+// saveBatchResultFile 将结果临时文件写入文件存储并登记到 File 表，空文件不保存
+func saveBatchResultFile(batch *model.Batch, tmp *os.File, filename string) (string, error) {
+	info, err := tmp.Stat()
+	if err != nil || info.Size() == 0 {
+		return "", err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	storage, err := service.GetFileStorage()
+	if err != nil {
+		return "", err
+	}
+	file := &model.File{
+		FileId:   model.GenerateFileId(),
+		UserId:   batch.UserId,
+		TokenId:  batch.TokenId,
+		Filename: filename,
+		Purpose:  "batch_output",
+		Status:   model.FileStatusProcessed,
+	}
+	file.StorageKey = file.FileId
+	if file.Bytes, err = storage.Save(file.StorageKey, tmp); err != nil {
+		return "", err
+	}
+	if err = file.Insert(); err != nil {
+		_ = storage.Delete(file.StorageKey)
+		return "", err
+	}
+	return file.FileId, nil
+}
+
+func runBatch(batch *model.Batch) {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysLog(fmt.Sprintf("batch %s panic: %v", batch.BatchId, r))
+			failBatch(batch, []dto.BatchError{{Code: "internal_error", Message: fmt.Sprintf("%v", r)}})
+		}
+	}()
+	inputs, batchErrors := readBatchInput(batch)
+	if len(batchErrors) > 0 {
+		failBatch(batch, batchErrors)
+		return
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		failBatch(batch, []dto.BatchError{{Code: "invalid_token", Message: err.Error()}})
+		return
+	}
+	batch.RequestTotal = len(inputs)
+	if err = batch.UpdateProgress(); err != nil {
+		common.SysLog(fmt.Sprintf("update batch %s progress failed: %s", batch.BatchId, err.Error()))
+	}
+
+	outputTmp, err := os.CreateTemp("", "batch-output-*.jsonl")
+	if err != nil {
+		failBatch(batch, []dto.BatchError{{Code: "internal_error", Message: err.Error()}})
+		return
+	}
+	defer os.Remove(outputTmp.Name())
+	defer outputTmp.Close()
+	errorTmp, err := os.CreateTemp("", "batch-error-*.jsonl")
+	if err != nil {
+		failBatch(batch, []dto.BatchError{{Code: "internal_error", Message: err.Error()}})
+		return
+	}
+	defer os.Remove(errorTmp.Name())
+	defer errorTmp.Close()
+	writer := &batchResultWriter{output: outputTmp, errors: errorTmp}
+
+	// dispatchCtx 只控制是否继续派发新请求，已发出的请求会执行完毕
+	dispatchCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		countLock sync.Mutex
+		cancelled bool
+		expired   bool
+	)
+	// 定期保存进度，同时检测取消与过期
+	monitorDone := make(chan struct{})
+	var monitorWg sync.WaitGroup
+	monitorWg.Add(1)
+	go func() {
+		defer monitorWg.Done()
+		ticker := time.NewTicker(batchProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-monitorDone:
+				return
+			case <-ticker.C:
+			}
+			countLock.Lock()
+			_ = batch.UpdateProgress()
+			countLock.Unlock()
+			status, err := model.GetBatchStatus(batch.Id)
+			if err == nil && status == model.BatchStatusCancelling {
+				cancelled = true
+				cancel()
+				return
+			}
+			if common.GetTimestamp() > batch.ExpiresAt {
+				expired = true
+				cancel()
+				return
+			}
+		}
+	}()
+
+	concurrency := operation_setting.GetBatchSetting().Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	dispatched := 0
+	for _, input := range inputs {
+		select {
+		case <-dispatchCtx.Done():
+		case sem <- struct{}{}:
+		}
+		if dispatchCtx.Err() != nil {
+			break
+		}
+		dispatched++
+		wg.Add(1)
+		input := input
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			statusCode, respBody, requestId := executeBatchRequest(batch, token.Key, input)
+			failed := statusCode != http.StatusOK
+			writer.write(failed, &dto.BatchRequestOutput{
+				Id:       "batch_req_" + common.GetRandomString(24),
+				CustomId: input.CustomId,
+				Response: &dto.BatchResponse{
+					StatusCode: statusCode,
+					RequestId:  requestId,
+					Body:       respBody,
+				},
+			})
+			countLock.Lock()
+			if failed {
+				batch.RequestFailed++
+			} else {
+				batch.RequestCompleted++
+			}
+			countLock.Unlock()
+		})
+	}
+	wg.Wait()
+	close(monitorDone)
+	monitorWg.Wait()
+
+	// 过期时未执行的请求写入错误文件
+	if expired {
+		for _, input := range inputs[dispatched:] {
+			writer.write(true, &dto.BatchRequestOutput{
+				Id:       "batch_req_" + common.GetRandomString(24),
+				CustomId: input.CustomId,
+				Error:    &dto.BatchError{Code: "batch_expired", Message: "This request could not be executed before the completion window expired."},
+			})
+		}
+	}
+
+	batch.Status = model.BatchStatusFinalizing
+	batch.FinalizingAt = common.GetTimestamp()
+	if status, err := model.GetBatchStatus(batch.Id); err == nil && status == model.BatchStatusCancelling {
+		cancelled = true
+		batch.Status = model.BatchStatusCancelling
+	}
+	_ = batch.Update()
+
+	var finalizeErrors []dto.BatchError
+	if batch.OutputFileId, err = saveBatchResultFile(batch, outputTmp, batch.BatchId+"_output.jsonl"); err != nil {
+		finalizeErrors = append(finalizeErrors, dto.BatchError{Code: "output_file_error", Message: err.Error()})
+	}
+	if batch.ErrorFileId, err = saveBatchResultFile(batch, errorTmp, batch.BatchId+"_error.jsonl"); err != nil {
+		finalizeErrors = append(finalizeErrors, dto.BatchError{Code: "error_file_error", Message: err.Error()})
+	}
+	if len(finalizeErrors) > 0 {
+		failBatch(batch, finalizeErrors)
+		return
+	}
+
+	now := common.GetTimestamp()
+	switch {
+	case cancelled:
+		batch.Status = model.BatchStatusCancelled
+		batch.CancelledAt = now
+	case expired:
+		batch.Status = model.BatchStatusExpired
+		batch.ExpiredAt = now
+	default:
+		batch.Status = model.BatchStatusCompleted
+		batch.CompletedAt = now
+	}
+	if err = batch.Update(); err != nil {
+		common.SysLog(fmt.Sprintf("update batch %s failed: %s", batch.BatchId, err.Error()))
+	}
+	logger.LogInfo(dispatchCtx, fmt.Sprintf("batch %s finished with status %s, completed %d, failed %d", batch.BatchId, batch.Status, batch.RequestCompleted, batch.RequestFailed))
+}
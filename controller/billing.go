@@ -3,10 +3,25 @@ package controller
 import (
 	"github.com/gin-gonic/gin"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
 )
 
+// getBillingUsedQuota 组织令牌返回成员在组织中的已用额度
+func getBillingUsedQuota(userId int, orgId int) (int, error) {
+	if orgId != 0 {
+		member, err := model.GetOrganizationMember(orgId, userId)
+		if err != nil {
+			return 0, err
+		}
+		return member.UsedQuota, nil
+	}
+	return model.GetUserUsedQuota(userId)
+}
+
 func GetSubscription(c *gin.Context) {
 	var remainQuota int
 	var usedQuota int
@@ -21,7 +36,12 @@ func GetSubscription(c *gin.Context) {
 		usedQuota = token.UsedQuota
 	} else {
 		userId := c.GetInt("id")
-		remainQuota, err = model.GetUserQuota(userId, false)
+		orgId := common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId)
+		// 组织令牌从组织额度中扣费，显示成员可使用的组织额度
+		remainQuota, err = service.GetBillingQuota(&relaycommon.RelayInfo{
+			UserId: userId,
+			OrgId:  orgId,
+		})
 		if err != nil {
 			openAIError := dto.OpenAIError{
 				Message: err.Error(),
@@ -32,7 +52,7 @@ func GetSubscription(c *gin.Context) {
 			})
 			return
 		}
-		usedQuota, err = model.GetUserUsedQuota(userId)
+		usedQuota, err = getBillingUsedQuota(userId, orgId)
 	}
 	if expiredTime <= 0 {
 		expiredTime = 0
@@ -76,8 +96,7 @@ func GetUsage(c *gin.Context) {
 		token, err = model.GetTokenById(tokenId)
 		quota = token.UsedQuota
 	} else {
-		userId := c.GetInt("id")
-		quota, err = model.GetUserUsedQuota(userId)
+		quota, err = getBillingUsedQuota(c.GetInt("id"), common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId))
 	}
 	if err != nil {
 		openAIError := dto.OpenAIError{
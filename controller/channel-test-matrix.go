@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"one-api/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// matrixEndpointTypes 返回测试矩阵中模型需要测试的端点类型
+func matrixEndpointTypes(channel *model.Channel, modelName string) []constant.EndpointType {
+	if strings.Contains(strings.ToLower(modelName), "embed") ||
+		strings.HasPrefix(modelName, "m3e") ||
+		strings.Contains(modelName, "bge-") ||
+		channel.Type == constant.ChannelTypeMokaAI {
+		return []constant.EndpointType{constant.EndpointTypeEmbeddings}
+	}
+	return common.GetEndpointTypesByChannelType(channel.Type, modelName)
+}
+
+// testChannelMatrix 逐个测试渠道的模型与端点类型并保存结果。
+// 所有端点都因模型不存在等模型相关错误失败的模型只禁用该模型的能力，其余模型不受影响；之前被禁用的模型测试通过后恢复。
+// 密钥失效、余额不足等渠道级错误按普通渠道测试禁用整个渠道并通知，不再继续测试
+func testChannelMatrix(channel *model.Channel) []model.ChannelTestResult {
+	results := make([]model.ChannelTestResult, 0)
+	passedModels := make([]string, 0)
+	// 失败的模型 -> 失败原因
+	failedModels := make(map[string]string)
+	var totalLatency int64
+	// 恢复渠道时使用测试通过的密钥，多Key渠道按密钥记录状态
+	passedKey := ""
+	channelFailed := false
+	for _, modelName := range channel.GetModels() {
+		modelPassed := false
+		modelFailed := false
+		failReason := ""
+		for _, endpointType := range matrixEndpointTypes(channel, modelName) {
+			tik := time.Now()
+			result := testChannel(channel, modelName, string(endpointType))
+			latency := time.Since(tik).Milliseconds()
+			testResult := model.ChannelTestResult{
+				ChannelId:    channel.Id,
+				Model:        modelName,
+				EndpointType: string(endpointType),
+				Success:      result.localErr == nil,
+				Latency:      latency,
+				TestedAt:     common.GetTimestamp(),
+			}
+			if result.localErr != nil {
+				testResult.Message = result.localErr.Error()
+				failReason = testResult.Message
+				if service.IsModelSpecificError(result.newAPIError) {
+					modelFailed = true
+				} else if service.ShouldDisableChannel(channel.Type, result.newAPIError) {
+					channelFailed = true
+					if channel.Status == common.ChannelStatusEnabled && channel.GetAutoBan() {
+						processChannelError(result.context, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.GetAutoBan()), result.newAPIError)
+					}
+				}
+			} else {
+				modelPassed = true
+				totalLatency += latency
+				if passedKey == "" {
+					passedKey = common.GetContextKeyString(result.context, constant.ContextKeyChannelKey)
+				}
+			}
+			results = append(results, testResult)
+			time.Sleep(common.RequestInterval)
+			if channelFailed {
+				break
+			}
+		}
+		if channelFailed {
+			break
+		}
+		if modelPassed {
+			passedModels = append(passedModels, modelName)
+		} else if modelFailed {
+			failedModels[modelName] = failReason
+		}
+	}
+	if err := model.SaveChannelTestResults(channel.Id, results); err != nil {
+		common.SysLog("failed to save channel test results: " + err.Error())
+	}
+	if channelFailed {
+		return results
+	}
+
+	// 渠道整体被自动禁用时，只要有模型可用就先恢复渠道，恢复会启用所有能力，随后再禁用失败的模型
+	if len(passedModels) > 0 && service.ShouldEnableChannel(nil, channel.Status) {
+		service.EnableChannel(channel.Id, passedKey, channel.Name)
+		channel.Status = common.ChannelStatusEnabled
+	}
+	if channel.Status == common.ChannelStatusEnabled {
+		changed := false
+		if common.AutomaticEnableChannelEnabled {
+			for _, modelName := range passedModels {
+				if rows, err := model.UpdateAbilityStatusByModel(channel.Id, modelName, true, ""); err == nil && rows > 0 {
+					common.SysLog(fmt.Sprintf("channel #%d model %s passed the test matrix, enabling its abilities", channel.Id, modelName))
+					changed = true
+				}
+			}
+		}
+		if common.AutomaticDisableChannelEnabled && channel.GetAutoBan() {
+			for modelName, reason := range failedModels {
+				if rows, err := model.UpdateAbilityStatusByModel(channel.Id, modelName, false, reason); err == nil && rows > 0 {
+					common.SysLog(fmt.Sprintf("channel #%d model %s failed the test matrix, disabling its abilities", channel.Id, modelName))
+					changed = true
+				}
+			}
+		}
+		if changed {
+			model.InitChannelCache()
+		}
+	}
+	if len(passedModels) > 0 {
+		channel.UpdateResponseTime(totalLatency / int64(len(passedModels)))
+	}
+	return results
+}
+
+// recoverDisabledAbilities 逐个测试渠道中单独被禁用的模型，测试通过后恢复
+func recoverDisabledAbilities(channel *model.Channel) {
+	if !common.AutomaticEnableChannelEnabled || channel.Status != common.ChannelStatusEnabled {
+		return
+	}
+	abilities, err := model.GetDisabledAbilities(channel.Id)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to get disabled abilities of channel #%d: %v", channel.Id, err))
+		return
+	}
+	tested := make(map[string]bool)
+	changed := false
+	for _, ability := range abilities {
+		if tested[ability.Model] {
+			continue
+		}
+		tested[ability.Model] = true
+		result := testChannel(channel, ability.Model, "")
+		if result.localErr == nil {
+			if rows, err := model.UpdateAbilityStatusByModel(channel.Id, ability.Model, true, ""); err == nil && rows > 0 {
+				common.SysLog(fmt.Sprintf("channel #%d model %s passed the test, enabling its abilities", channel.Id, ability.Model))
+				changed = true
+			}
+		}
+		time.Sleep(common.RequestInterval)
+	}
+	if changed {
+		model.InitChannelCache()
+	}
+}
+
+func TestChannelMatrix(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	results := testChannelMatrix(channel)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    results,
+	})
+}
+
+func GetChannelTestResults(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	results, err := model.GetChannelTestResults(channelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    results,
+	})
+}
@@ -39,6 +39,11 @@ type testResult struct {
 }
 
 func testChannel(channel *model.Channel, testModel string, endpointType string) testResult {
+	return testChannelKey(channel, testModel, endpointType, -1)
+}
+
+// testChannelKey 使用多Key渠道中指定索引的密钥测试渠道，keyIndex 小于 0 时按渠道的密钥选择策略选择
+func testChannelKey(channel *model.Channel, testModel string, endpointType string, keyIndex int) testResult {
 	tik := time.Now()
 	if channel.Type == constant.ChannelTypeMidjourney {
 		return testResult{
@@ -138,7 +143,12 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 	group, _ := model.GetUserGroup(1, false)
 	c.Set("group", group)
 
-	newAPIError := middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	var newAPIError *types.NewAPIError
+	if keyIndex >= 0 {
+		newAPIError = middleware.SetupContextForSelectedChannelKey(c, channel, testModel, keyIndex)
+	} else {
+		newAPIError = middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	}
 	if newAPIError != nil {
 		return testResult{
 			context:     c,
@@ -575,6 +585,10 @@ func testAllChannels(notify bool) error {
 		}()
 
 		for _, channel := range channels {
+			if operation_setting.GetMonitorSetting().ChannelTestMatrixEnabled && channel.Status != common.ChannelStatusManuallyDisabled {
+				testChannelMatrix(channel)
+				continue
+			}
 			isChannelEnabled := channel.Status == common.ChannelStatusEnabled
 			tik := time.Now()
 			result := testChannel(channel, "", "")
@@ -609,6 +623,10 @@ func testAllChannels(notify bool) error {
 
 			channel.UpdateResponseTime(milliseconds)
 			time.Sleep(common.RequestInterval)
+
+			if !shouldBanChannel {
+				recoverDisabledAbilities(channel)
+			}
 		}
 
 		if notify {
@@ -653,3 +671,57 @@ func AutomaticallyTestChannels() {
 		}
 	})
 }
+
+var autoRecoverKeysOnce sync.Once
+
+// AutomaticallyRecoverChannelKeys 定期探测多Key渠道中被自动禁用的密钥，测试通过后重新启用。
+// 手动禁用的密钥不会被探测
+func AutomaticallyRecoverChannelKeys() {
+	autoRecoverKeysOnce.Do(func() {
+		for {
+			setting := operation_setting.GetMonitorSetting()
+			if !setting.AutoRecoverKeyEnabled || setting.AutoRecoverKeyMinutes <= 0 {
+				time.Sleep(10 * time.Minute)
+				continue
+			}
+			time.Sleep(time.Duration(setting.AutoRecoverKeyMinutes) * time.Minute)
+			if !operation_setting.GetMonitorSetting().AutoRecoverKeyEnabled {
+				continue
+			}
+			recoverChannelKeys()
+		}
+	})
+}
+
+func recoverChannelKeys() {
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		common.SysLog("failed to get channels for key recovery: " + err.Error())
+		return
+	}
+	cooldown := int64(operation_setting.GetMonitorSetting().AutoRecoverKeyCooldownMinutes) * 60
+	now := common.GetTimestamp()
+	for _, channel := range channels {
+		if !channel.ChannelInfo.IsMultiKey || channel.Status == common.ChannelStatusManuallyDisabled {
+			continue
+		}
+		keys := channel.GetKeys()
+		for keyIndex, status := range channel.ChannelInfo.MultiKeyStatusList {
+			if status != common.ChannelStatusAutoDisabled || keyIndex >= len(keys) {
+				continue
+			}
+			if now-channel.ChannelInfo.MultiKeyDisabledTime[keyIndex] < cooldown {
+				continue
+			}
+			result := testChannelKey(channel, "", "", keyIndex)
+			if result.localErr != nil {
+				common.SysLog(fmt.Sprintf("channel #%d key #%d is still unavailable: %s", channel.Id, keyIndex, result.localErr.Error()))
+			} else {
+				common.SysLog(fmt.Sprintf("channel #%d key #%d passed the recovery test, enabling", channel.Id, keyIndex))
+				model.RecordCircuitBreakerSuccess(channel.Id, keyIndex)
+				service.EnableChannel(channel.Id, keys[keyIndex], channel.Name)
+			}
+			time.Sleep(common.RequestInterval)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"one-api/service"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 
@@ -1069,11 +1070,14 @@ func CopyChannel(c *gin.Context) {
 // MultiKeyManageRequest represents the request for multi-key management operations
 type MultiKeyManageRequest struct {
 	ChannelId int    `json:"channel_id"`
-	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status"
+	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status", "set_key_limit"
 	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key, enable_key, and delete_key actions
 	Page      int    `json:"page,omitempty"`      // for get_key_status pagination
 	PageSize  int    `json:"page_size,omitempty"` // for get_key_status pagination
 	Status    *int   `json:"status,omitempty"`    // for get_key_status filtering: 1=enabled, 2=manual_disabled, 3=auto_disabled, nil=all
+	Weight    *int   `json:"weight,omitempty"`    // for set_key_limit
+	RPM       *int   `json:"rpm,omitempty"`       // for set_key_limit, 0 means unlimited
+	TPM       *int   `json:"tpm,omitempty"`       // for set_key_limit, 0 means unlimited
 }
 
 // MultiKeyStatusResponse represents the response for key status query
@@ -1095,6 +1099,11 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	Weight       int    `json:"weight"`
+	RPM          int    `json:"rpm"`
+	TPM          int    `json:"tpm"`
+	// Usage 当前分钟窗口与累计的请求数、token 数
+	Usage model.ChannelKeyUsage `json:"usage"`
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1143,6 +1152,7 @@ func ManageMultiKeys(c *gin.Context) {
 
 		// Statistics for all keys (unchanged by filtering)
 		var enabledCount, manualDisabledCount, autoDisabledCount int
+		usages := model.GetChannelKeyUsages(channel.Id)
 
 		// Build all key status data first
 		var allKeyStatusList []KeyStatus
@@ -1182,12 +1192,21 @@ func ManageMultiKeys(c *gin.Context) {
 				keyPreview = key[:10] + "..."
 			}
 
+			limit := channel.ChannelInfo.MultiKeyLimits[i]
+			weight := limit.Weight
+			if weight <= 0 {
+				weight = 1
+			}
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
 				Index:        i,
 				Status:       status,
 				DisabledTime: disabledTime,
 				Reason:       reason,
 				KeyPreview:   keyPreview,
+				Weight:       weight,
+				RPM:          limit.RPM,
+				TPM:          limit.TPM,
+				Usage:        usages[i],
 			})
 		}
 
@@ -1398,6 +1417,64 @@ func ManageMultiKeys(c *gin.Context) {
 		})
 		return
 
+	case "set_key_limit":
+		if request.KeyIndex == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "未指定要设置的密钥索引",
+			})
+			return
+		}
+
+		keyIndex := *request.KeyIndex
+		if keyIndex < 0 || keyIndex >= channel.ChannelInfo.MultiKeySize {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "密钥索引超出范围",
+			})
+			return
+		}
+
+		limit := channel.ChannelInfo.MultiKeyLimits[keyIndex]
+		if request.Weight != nil {
+			limit.Weight = *request.Weight
+		}
+		if request.RPM != nil {
+			limit.RPM = *request.RPM
+		}
+		if request.TPM != nil {
+			limit.TPM = *request.TPM
+		}
+		if limit.Weight < 0 || limit.RPM < 0 || limit.TPM < 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "权重与限额不能为负数",
+			})
+			return
+		}
+
+		if channel.ChannelInfo.MultiKeyLimits == nil {
+			channel.ChannelInfo.MultiKeyLimits = make(map[int]model.MultiKeyLimit)
+		}
+		if limit == (model.MultiKeyLimit{}) {
+			delete(channel.ChannelInfo.MultiKeyLimits, keyIndex)
+		} else {
+			channel.ChannelInfo.MultiKeyLimits[keyIndex] = limit
+		}
+
+		err = channel.Update()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		model.InitChannelCache()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "密钥限额已更新",
+		})
+		return
+
 	case "delete_key":
 		if request.KeyIndex == nil {
 			c.JSON(http.StatusOK, gin.H{
@@ -1421,6 +1498,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var newLimits = make(map[int]model.MultiKeyLimit)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1430,6 +1508,9 @@ func ManageMultiKeys(c *gin.Context) {
 			}
 
 			remainingKeys = append(remainingKeys, key)
+			if limit, exists := channel.ChannelInfo.MultiKeyLimits[i]; exists {
+				newLimits[newIndex] = limit
+			}
 
 			// 保留其他密钥的状态信息，重新索引
 			if channel.ChannelInfo.MultiKeyStatusList != nil {
@@ -1464,6 +1545,7 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.MultiKeyLimits = newLimits
 
 		err = channel.Update()
 		if err != nil {
@@ -1471,6 +1553,8 @@ func ManageMultiKeys(c *gin.Context) {
 			return
 		}
 
+		// 索引已重新排列，旧的用量统计不再对应
+		model.ResetChannelKeyUsage(channel.Id)
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -1485,6 +1569,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var newLimits = make(map[int]model.MultiKeyLimit)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1500,6 +1585,9 @@ func ManageMultiKeys(c *gin.Context) {
 				deletedCount++
 			} else {
 				remainingKeys = append(remainingKeys, key)
+				if limit, exists := channel.ChannelInfo.MultiKeyLimits[i]; exists {
+					newLimits[newIndex] = limit
+				}
 				// 保留非自动禁用密钥的状态信息，重新索引
 				if status != 1 {
 					newStatusList[newIndex] = status
@@ -1532,6 +1620,7 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.MultiKeyLimits = newLimits
 
 		err = channel.Update()
 		if err != nil {
@@ -1539,6 +1628,8 @@ func ManageMultiKeys(c *gin.Context) {
 			return
 		}
 
+		// 索引已重新排列，旧的用量统计不再对应
+		model.ResetChannelKeyUsage(channel.Id)
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -1555,3 +1646,84 @@ func ManageMultiKeys(c *gin.Context) {
 		return
 	}
 }
+
+// GetChannelScores 查看自适应选择使用的渠道实时统计
+func GetChannelScores(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	selectSetting := operation_setting.GetChannelSelectSetting()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"default_mode":   selectSetting.DefaultMode,
+			"group_modes":    selectSetting.GroupModes,
+			"window_seconds": selectSetting.WindowSeconds,
+			"scores":         model.GetChannelScores(c.Query("model"), channelId),
+		},
+	})
+}
+
+// GetCircuitBreakers 查看当前节点的渠道熔断状态
+func GetCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetCircuitBreakerStates(channelId),
+	})
+}
+
+// ResetCircuitBreakers 手动恢复熔断中的渠道，channel_id 为空时恢复全部
+func ResetCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	model.ResetCircuitBreakers(channelId)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetDisabledAbilities 列出单独被禁用的模型能力，channel_id 为空时返回全部
+func GetDisabledAbilities(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	abilities, err := model.GetDisabledAbilities(channelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    abilities,
+	})
+}
+
+type EnableAbilityRequest struct {
+	ChannelId int    `json:"channel_id"`
+	Group     string `json:"group"` // 为空时启用该模型在所有分组下的能力
+	Model     string `json:"model"`
+}
+
+// EnableAbility 手动恢复单独被禁用的模型能力
+func EnableAbility(c *gin.Context) {
+	var req EnableAbilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChannelId == 0 || req.Model == "" {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	var err error
+	if req.Group == "" {
+		_, err = model.UpdateAbilityStatusByModel(req.ChannelId, req.Model, true, "")
+	} else {
+		_, err = model.UpdateAbilityStatusByGroupModel(req.Group, req.Model, req.ChannelId, true, "")
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func respondOpenAIError(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+func fileToOpenAIFile(file *model.File) dto.OpenAIFile {
+	openaiFile := dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+	if file.StatusDetails != "" {
+		openaiFile.StatusDetails = &file.StatusDetails
+	}
+	return openaiFile
+}
+
+// getUserFile 查询当前令牌用户的文件，不存在或不属于该用户时直接返回 404
+func getUserFile(c *gin.Context) (*model.File, bool) {
+	file, err := model.GetUserFileByFileId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondOpenAIError(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", c.Param("id")))
+		} else {
+			respondOpenAIError(c, http.StatusInternalServerError, "query_data_error", err.Error())
+		}
+		return nil, false
+	}
+	return file, true
+}
+
+// selectFileChannel 为透传上传选择渠道：管理员指定渠道优先，其次按 model 表单字段选择
+func selectFileChannel(c *gin.Context) (*model.Channel, error) {
+	if channelId := common.GetContextKeyString(c, constant.ContextKeyTokenSpecificChannelId); channelId != "" {
+		id, err := strconv.Atoi(channelId)
+		if err != nil {
+			return nil, errors.New("无效的渠道 Id")
+		}
+		return model.GetChannelById(id, true)
+	}
+	modelName := c.PostForm("model")
+	if modelName == "" {
+		return nil, nil
+	}
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, common.GetContextKeyString(c, constant.ContextKeyUsingGroup), modelName, 0)
+	return channel, err
+}
+
+func passThroughFile(c *gin.Context, file *model.File, storage service.FileStorage) error {
+	channel, err := selectFileChannel(c)
+	if err != nil || channel == nil {
+		return err
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		return errors.New("该渠道已被禁用")
+	}
+	if !service.ChannelSupportsFiles(channel) {
+		return nil
+	}
+	_, keyIndex, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return apiErr
+	}
+	reader, err := storage.Open(file.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	upstreamFile, err := service.UploadFileToChannel(channel, keyIndex, file.Filename, file.Purpose, reader)
+	if err != nil {
+		return err
+	}
+	file.ChannelId = channel.Id
+	file.ChannelKeyIdx = keyIndex
+	file.UpstreamFileId = upstreamFile.Id
+	if upstreamFile.Status != "" {
+		file.Status = upstreamFile.Status
+	}
+	return nil
+}
+
+func UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", "purpose is required")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", "file is required")
+		return
+	}
+	setting := system_setting.GetFileSetting()
+	if setting.MaxFileSizeMB > 0 && header.Size > int64(setting.MaxFileSizeMB)<<20 {
+		respondOpenAIError(c, http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("file size exceeds the limit of %d MB", setting.MaxFileSizeMB))
+		return
+	}
+	storage, err := service.GetFileStorage()
+	if err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "file_storage_error", err.Error())
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	defer src.Close()
+
+	file := &model.File{
+		FileId:   model.GenerateFileId(),
+		UserId:   c.GetInt("id"),
+		TokenId:  c.GetInt("token_id"),
+		Filename: header.Filename,
+		Purpose:  purpose,
+		Status:   model.FileStatusProcessed,
+	}
+	file.StorageKey = file.FileId
+	file.Bytes, err = storage.Save(file.StorageKey, src)
+	if err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "file_storage_error", err.Error())
+		return
+	}
+	if setting.PassThroughEnabled {
+		if err = passThroughFile(c, file, storage); err != nil {
+			_ = storage.Delete(file.StorageKey)
+			respondOpenAIError(c, http.StatusBadGateway, "upstream_file_error", err.Error())
+			return
+		}
+	}
+	if err = file.Insert(); err != nil {
+		_ = storage.Delete(file.StorageKey)
+		respondOpenAIError(c, http.StatusInternalServerError, "insert_data_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, fileToOpenAIFile(file))
+}
+
+func ListFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	// 多查一条用于判断 has_more
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	resp := dto.OpenAIFileList{
+		Object: "list",
+		Data:   make([]dto.OpenAIFile, 0, len(files)),
+	}
+	if len(files) > limit {
+		resp.HasMore = true
+		files = files[:limit]
+	}
+	for _, file := range files {
+		resp.Data = append(resp.Data, fileToOpenAIFile(file))
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func RetrieveFile(c *gin.Context) {
+	file, ok := getUserFile(c)
+	if !ok {
+		return
+	}
+	// 上游文件可能仍在处理中，同步一次状态
+	if file.UpstreamFileId != "" && file.Status != model.FileStatusProcessed {
+		if channel, err := model.GetChannelById(file.ChannelId, true); err == nil {
+			upstreamFile, err := service.RetrieveChannelFile(channel, file.ChannelKeyIdx, file.UpstreamFileId)
+			if err != nil {
+				logger.LogWarn(c, fmt.Sprintf("retrieve upstream file %s failed: %s", file.UpstreamFileId, err.Error()))
+			} else if upstreamFile.Status != "" && upstreamFile.Status != file.Status {
+				file.Status = upstreamFile.Status
+				if upstreamFile.StatusDetails != nil {
+					file.StatusDetails = *upstreamFile.StatusDetails
+				}
+				_ = file.Update()
+			}
+		}
+	}
+	c.JSON(http.StatusOK, fileToOpenAIFile(file))
+}
+
+func GetFileContent(c *gin.Context) {
+	file, ok := getUserFile(c)
+	if !ok {
+		return
+	}
+	var reader io.ReadCloser
+	if file.StorageKey != "" {
+		if storage, err := service.GetFileStorage(); err == nil {
+			reader, err = storage.Open(file.StorageKey)
+			if err != nil {
+				logger.LogWarn(c, fmt.Sprintf("open file %s from storage failed: %s", file.FileId, err.Error()))
+				reader = nil
+			}
+		}
+	}
+	if reader == nil && file.UpstreamFileId != "" {
+		channel, err := model.GetChannelById(file.ChannelId, true)
+		if err != nil {
+			respondOpenAIError(c, http.StatusBadGateway, "upstream_file_error", err.Error())
+			return
+		}
+		resp, err := service.OpenChannelFileContent(channel, file.ChannelKeyIdx, file.UpstreamFileId)
+		if err != nil {
+			respondOpenAIError(c, http.StatusBadGateway, "upstream_file_error", err.Error())
+			return
+		}
+		reader = resp.Body
+	}
+	if reader == nil {
+		respondOpenAIError(c, http.StatusNotFound, "file_content_not_found", fmt.Sprintf("content of file %s is not available", file.FileId))
+		return
+	}
+	defer reader.Close()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, nil)
+}
+
+func DeleteFile(c *gin.Context) {
+	file, ok := getUserFile(c)
+	if !ok {
+		return
+	}
+	if file.UpstreamFileId != "" {
+		if channel, err := model.GetChannelById(file.ChannelId, true); err == nil {
+			if err = service.DeleteChannelFile(channel, file.ChannelKeyIdx, file.UpstreamFileId); err != nil {
+				logger.LogWarn(c, fmt.Sprintf("delete upstream file %s failed: %s", file.UpstreamFileId, err.Error()))
+			}
+		}
+	}
+	if file.StorageKey != "" {
+		if storage, err := service.GetFileStorage(); err == nil {
+			if err = storage.Delete(file.StorageKey); err != nil {
+				logger.LogWarn(c, fmt.Sprintf("delete file %s from storage failed: %s", file.FileId, err.Error()))
+			}
+		}
+	}
+	if err := file.Delete(); err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "delete_data_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleteResponse{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
@@ -0,0 +1,493 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting/ratio_setting"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fineTuneTaskStatus 将上游任务状态映射为 Task 状态与进度
+func fineTuneTaskStatus(status string) (model.TaskStatus, string) {
+	switch status {
+	case "validating_files", "queued":
+		return model.TaskStatusQueued, "20%"
+	case "running":
+		return model.TaskStatusInProgress, "30%"
+	case "succeeded":
+		return model.TaskStatusSuccess, "100%"
+	case "failed", "cancelled":
+		return model.TaskStatusFailure, "100%"
+	default:
+		return model.TaskStatusSubmitted, "10%"
+	}
+}
+
+// fineTuneFileId 把上游文件 id 换回网关文件 id，找不到时原样返回
+func fineTuneFileId(userId int, channelId int, upstreamFileId string) string {
+	file, err := model.GetUserFileByUpstreamId(userId, channelId, upstreamFileId)
+	if err != nil {
+		return upstreamFileId
+	}
+	return file.FileId
+}
+
+// registerFineTuneResultFile 结果文件只保存在上游，登记后可通过 /v1/files 读取
+func registerFineTuneResultFile(task *model.Task, upstreamFileId string) string {
+	if file, err := model.GetUserFileByUpstreamId(task.UserId, task.ChannelId, upstreamFileId); err == nil {
+		return file.FileId
+	}
+	file := &model.File{
+		FileId:         model.GenerateFileId(),
+		UserId:         task.UserId,
+		TokenId:        task.Properties.TokenId,
+		Filename:       upstreamFileId + ".csv",
+		Purpose:        "fine-tune-results",
+		Status:         model.FileStatusProcessed,
+		ChannelId:      task.ChannelId,
+		ChannelKeyIdx:  task.Properties.KeyIndex,
+		UpstreamFileId: upstreamFileId,
+	}
+	if err := file.Insert(); err != nil {
+		common.SysLog(fmt.Sprintf("register fine-tune result file %s error: %v", upstreamFileId, err))
+		return upstreamFileId
+	}
+	return file.FileId
+}
+
+func fineTuneJobResponse(task *model.Task, job *dto.FineTuningJob) dto.FineTuningJob {
+	resp := *job
+	resp.TrainingFile = fineTuneFileId(task.UserId, task.ChannelId, job.TrainingFile)
+	if job.ValidationFile != nil {
+		validationFile := fineTuneFileId(task.UserId, task.ChannelId, *job.ValidationFile)
+		resp.ValidationFile = &validationFile
+	}
+	resp.ResultFiles = make([]string, 0, len(job.ResultFiles))
+	for _, upstreamFileId := range job.ResultFiles {
+		resp.ResultFiles = append(resp.ResultFiles, registerFineTuneResultFile(task, upstreamFileId))
+	}
+	resp.OrganizationId = ""
+	return resp
+}
+
+func getUserFineTuneTask(c *gin.Context) (*model.Task, bool) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "query_data_error", err.Error())
+		return nil, false
+	}
+	if !exist || task.Platform != constant.TaskPlatformFineTune {
+		respondOpenAIError(c, http.StatusNotFound, "fine_tune_job_not_found", fmt.Sprintf("No such fine-tuning job: %s", c.Param("id")))
+		return nil, false
+	}
+	return task, true
+}
+
+func getFineTuneTaskChannel(c *gin.Context, task *model.Task) (*model.Channel, bool) {
+	channel, err := model.GetChannelById(task.ChannelId, true)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadGateway, "channel_not_found", fmt.Sprintf("channel #%d of fine-tuning job not found", task.ChannelId))
+		return nil, false
+	}
+	return channel, true
+}
+
+// ensureFileOnChannel 确保文件已上传到指定渠道的指定密钥下，必要时从本地存储重新上传
+func ensureFileOnChannel(channel *model.Channel, keyIndex int, file *model.File) error {
+	if file.UpstreamFileId != "" && file.ChannelId == channel.Id && file.ChannelKeyIdx == keyIndex {
+		return nil
+	}
+	if file.StorageKey == "" {
+		return fmt.Errorf("file %s is not available on the selected channel", file.FileId)
+	}
+	storage, err := service.GetFileStorage()
+	if err != nil {
+		return err
+	}
+	reader, err := storage.Open(file.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	upstreamFile, err := service.UploadFileToChannel(channel, keyIndex, file.Filename, file.Purpose, reader)
+	if err != nil {
+		return err
+	}
+	file.ChannelId = channel.Id
+	file.ChannelKeyIdx = keyIndex
+	file.UpstreamFileId = upstreamFile.Id
+	return file.Update()
+}
+
+// selectFineTuneChannel 训练文件已透传到某个渠道时沿用该渠道，否则按模型选择
+func selectFineTuneChannel(c *gin.Context, modelName string, trainingFile *model.File) (*model.Channel, int, error) {
+	if trainingFile.UpstreamFileId != "" {
+		channel, err := model.GetChannelById(trainingFile.ChannelId, true)
+		if err == nil && channel.Status == common.ChannelStatusEnabled {
+			return channel, trainingFile.ChannelKeyIdx, nil
+		}
+	}
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(c, common.GetContextKeyString(c, constant.ContextKeyUsingGroup), modelName, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if channel == nil {
+		return nil, 0, fmt.Errorf("no available channel for model %s", modelName)
+	}
+	if !service.ChannelSupportsFiles(channel) {
+		return nil, 0, fmt.Errorf("channel #%d does not support fine-tuning", channel.Id)
+	}
+	_, keyIndex, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, 0, apiErr
+	}
+	return channel, keyIndex, nil
+}
+
+func checkFineTuneModelAccess(c *gin.Context, modelName string) error {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return nil
+	}
+	tokenModelLimit, _ := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	if _, ok := tokenModelLimit[ratio_setting.FormatMatchingModelName(modelName)]; !ok {
+		return errors.New("该令牌无权访问模型 " + modelName)
+	}
+	return nil
+}
+
+func CreateFineTuningJob(c *gin.Context) {
+	var req map[string]any
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	modelName, _ := req["model"].(string)
+	trainingFileId, _ := req["training_file"].(string)
+	validationFileId, _ := req["validation_file"].(string)
+	if modelName == "" || trainingFileId == "" {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", "model and training_file are required")
+		return
+	}
+	if err := checkFineTuneModelAccess(c, modelName); err != nil {
+		respondOpenAIError(c, http.StatusForbidden, "model_not_allowed", err.Error())
+		return
+	}
+	if _, ok := ratio_setting.GetFineTuneTrainingPrice(modelName); !ok {
+		userSetting, _ := common.GetContextKeyType[dto.UserSetting](c, constant.ContextKeyUserSetting)
+		if !userSetting.AcceptUnsetRatioModel {
+			respondOpenAIError(c, http.StatusBadRequest, "model_price_error", fmt.Sprintf("fine-tuning price of model %s is not set", modelName))
+			return
+		}
+	}
+	// 训练 token 数在任务完成前未知，提交时只要求余额为正，完成后按实际用量结算
+	userQuota, err := service.GetBillingQuota(&relaycommon.RelayInfo{
+		UserId: c.GetInt("id"),
+		OrgId:  common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
+	})
+	if err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "get_user_quota_failed", err.Error())
+		return
+	}
+	if userQuota <= 0 {
+		respondOpenAIError(c, http.StatusForbidden, "insufficient_user_quota", "user quota is not enough")
+		return
+	}
+
+	trainingFile, err := model.GetUserFileByFileId(c.GetInt("id"), trainingFileId)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, "invalid_request", fmt.Sprintf("No such File object: %s", trainingFileId))
+		return
+	}
+	var validationFile *model.File
+	if validationFileId != "" {
+		validationFile, err = model.GetUserFileByFileId(c.GetInt("id"), validationFileId)
+		if err != nil {
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request", fmt.Sprintf("No such File object: %s", validationFileId))
+			return
+		}
+	}
+
+	channel, keyIndex, err := selectFineTuneChannel(c, modelName, trainingFile)
+	if err != nil {
+		respondOpenAIError(c, http.StatusServiceUnavailable, "no_available_channel", err.Error())
+		return
+	}
+	if err = ensureFileOnChannel(channel, keyIndex, trainingFile); err != nil {
+		respondOpenAIError(c, http.StatusBadGateway, "upstream_file_error", err.Error())
+		return
+	}
+	req["training_file"] = trainingFile.UpstreamFileId
+	if validationFile != nil {
+		if err = ensureFileOnChannel(channel, keyIndex, validationFile); err != nil {
+			respondOpenAIError(c, http.StatusBadGateway, "upstream_file_error", err.Error())
+			return
+		}
+		req["validation_file"] = validationFile.UpstreamFileId
+	}
+
+	job, err := service.CreateChannelFineTuningJob(channel, keyIndex, req)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadGateway, "upstream_fine_tune_error", err.Error())
+		return
+	}
+	status, progress := fineTuneTaskStatus(job.Status)
+	task := &model.Task{
+		TaskID:     job.Id,
+		Platform:   constant.TaskPlatformFineTune,
+		UserId:     c.GetInt("id"),
+		ChannelId:  channel.Id,
+		Action:     constant.TaskActionFineTune,
+		Status:     status,
+		Progress:   progress,
+		SubmitTime: time.Now().Unix(),
+		Properties: model.Properties{
+			Input:    modelName,
+			TokenId:  c.GetInt("token_id"),
+			Group:    common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+			KeyIndex: keyIndex,
+			OrgId:    common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
+		},
+	}
+	task.SetData(job)
+	if err = task.Insert(); err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "insert_data_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, fineTuneJobResponse(task, job))
+}
+
+func ListFineTuningJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	tasks, err := model.GetUserPlatformTasks(c.GetInt("id"), constant.TaskPlatformFineTune, c.Query("after"), limit+1)
+	if err != nil {
+		respondOpenAIError(c, http.StatusInternalServerError, "query_data_error", err.Error())
+		return
+	}
+	resp := dto.FineTuningJobList{
+		Object: "list",
+		Data:   make([]dto.FineTuningJob, 0, len(tasks)),
+	}
+	if len(tasks) > limit {
+		resp.HasMore = true
+		tasks = tasks[:limit]
+	}
+	for _, task := range tasks {
+		var job dto.FineTuningJob
+		if err := task.GetData(&job); err != nil {
+			continue
+		}
+		resp.Data = append(resp.Data, fineTuneJobResponse(task, &job))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func RetrieveFineTuningJob(c *gin.Context) {
+	task, ok := getUserFineTuneTask(c)
+	if !ok {
+		return
+	}
+	var job *dto.FineTuningJob
+	if channel, err := model.GetChannelById(task.ChannelId, true); err == nil {
+		job, err = service.RetrieveChannelFineTuningJob(channel, task.Properties.KeyIndex, task.TaskID)
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("retrieve upstream fine-tuning job %s failed: %s", task.TaskID, err.Error()))
+		}
+	}
+	// 上游不可用时返回最近一次轮询到的状态，状态流转与计费只由轮询任务处理
+	if job == nil {
+		job = &dto.FineTuningJob{}
+		if err := task.GetData(job); err != nil {
+			respondOpenAIError(c, http.StatusInternalServerError, "query_data_error", err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, fineTuneJobResponse(task, job))
+}
+
+func CancelFineTuningJob(c *gin.Context) {
+	task, ok := getUserFineTuneTask(c)
+	if !ok {
+		return
+	}
+	channel, ok := getFineTuneTaskChannel(c, task)
+	if !ok {
+		return
+	}
+	job, err := service.CancelChannelFineTuningJob(channel, task.Properties.KeyIndex, task.TaskID)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadGateway, "upstream_fine_tune_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, fineTuneJobResponse(task, job))
+}
+
+func ListFineTuningEvents(c *gin.Context) {
+	task, ok := getUserFineTuneTask(c)
+	if !ok {
+		return
+	}
+	channel, ok := getFineTuneTaskChannel(c, task)
+	if !ok {
+		return
+	}
+	body, err := service.ListChannelFineTuningEvents(channel, task.Properties.KeyIndex, task.TaskID, c.Request.URL.RawQuery)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadGateway, "upstream_fine_tune_error", err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+func UpdateFineTuneTaskAll(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) error {
+	for channelId, taskIds := range taskChannelM {
+		if err := updateFineTuneTaskAll(ctx, channelId, taskIds, taskM); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新微调任务失败: %v", channelId, err))
+		}
+	}
+	return nil
+}
+
+func updateFineTuneTaskAll(ctx context.Context, channelId int, taskIds []string, taskM map[string]*model.Task) error {
+	logger.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的微调任务有: %d", channelId, len(taskIds)))
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		errUpdate := model.TaskBulkUpdate(taskIds, map[string]any{
+			"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
+			"status":      "FAILURE",
+			"progress":    "100%",
+		})
+		if errUpdate != nil {
+			common.SysLog(fmt.Sprintf("UpdateFineTuneTask error: %v", errUpdate))
+		}
+		return fmt.Errorf("CacheGetChannel failed: %w", err)
+	}
+	for _, taskId := range taskIds {
+		task := taskM[taskId]
+		if task == nil {
+			continue
+		}
+		job, err := service.RetrieveChannelFineTuningJob(channel, task.Properties.KeyIndex, taskId)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("Failed to update fine-tuning job %s: %s", taskId, err.Error()))
+			continue
+		}
+		updateFineTuneSingleTask(ctx, task, job)
+	}
+	return nil
+}
+
+func updateFineTuneSingleTask(ctx context.Context, task *model.Task, job *dto.FineTuningJob) {
+	now := time.Now().Unix()
+	task.SetData(job)
+	task.Status, task.Progress = fineTuneTaskStatus(job.Status)
+	switch task.Status {
+	case model.TaskStatusInProgress:
+		if task.StartTime == 0 {
+			task.StartTime = now
+		}
+	case model.TaskStatusSuccess:
+		task.FinishTime = now
+		if job.TrainedTokens != nil && *job.TrainedTokens > 0 {
+			task.Quota = settleFineTuneTask(ctx, task, *job.TrainedTokens)
+		}
+	case model.TaskStatusFailure:
+		task.FinishTime = now
+		if job.Error != nil {
+			task.FailReason = job.Error.Message
+		} else {
+			task.FailReason = job.Status
+		}
+		logger.LogInfo(ctx, fmt.Sprintf("Fine-tuning job %s %s: %s", task.TaskID, job.Status, task.FailReason))
+	}
+	if err := task.Update(); err != nil {
+		common.SysLog("UpdateFineTuneTask task error: " + err.Error())
+	}
+}
+
+// settleFineTuneTask 按训练 token 数扣费并记录消费日志，返回实际扣除的额度
+func settleFineTuneTask(ctx context.Context, task *model.Task, trainedTokens int) int {
+	modelName := task.Properties.Input
+	price, _ := ratio_setting.GetFineTuneTrainingPrice(modelName)
+	groupRatio := ratio_setting.GetGroupRatio(task.Properties.Group)
+	ratio := groupRatio
+	userGroup, _ := model.GetUserGroup(task.UserId, false)
+	userGroupRatio, hasUserGroupRatio := ratio_setting.GetGroupGroupRatio(userGroup, task.Properties.Group)
+	if hasUserGroupRatio {
+		ratio = userGroupRatio
+	}
+	quota := int(float64(trainedTokens) / 1000000 * price * ratio * common.QuotaPerUnit)
+	if quota <= 0 {
+		return 0
+	}
+
+	info := &relaycommon.RelayInfo{
+		UserId:     task.UserId,
+		TokenId:    task.Properties.TokenId,
+		UsingGroup: task.Properties.Group,
+		UserGroup:  userGroup,
+		OrgId:      task.Properties.OrgId,
+	}
+	token, err := model.GetTokenById(task.Properties.TokenId)
+	if err != nil {
+		// 令牌已删除时只扣用户额度
+		info.IsPlayground = true
+	} else {
+		info.TokenKey = token.Key
+	}
+	if err = service.PostConsumeQuota(info, quota, 0, false); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("fine-tuning job %s consume quota error: %s", task.TaskID, err.Error()))
+		return 0
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = &http.Request{Header: make(http.Header)}
+	if userCache, err := model.GetUserCache(task.UserId); err == nil {
+		c.Set("username", userCache.Username)
+	}
+	tokenName := ""
+	if token != nil {
+		tokenName = token.Name
+	}
+	other := map[string]interface{}{
+		"trained_tokens":          trainedTokens,
+		"fine_tune_price":         price,
+		"group_ratio":             groupRatio,
+		"fine_tune_job_id":        task.TaskID,
+		"fine_tune_submit_time":   task.SubmitTime,
+		"fine_tune_finished_time": task.FinishTime,
+	}
+	if hasUserGroupRatio {
+		other["user_group_ratio"] = userGroupRatio
+	}
+	model.RecordConsumeLog(c, task.UserId, model.RecordConsumeLogParams{
+		ChannelId:    task.ChannelId,
+		ModelName:    modelName,
+		TokenName:    tokenName,
+		PromptTokens: trainedTokens,
+		Quota:        quota,
+		Content:      fmt.Sprintf("微调训练 %d tokens，训练价格 $%.2f / 1M tokens，分组倍率 %.2f", trainedTokens, price, ratio),
+		TokenId:      task.Properties.TokenId,
+		Group:        task.Properties.Group,
+		OrgId:        task.Properties.OrgId,
+		Other:        other,
+	})
+	model.UpdateUserUsedQuotaAndRequestCount(task.UserId, quota)
+	model.UpdateChannelUsedQuota(task.ChannelId, quota)
+	return quota
+}
@@ -176,7 +176,10 @@ func UpdateMidjourneyTaskBulk() {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = model.RefundQuota(task.UserId, task.OrgId, task.Quota, model.QuotaLedgerSource{
+							Type:      model.QuotaLedgerTypeRefund,
+							SourceRef: task.MjId,
+						})
 						if err != nil {
 							logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 						}
@@ -163,6 +163,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "FineTuneTrainingPrice":
+		err = ratio_setting.UpdateFineTuneTrainingPriceByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "微调训练价格设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "ModelRequestRateLimitGroup":
 		err = setting.CheckModelRequestRateLimitGroup(option.Value.(string))
 		if err != nil {
@@ -0,0 +1,466 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validateOrganizationName(name string) error {
+	if name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > 64 {
+		return errors.New("组织名称过长")
+	}
+	return nil
+}
+
+// checkOrganizationTokenPermission 创建或修改组织令牌时检查组织状态与成员角色
+func checkOrganizationTokenPermission(orgId int, userId int) error {
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		return errors.New("组织不存在")
+	}
+	if org.Status != model.OrganizationStatusEnabled {
+		return errors.New("组织已被禁用")
+	}
+	member, err := model.GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return err
+	}
+	if !member.CanIssueTokens() {
+		return errors.New("该角色不能创建组织令牌")
+	}
+	return nil
+}
+
+// getRequestOrganizationMember 返回当前用户在路径中组织的成员信息，系统管理员视为组织所有者
+func getRequestOrganizationMember(c *gin.Context) (*model.OrganizationMember, error) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := model.GetOrganizationById(orgId); err != nil {
+		return nil, errors.New("组织不存在")
+	}
+	userId := c.GetInt("id")
+	member, err := model.GetOrganizationMember(orgId, userId)
+	if err != nil && c.GetInt("role") >= common.RoleAdminUser {
+		return &model.OrganizationMember{OrgId: orgId, UserId: userId, Role: model.OrganizationRoleOwner}, nil
+	}
+	return member, err
+}
+
+// canManageMemberRole 所有者可以管理所有角色，管理员只能管理普通成员与账单成员
+func canManageMemberRole(operator *model.OrganizationMember, role string) bool {
+	if operator.Role == model.OrganizationRoleOwner {
+		return true
+	}
+	return operator.CanManageMembers() && (role == model.OrganizationRoleMember || role == model.OrganizationRoleBilling)
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type createOrganizationRequest struct {
+	Name    string `json:"name"`
+	Quota   int    `json:"quota"`
+	OwnerId int    `json:"owner_id"`
+}
+
+// CreateOrganization 管理员创建组织并指定所有者
+func CreateOrganization(c *gin.Context) {
+	var req createOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateOrganizationName(req.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Quota < 0 {
+		common.ApiErrorMsg(c, "额度不能为负数")
+		return
+	}
+	if req.OwnerId == 0 {
+		req.OwnerId = c.GetInt("id")
+	}
+	if _, err := model.GetUserById(req.OwnerId, false); err != nil {
+		common.ApiErrorMsg(c, "所有者用户不存在")
+		return
+	}
+	org := &model.Organization{Name: req.Name}
+	if err := model.CreateOrganization(org, req.OwnerId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Quota > 0 {
+		if err := model.AdjustOrganizationQuota(org.Id, req.Quota, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		org.Quota = req.Quota
+	}
+	common.ApiSuccess(c, org)
+}
+
+// UpdateOrganization 管理员修改组织名称、状态与额度，status_only 时只修改状态
+func UpdateOrganization(c *gin.Context) {
+	statusOnly := c.Query("status_only")
+	org := model.Organization{}
+	if err := c.ShouldBindJSON(&org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanOrg, err := model.GetOrganizationById(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if org.Status != model.OrganizationStatusEnabled && org.Status != model.OrganizationStatusDisabled {
+		common.ApiErrorMsg(c, "无效的组织状态")
+		return
+	}
+	cleanOrg.Status = org.Status
+	if statusOnly == "" {
+		if err := validateOrganizationName(org.Name); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if org.Quota < 0 {
+			common.ApiErrorMsg(c, "额度不能为负数")
+			return
+		}
+		cleanOrg.Name = org.Name
+	}
+	if err := cleanOrg.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if statusOnly == "" && org.Quota != cleanOrg.Quota {
+		if err := model.AdjustOrganizationQuota(cleanOrg.Id, org.Quota, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		cleanOrg.Quota = org.Quota
+	}
+	common.ApiSuccess(c, cleanOrg)
+}
+
+func DeleteOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteOrganization(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+// CreateSelfOrganization 用户创建组织并成为所有者，组织额度需由成员转入
+func CreateSelfOrganization(c *gin.Context) {
+	var req createOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateOrganizationName(req.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org := &model.Organization{Name: req.Name}
+	if err := model.CreateOrganization(org, c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	operator, err := getRequestOrganizationMember(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	members, err := model.GetOrganizationMembers(operator.OrgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+type organizationMemberRequest struct {
+	UserId     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	operator, err := getRequestOrganizationMember(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if !model.IsValidOrganizationRole(req.Role) {
+		common.ApiErrorMsg(c, "无效的组织角色")
+		return
+	}
+	if !canManageMemberRole(operator, req.Role) {
+		common.ApiErrorMsg(c, "无权添加该角色的成员")
+		return
+	}
+	if req.QuotaLimit < 0 {
+		common.ApiErrorMsg(c, "额度上限不能为负数")
+		return
+	}
+	if req.UserId == 0 {
+		req.UserId, err = model.GetUserIdByUsername(req.Username)
+		if err != nil {
+			common.ApiErrorMsg(c, "用户不存在")
+			return
+		}
+	} else if _, err := model.GetUserById(req.UserId, false); err != nil {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+	if _, err := model.GetOrganizationMember(operator.OrgId, req.UserId); err == nil {
+		common.ApiErrorMsg(c, "该用户已是组织成员")
+		return
+	}
+	member := &model.OrganizationMember{
+		OrgId:      operator.OrgId,
+		UserId:     req.UserId,
+		Role:       req.Role,
+		QuotaLimit: req.QuotaLimit,
+	}
+	if err := member.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// UpdateOrganizationMember 修改成员角色与额度上限，组织至少保留一名所有者
+func UpdateOrganizationMember(c *gin.Context) {
+	operator, err := getRequestOrganizationMember(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !model.IsValidOrganizationRole(req.Role) {
+		common.ApiErrorMsg(c, "无效的组织角色")
+		return
+	}
+	if req.QuotaLimit < 0 {
+		common.ApiErrorMsg(c, "额度上限不能为负数")
+		return
+	}
+	member, err := model.GetOrganizationMember(operator.OrgId, req.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !canManageMemberRole(operator, member.Role) || !canManageMemberRole(operator, req.Role) {
+		common.ApiErrorMsg(c, "无权修改该成员")
+		return
+	}
+	if member.Role == model.OrganizationRoleOwner && req.Role != model.OrganizationRoleOwner {
+		owners, err := model.CountOrganizationOwners(operator.OrgId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if owners <= 1 {
+			common.ApiErrorMsg(c, "组织至少需要保留一名所有者")
+			return
+		}
+	}
+	member.Role = req.Role
+	member.QuotaLimit = req.QuotaLimit
+	if err := member.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以移除自己以退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	operator, err := getRequestOrganizationMember(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := model.GetOrganizationMember(operator.OrgId, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if userId != operator.UserId && !canManageMemberRole(operator, member.Role) {
+		common.ApiErrorMsg(c, "无权移除该成员")
+		return
+	}
+	if member.Role == model.OrganizationRoleOwner {
+		owners, err := model.CountOrganizationOwners(operator.OrgId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if owners <= 1 {
+			common.ApiErrorMsg(c, "组织至少需要保留一名所有者")
+			return
+		}
+	}
+	if err := model.RemoveOrganizationMember(operator.OrgId, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// TransferQuotaToOrganization 所有者与账单成员将自己的额度转入组织
+func TransferQuotaToOrganization(c *gin.Context) {
+	operator, err := getRequestOrganizationMember(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !operator.CanTransferQuota() {
+		common.ApiErrorMsg(c, "该角色不能向组织转入额度")
+		return
+	}
+	var req struct {
+		Quota int `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.TransferQuotaToOrganization(c.GetInt("id"), operator.OrgId, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func getBillingOrganizationMember(c *gin.Context) (*model.OrganizationMember, bool) {
+	operator, err := getRequestOrganizationMember(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	if !operator.CanViewBilling() {
+		common.ApiErrorMsg(c, "该角色不能查看组织用量")
+		return nil, false
+	}
+	return operator, true
+}
+
+func GetOrganizationLogs(c *gin.Context) {
+	operator, ok := getBillingOrganizationMember(c)
+	if !ok {
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(operator.OrgId, logType, startTimestamp, endTimestamp, c.Query("model_name"), c.Query("username"), c.Query("token_name"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetOrganizationQuotaDates(c *gin.Context) {
+	operator, ok := getBillingOrganizationMember(c)
+	if !ok {
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp-startTimestamp > 2592000 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "时间跨度不能超过 1 个月",
+		})
+		return
+	}
+	dates, err := model.GetQuotaDataByOrgId(operator.OrgId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, dates)
+}
+
+func GetOrganizationQuotaLedgers(c *gin.Context) {
+	operator, ok := getBillingOrganizationMember(c)
+	if !ok {
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	ledgerType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	ledgers, total, err := model.GetQuotaLedgers(0, operator.OrgId, tokenId, ledgerType, startTimestamp, endTimestamp, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(ledgers)
+	common.ApiSuccess(c, pageInfo)
+}
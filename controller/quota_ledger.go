@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllQuotaLedgers(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	orgId, _ := strconv.Atoi(c.Query("org_id"))
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	ledgerType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	ledgers, total, err := model.GetQuotaLedgers(userId, orgId, tokenId, ledgerType, startTimestamp, endTimestamp, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(ledgers)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetUserQuotaLedgers(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId := c.GetInt("id")
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	ledgerType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	ledgers, total, err := model.GetQuotaLedgers(userId, 0, tokenId, ledgerType, startTimestamp, endTimestamp, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(ledgers)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ReconcileQuota 立即核对账本并返回结果
+func ReconcileQuota(c *gin.Context) {
+	report, err := service.ReconcileQuota()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, report)
+}
+
+// GetQuotaReconcileReport 返回本节点最近一次核对的结果
+func GetQuotaReconcileReport(c *gin.Context) {
+	common.ApiSuccess(c, service.GetLastQuotaReconcileReport())
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"one-api/common"
+	"one-api/common/metrics"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
@@ -17,9 +18,10 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
-	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strings"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 
@@ -65,6 +67,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	requestId := c.GetString(common.RequestIdKey)
 	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
 	originalModel := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
+	common.SetContextKey(c, constant.ContextKeyRelayFormat, string(relayFormat))
 
 	var (
 		newAPIError *types.NewAPIError
@@ -112,15 +115,23 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	finalChannelId, finalInfo := 0, relayInfo
+	defer func() {
+		recordRelayMetrics(c, finalInfo, finalChannelId, originalModel, relayFormat, newAPIError)
+	}()
+
+	service.StartBodyCapture(c)
+	defer service.FinishBodyCapture(c)
+
 	meta := request.GetTokenCountMeta()
 
-	if setting.ShouldCheckPromptSensitive() {
-		contains, words := service.CheckSensitiveText(meta.CombineText)
-		if contains {
-			logger.LogWarn(c, fmt.Sprintf("user sensitive words detected: %s", strings.Join(words, ", ")))
-			newAPIError = types.NewError(err, types.ErrorCodeSensitiveWordsDetected)
-			return
-		}
+	redacted, moderationErr := service.ModerateRequest(c, relayInfo, request, meta.CombineText)
+	if moderationErr != nil {
+		newAPIError = moderationErr
+		return
+	}
+	if redacted {
+		meta = request.GetTokenCountMeta()
 	}
 
 	tokens, err := service.CountRequestToken(c, meta, relayInfo)
@@ -139,6 +150,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	// common.SetContextKey(c, constant.ContextKeyTokenCountMeta, meta)
 
+	newAPIError = service.AcquireTokenRateLimit(c, relayInfo, tokens)
+	if newAPIError != nil {
+		return
+	}
+	defer service.ReleaseTokenRateLimit(c)
+
 	newAPIError = service.PreConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
 	if newAPIError != nil {
 		return
@@ -151,6 +168,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	if relayFormat == types.RelayFormatOpenAI && relay.ServeResponseCache(c, relayInfo) {
+		return
+	}
+
 	for i := 0; i <= common.RetryTimes; i++ {
 		channel, err := getChannel(c, group, originalModel, i)
 		if err != nil {
@@ -160,29 +181,36 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 
 		addUsedChannel(c, channel.Id)
+		attemptStart := time.Now()
 		requestBody, _ := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
+		attempt := &relayAttempt{c: c, info: relayInfo, channel: channel}
+		if delay := hedgeDelay(c, relayInfo, relayFormat); delay > 0 {
+			attempt = relayWithHedge(c, relayInfo, relayFormat, channel, group, originalModel, delay)
+		} else {
+			attempt.err = relayByFormat(c, relayInfo, relayFormat)
 		}
+		newAPIError = attempt.err
+		finalChannelId, finalInfo = attempt.channel.Id, attempt.info
+		metrics.RecordUpstreamResponse(attempt.channel.Id, originalModel, relayInfo.UsingGroup, string(relayFormat), attemptStatusCode(attempt.err))
 
 		if newAPIError == nil {
+			recordChannelSuccess(attempt.info, attempt.channel.Id, originalModel, attemptStart)
+			model.RecordCircuitBreakerSuccess(attempt.channel.Id, channelKeyIndex(attempt.c, common.GetContextKeyBool(attempt.c, constant.ContextKeyChannelIsMultiKey)))
 			return
 		}
 
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		if relayInfo.IsReplay {
+			// 回放固定使用指定渠道，失败时不重试，也不禁用渠道
+			break
+		}
+		processAttemptError(attempt)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-i) {
 			break
 		}
+		metrics.RecordRetry(attempt.channel.Id, originalModel, relayInfo.UsingGroup, string(relayFormat))
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -199,6 +227,28 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+func relayByFormat(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) *types.NewAPIError {
+	restore, scrubErr := service.ScrubRequestPII(c, relayInfo)
+	if scrubErr != nil {
+		return scrubErr
+	}
+	if restore != nil {
+		// 响应缓存按原始请求计算缓存键，替换过敏感信息的响应不写入缓存
+		relayInfo.ResponseCacheKey = ""
+		defer restore()
+	}
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		return relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		return relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		return geminiRelayHandler(c, relayInfo)
+	default:
+		return relayHandler(c, relayInfo)
+	}
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -275,11 +325,81 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return true
 }
 
+// attemptStatusCode 返回一次尝试用于统计的状态码，成功时为 200
+func attemptStatusCode(err *types.NewAPIError) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return err.StatusCode
+}
+
+// recordRelayMetrics 按最终使用的渠道记录请求结果与耗时，channelId 为 0 表示未经过渠道
+func recordRelayMetrics(c *gin.Context, info *relaycommon.RelayInfo, channelId int, modelName string, relayFormat types.RelayFormat, err *types.NewAPIError) {
+	statusCode := c.Writer.Status()
+	if err != nil {
+		statusCode = err.StatusCode
+	}
+	var ttft time.Duration
+	if info.IsStream && info.HasSendResponse() {
+		ttft = info.FirstResponseTime.Sub(info.StartTime)
+	}
+	metrics.RecordRelayRequest(channelId, modelName, info.UsingGroup, string(relayFormat), statusCode, time.Since(info.StartTime), ttft)
+}
+
+// recordChannelSuccess 记录本次尝试的首字时间与总耗时，供自适应选择渠道使用
+func recordChannelSuccess(info *relaycommon.RelayInfo, channelId int, modelName string, attemptStart time.Time) {
+	var ttft time.Duration
+	if info.IsStream && info.HasSendResponse() && info.FirstResponseTime.After(attemptStart) {
+		ttft = info.FirstResponseTime.Sub(attemptStart)
+	}
+	model.RecordChannelSuccess(channelId, modelName, ttft, time.Since(attemptStart))
+}
+
+// channelKeyIndex 单密钥渠道固定为 0，上下文中的索引可能是上一次重试留下的
+func channelKeyIndex(c *gin.Context, isMultiKey bool) int {
+	if !isMultiKey {
+		return 0
+	}
+	return common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+}
+
+// isChannelHealthError 只统计由渠道自身导致的错误，请求参数错误等不计入渠道失败
+func isChannelHealthError(err *types.NewAPIError) bool {
+	if types.IsChannelError(err) {
+		return true
+	}
+	if types.IsSkipRetryError(err) {
+		return false
+	}
+	switch err.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return err.StatusCode/100 == 5
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
+	if isChannelHealthError(err) {
+		model.RecordChannelFailure(channelError.ChannelId, c.GetString("original_model"))
+	}
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
-	if service.ShouldDisableChannel(channelError.ChannelId, err) && channelError.AutoBan {
+	if service.IsModelSpecificError(err) {
+		if channelError.AutoBan {
+			group := c.GetString("auto_group")
+			if group == "" {
+				group = common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+			}
+			modelName := c.GetString("original_model")
+			reason := err.MaskSensitiveError()
+			gopool.Go(func() {
+				service.DisableAbility(channelError, group, modelName, reason)
+			})
+		}
+	} else if operation_setting.GetCircuitBreakerSetting().Enabled && service.IsTransientChannelError(channelError.ChannelType, err) {
+		model.RecordCircuitBreakerFailure(channelError.ChannelId, channelKeyIndex(c, channelError.IsMultiKey), err.MaskSensitiveError())
+	} else if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
 		gopool.Go(func() {
 			service.DisableChannel(channelError, err.Error())
 		})
@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/logger"
+	"one-api/middleware"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// relayAttempt 一次发往某个渠道的请求，对冲时主请求与对冲请求各占一个
+type relayAttempt struct {
+	c       *gin.Context
+	info    *relaycommon.RelayInfo
+	channel *model.Channel
+	err     *types.NewAPIError
+	index   int
+	ctx     context.Context
+}
+
+func processAttemptError(a *relayAttempt) {
+	processChannelError(a.c, *types.NewChannelError(a.channel.Id, a.channel.Type, a.channel.Name, a.channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(a.c, constant.ContextKeyChannelKey), a.channel.GetAutoBan()), a.err)
+}
+
+// hedgeDelay 返回触发对冲的首字节超时时间，0 表示不对冲；只对冲文本生成类请求
+func hedgeDelay(c *gin.Context, info *relaycommon.RelayInfo, relayFormat types.RelayFormat) time.Duration {
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return 0
+	}
+	switch relayFormat {
+	case types.RelayFormatClaude, types.RelayFormatOpenAIResponses:
+	case types.RelayFormatGemini:
+		if strings.Contains(c.Request.URL.Path, "embed") {
+			return 0
+		}
+	case types.RelayFormatOpenAI:
+		if info.RelayMode != relayconstant.RelayModeChatCompletions && info.RelayMode != relayconstant.RelayModeCompletions {
+			return 0
+		}
+	default:
+		return 0
+	}
+	return operation_setting.GetHedgeDelay(common.GetContextKeyString(c, constant.ContextKeyUsingGroup))
+}
+
+// hedgeGroup 记录各请求的取消函数，一方胜出后取消其余请求
+type hedgeGroup struct {
+	state   *relaycommon.HedgeState
+	mu      sync.Mutex
+	cancels map[int]context.CancelFunc
+}
+
+func (g *hedgeGroup) register(index int, cancel context.CancelFunc) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cancels[index] = cancel
+	if winner := g.state.Winner(); winner >= 0 && winner != index {
+		cancel()
+	}
+}
+
+func (g *hedgeGroup) cancelOthers(winner int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for index, cancel := range g.cancels {
+		if index != winner {
+			cancel()
+		}
+	}
+}
+
+var hedgePingData = []byte(": PING\n\n")
+
+// hedgeWriter 在决出胜者前缓存响应头并丢弃输出，第一个写出数据的请求胜出并接管真实的 writer
+type hedgeWriter struct {
+	gin.ResponseWriter
+	group        *hedgeGroup
+	index        int
+	header       http.Header
+	status       int
+	size         int
+	headerSynced bool
+}
+
+func newHedgeWriter(w gin.ResponseWriter, group *hedgeGroup, index int) *hedgeWriter {
+	return &hedgeWriter{
+		ResponseWriter: w,
+		group:          group,
+		index:          index,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *hedgeWriter) isWinner() bool {
+	return w.group.state.Winner() == w.index
+}
+
+func (w *hedgeWriter) claim() bool {
+	if !w.group.state.Claim(w.index) {
+		return false
+	}
+	if !w.headerSynced {
+		w.headerSynced = true
+		dst := w.ResponseWriter.Header()
+		for key, values := range w.header {
+			dst[key] = values
+		}
+		w.group.cancelOthers(w.index)
+	}
+	return true
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.headerSynced {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.claim() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	// 保活 ping 不是上游返回的数据，不能据此决出胜者
+	if w.group.state.Winner() < 0 && bytes.Equal(data, hedgePingData) {
+		return len(data), nil
+	}
+	if w.claim() {
+		return w.ResponseWriter.Write(data)
+	}
+	w.size += len(data)
+	return len(data), nil
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.isWinner() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.headerSynced {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.headerSynced {
+		return w.ResponseWriter.Size()
+	}
+	return w.size
+}
+
+func (w *hedgeWriter) Written() bool {
+	if w.headerSynced {
+		return w.ResponseWriter.Written()
+	}
+	return w.size > 0
+}
+
+func runRelayAttempt(a *relayAttempt, relayFormat types.RelayFormat) (newAPIError *types.NewAPIError) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogError(a.c, fmt.Sprintf("hedged relay attempt panic: %v", r))
+			newAPIError = types.NewError(fmt.Errorf("relay panic: %v", r), types.ErrorCodeDoRequestFailed)
+		}
+	}()
+	return relayByFormat(a.c, a.info, relayFormat)
+}
+
+// startHedgeAttempt 选择与主请求不同的渠道发起对冲请求，没有可用渠道时返回 nil
+func startHedgeAttempt(template *gin.Context, realWriter gin.ResponseWriter, realRequest *http.Request, info *relaycommon.RelayInfo, group *hedgeGroup, primaryChannelId int, usingGroup string, originalModel string) *relayAttempt {
+	hc := template.Copy()
+	// 各请求需要在自己的 writer 上设置 SSE 响应头
+	delete(hc.Keys, "event_stream_headers_set")
+	var channel *model.Channel
+	for i := 0; i < 3; i++ {
+		candidate, _, err := model.CacheGetRandomSatisfiedChannel(hc, usingGroup, originalModel, 0)
+		if err != nil || candidate == nil {
+			return nil
+		}
+		if candidate.Id != primaryChannelId {
+			channel = candidate
+			break
+		}
+	}
+	if channel == nil {
+		return nil
+	}
+	if newAPIError := middleware.SetupContextForSelectedChannel(hc, channel, originalModel); newAPIError != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(realRequest.Context())
+	hc.Request = realRequest.Clone(ctx)
+	requestBody, _ := common.GetRequestBody(hc)
+	hc.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	hc.Writer = newHedgeWriter(realWriter, group, 1)
+	group.register(1, cancel)
+	group.state.Trigger(channel.Id)
+	return &relayAttempt{c: hc, info: info.CloneForHedge(1), channel: channel, index: 1, ctx: ctx}
+}
+
+// relayWithHedge 主请求超过 delay 仍未输出首字节时，向另一个渠道发起相同请求，
+// 先输出的一方胜出并计费，另一方被取消。返回最终结果对应的请求
+func relayWithHedge(c *gin.Context, info *relaycommon.RelayInfo, relayFormat types.RelayFormat, channel *model.Channel, usingGroup string, originalModel string, delay time.Duration) *relayAttempt {
+	state := relaycommon.NewHedgeState(channel.Id, delay)
+	group := &hedgeGroup{state: state, cancels: make(map[int]context.CancelFunc)}
+	info.HedgeState = state
+	info.HedgeIndex = 0
+
+	// 主请求开始后会并发修改 c.Keys，需要提前复制一份作为对冲请求的模板
+	template := c.Copy()
+	realWriter := c.Writer
+	realRequest := c.Request
+	ctx, cancel := context.WithCancel(realRequest.Context())
+	c.Request = realRequest.WithContext(ctx)
+	c.Writer = newHedgeWriter(realWriter, group, 0)
+	group.register(0, cancel)
+	defer func() {
+		cancel()
+		c.Writer = realWriter
+		c.Request = realRequest
+		info.HedgeState = nil
+	}()
+
+	primary := &relayAttempt{c: c, info: info, channel: channel, index: 0, ctx: ctx}
+	results := make(chan *relayAttempt, 2)
+	go func() {
+		primary.err = runRelayAttempt(primary, relayFormat)
+		results <- primary
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case a := <-results:
+		return a
+	case <-timer.C:
+	}
+	if state.Winner() >= 0 {
+		return <-results
+	}
+	hedge := startHedgeAttempt(template, realWriter, realRequest, info, group, channel.Id, usingGroup, originalModel)
+	if hedge == nil {
+		return <-results
+	}
+	addUsedChannel(c, hedge.channel.Id)
+	logger.LogInfo(c, fmt.Sprintf("channel #%d no first byte after %dms, hedging to channel #%d", channel.Id, delay.Milliseconds(), hedge.channel.Id))
+	go func() {
+		hedge.err = runRelayAttempt(hedge, relayFormat)
+		results <- hedge
+	}()
+
+	first := <-results
+	if first.err == nil {
+		state.Claim(first.index)
+	}
+	if state.Winner() == first.index {
+		group.cancelOthers(first.index)
+		if first.index != 0 {
+			// 主请求使用的是 gin 的原始 context，必须等它退出后才能返回
+			<-results
+		}
+		return first
+	}
+	// 先结束的一方失败且未输出任何内容，由另一方决定最终结果；被取消导致的失败不计入渠道错误
+	if first.err != nil && first.ctx.Err() == nil {
+		processAttemptError(first)
+	}
+	return <-results
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"one-api/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReplayRelayRequest struct {
+	// 三选一：按 request_id 或日志 id 回放记录的请求，或直接提供请求体
+	RequestId   string `json:"request_id"`
+	LogId       int    `json:"log_id"`
+	Body        string `json:"body"`
+	RelayFormat string `json:"relay_format"`
+	// 为空时使用记录中的路径或按 relay_format 推断
+	Path      string `json:"path"`
+	Model     string `json:"model"`
+	ChannelId int    `json:"channel_id"`
+}
+
+// replayDefaultPaths 未提供路径时各格式使用的请求路径
+var replayDefaultPaths = map[types.RelayFormat]string{
+	types.RelayFormatOpenAI:          "/v1/chat/completions",
+	types.RelayFormatClaude:          "/v1/messages",
+	types.RelayFormatOpenAIResponses: "/v1/responses",
+	types.RelayFormatOpenAIImage:     "/v1/images/generations",
+	types.RelayFormatOpenAIAudio:     "/v1/audio/speech",
+	types.RelayFormatEmbedding:       "/v1/embeddings",
+	types.RelayFormatRerank:          "/v1/rerank",
+}
+
+// replayRecorder 旧渠道的流式输出使用 c.Stream，需要 CloseNotify
+type replayRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *replayRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+// resolveReplayRequest 补全回放需要的请求体、格式、路径与模型
+func resolveReplayRequest(req *ReplayRelayRequest) error {
+	if req.LogId != 0 && req.RequestId == "" {
+		log, err := model.GetLogById(req.LogId)
+		if err != nil {
+			return err
+		}
+		other, _ := common.StrToMap(log.Other)
+		requestId, _ := other["request_id"].(string)
+		if requestId == "" {
+			return errors.New("该日志没有记录请求内容，无法回放")
+		}
+		req.RequestId = requestId
+	}
+	if req.RequestId != "" {
+		capture, err := model.GetRequestCaptureByRequestId(req.RequestId)
+		if err != nil {
+			return fmt.Errorf("未找到请求 %s 的记录内容", req.RequestId)
+		}
+		if capture.Truncated {
+			return errors.New("记录的请求内容已被截断，无法回放")
+		}
+		req.Body = common.GetStringIfEmpty(req.Body, capture.ClientRequest)
+		req.RelayFormat = common.GetStringIfEmpty(req.RelayFormat, capture.RelayFormat)
+		req.Path = common.GetStringIfEmpty(req.Path, capture.RequestPath)
+		req.Model = common.GetStringIfEmpty(req.Model, capture.ModelName)
+	}
+	if req.Body == "" {
+		return errors.New("请求体不能为空")
+	}
+	if req.RelayFormat == "" {
+		req.RelayFormat = string(types.RelayFormatOpenAI)
+	}
+	if req.Model == "" {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = common.UnmarshalJsonStr(req.Body, &body)
+		req.Model = body.Model
+	}
+	if req.Model == "" {
+		return errors.New("无法确定请求的模型")
+	}
+	if req.Path == "" {
+		if types.RelayFormat(req.RelayFormat) == types.RelayFormatGemini {
+			req.Path = fmt.Sprintf("/v1beta/models/%s:generateContent", req.Model)
+		} else if path, ok := replayDefaultPaths[types.RelayFormat(req.RelayFormat)]; ok {
+			req.Path = path
+		} else {
+			return fmt.Errorf("不支持回放 %s 格式的请求", req.RelayFormat)
+		}
+	}
+	return nil
+}
+
+// ReplayRequest 将记录的请求或提供的请求体固定发往指定渠道，走正常的转发流程但不计费，
+// 返回转换后的上游请求、上游原始响应与最终返回给客户端的响应，便于排查格式转换问题
+func ReplayRequest(c *gin.Context) {
+	var req ReplayRelayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := resolveReplayRequest(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	relayFormat := types.RelayFormat(req.RelayFormat)
+	if relayFormat == types.RelayFormatOpenAIRealtime {
+		common.ApiErrorMsg(c, "不支持回放 Realtime 请求")
+		return
+	}
+	channel, err := model.GetChannelById(req.ChannelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	userId := c.GetInt("id")
+	userCache, err := model.GetUserCache(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	w := &replayRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rc, _ := gin.CreateTestContext(w)
+	requestId := common.GetTimeString() + common.GetRandomString(8)
+	ctx := context.WithValue(c.Request.Context(), common.RequestIdKey, requestId)
+	rc.Request = httptest.NewRequest(http.MethodPost, req.Path, strings.NewReader(req.Body)).WithContext(ctx)
+	rc.Request.Header.Set("Content-Type", "application/json")
+	rc.Set(common.RequestIdKey, requestId)
+
+	userCache.WriteContext(rc)
+	common.SetContextKey(rc, constant.ContextKeyUserId, userId)
+	common.SetContextKey(rc, constant.ContextKeyUsingGroup, userCache.Group)
+	common.SetContextKey(rc, constant.ContextKeyTokenUnlimited, true)
+	common.SetContextKey(rc, constant.ContextKeyRequestStartTime, time.Now())
+	common.SetContextKey(rc, constant.ContextKeyTokenSpecificChannelId, strconv.Itoa(channel.Id))
+	common.SetContextKey(rc, constant.ContextKeyReplay, true)
+
+	if newAPIError := middleware.SetupContextForSelectedChannel(rc, channel, req.Model); newAPIError != nil {
+		common.ApiError(c, newAPIError)
+		return
+	}
+	Relay(rc, relayFormat)
+
+	data := gin.H{
+		"request_id":      requestId,
+		"channel_id":      channel.Id,
+		"model":           req.Model,
+		"path":            req.Path,
+		"relay_format":    req.RelayFormat,
+		"client_request":  req.Body,
+		"status_code":     w.Code,
+		"client_response": w.Body.String(),
+	}
+	if record := service.GetBodyCaptureRecord(rc); record != nil {
+		data["upstream_request"] = record.UpstreamRequest
+		data["upstream_response"] = record.UpstreamResponse
+		data["response_text"] = record.ResponseText
+		data["is_stream"] = record.IsStream
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
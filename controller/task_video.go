@@ -130,7 +130,10 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		logger.LogInfo(ctx, fmt.Sprintf("Task %s failed: %s", task.TaskID, task.FailReason))
 		quota := task.Quota
 		if quota != 0 {
-			if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
+			if err := model.RefundQuota(task.UserId, task.Properties.OrgId, quota, model.QuotaLedgerSource{
+				Type:      model.QuotaLedgerTypeRefund,
+				SourceRef: task.TaskID,
+			}); err != nil {
 				logger.LogError(ctx, "Failed to increase user quota: "+err.Error())
 			}
 			logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, logger.LogQuota(quota))
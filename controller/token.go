@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"strings"
 
@@ -148,6 +149,26 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.RpmLimit < 0 || token.TpmLimit < 0 || token.MaxConcurrentStreams < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "令牌限流参数不能为负数",
+		})
+		return
+	}
+	if token.ModerationPolicy != "" && !operation_setting.IsValidModerationPolicy(token.ModerationPolicy) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的内容审核处理方式",
+		})
+		return
+	}
+	if token.OrgId != 0 {
+		if err := checkOrganizationTokenPermission(token.OrgId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -158,18 +179,25 @@ func AddToken(c *gin.Context) {
 		return
 	}
 	cleanToken := model.Token{
-		UserId:             c.GetInt("id"),
-		Name:               token.Name,
-		Key:                key,
-		CreatedTime:        common.GetTimestamp(),
-		AccessedTime:       common.GetTimestamp(),
-		ExpiredTime:        token.ExpiredTime,
-		RemainQuota:        token.RemainQuota,
-		UnlimitedQuota:     token.UnlimitedQuota,
-		ModelLimitsEnabled: token.ModelLimitsEnabled,
-		ModelLimits:        token.ModelLimits,
-		AllowIps:           token.AllowIps,
-		Group:              token.Group,
+		UserId:               c.GetInt("id"),
+		Name:                 token.Name,
+		Key:                  key,
+		CreatedTime:          common.GetTimestamp(),
+		AccessedTime:         common.GetTimestamp(),
+		ExpiredTime:          token.ExpiredTime,
+		RemainQuota:          token.RemainQuota,
+		UnlimitedQuota:       token.UnlimitedQuota,
+		ModelLimitsEnabled:   token.ModelLimitsEnabled,
+		ModelLimits:          token.ModelLimits,
+		AllowIps:             token.AllowIps,
+		Group:                token.Group,
+		ResponseCache:        token.ResponseCache,
+		RpmLimit:             token.RpmLimit,
+		TpmLimit:             token.TpmLimit,
+		MaxConcurrentStreams: token.MaxConcurrentStreams,
+		ModerationPolicy:     token.ModerationPolicy,
+		PIIScrub:             token.PIIScrub,
+		OrgId:                token.OrgId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -214,6 +242,26 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.RpmLimit < 0 || token.TpmLimit < 0 || token.MaxConcurrentStreams < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "令牌限流参数不能为负数",
+		})
+		return
+	}
+	if token.ModerationPolicy != "" && !operation_setting.IsValidModerationPolicy(token.ModerationPolicy) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的内容审核处理方式",
+		})
+		return
+	}
+	if token.OrgId != 0 {
+		if err := checkOrganizationTokenPermission(token.OrgId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -247,6 +295,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.MaxConcurrentStreams = token.MaxConcurrentStreams
+		cleanToken.ModerationPolicy = token.ModerationPolicy
+		cleanToken.PIIScrub = token.PIIScrub
+		cleanToken.OrgId = token.OrgId
 	}
 	err = cleanToken.Update()
 	if err != nil {
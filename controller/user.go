@@ -717,7 +717,7 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	updatePassword := updatedUser.Password != ""
-	if err := updatedUser.Edit(updatePassword); err != nil {
+	if err := updatedUser.Edit(updatePassword, c.GetInt("id")); err != nil {
 		common.ApiError(c, err)
 		return
 	}
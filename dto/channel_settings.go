@@ -7,6 +7,12 @@ type ChannelSettings struct {
 	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
 	SystemPrompt           string `json:"system_prompt,omitempty"`
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	// LowBalanceThreshold 余额低于该值（美元）时通知管理员，0 表示不检查
+	LowBalanceThreshold float64 `json:"low_balance_threshold,omitempty"`
+	// LowBalanceWeight 余额低于阈值时将渠道权重调整为该值，余额恢复后还原，为空时不调整
+	LowBalanceWeight *uint `json:"low_balance_weight,omitempty"`
+	// PIIScrubEnabled 转发到该渠道前替换请求中的敏感信息，需同时打开全局开关
+	PIIScrubEnabled bool `json:"pii_scrub_enabled,omitempty"`
 }
 
 type VertexKeyType string
@@ -20,6 +26,8 @@ type ChannelOtherSettings struct {
 	AzureResponsesVersion string        `json:"azure_responses_version,omitempty"`
 	VertexKeyType         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
 	OpenRouterEnterprise  *bool         `json:"openrouter_enterprise,omitempty"`
+	// AwsInferenceProfiles 模型名到 Bedrock 推理配置文件 ID 或 ARN 的映射，命中时直接作为 modelId 调用
+	AwsInferenceProfiles map[string]string `json:"aws_inference_profiles,omitempty"`
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
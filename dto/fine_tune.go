@@ -0,0 +1,36 @@
+package dto
+
+type FineTuningJobError struct {
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+	Param   *string `json:"param"`
+}
+
+type FineTuningJob struct {
+	Id              string              `json:"id"`
+	Object          string              `json:"object"`
+	CreatedAt       int64               `json:"created_at"`
+	FinishedAt      *int64              `json:"finished_at"`
+	Model           string              `json:"model"`
+	FineTunedModel  *string             `json:"fine_tuned_model"`
+	OrganizationId  string              `json:"organization_id,omitempty"`
+	Status          string              `json:"status"`
+	TrainingFile    string              `json:"training_file"`
+	ValidationFile  *string             `json:"validation_file"`
+	ResultFiles     []string            `json:"result_files"`
+	TrainedTokens   *int                `json:"trained_tokens"`
+	Error           *FineTuningJobError `json:"error"`
+	Hyperparameters any                 `json:"hyperparameters,omitempty"`
+	Method          any                 `json:"method,omitempty"`
+	Integrations    any                 `json:"integrations,omitempty"`
+	Seed            *int                `json:"seed,omitempty"`
+	EstimatedFinish *int64              `json:"estimated_finish,omitempty"`
+	Suffix          *string             `json:"user_provided_suffix,omitempty"`
+	Metadata        map[string]string   `json:"metadata,omitempty"`
+}
+
+type FineTuningJobList struct {
+	Object  string          `json:"object"`
+	Data    []FineTuningJob `json:"data"`
+	HasMore bool            `json:"has_more"`
+}
@@ -779,7 +779,7 @@ type OpenAIResponsesRequest struct {
 	Store              json.RawMessage `json:"store,omitempty"`
 	PromptCacheKey     json.RawMessage `json:"prompt_cache_key,omitempty"`
 	Stream             bool            `json:"stream,omitempty"`
-	Temperature        float64         `json:"temperature,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
 	Text               json.RawMessage `json:"text,omitempty"`
 	ToolChoice         json.RawMessage `json:"tool_choice,omitempty"`
 	Tools              json.RawMessage `json:"tools,omitempty"` // 需要处理的参数很少，MCP 参数太多不确定，所以用 map
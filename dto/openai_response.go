@@ -320,11 +320,17 @@ type IncompleteDetails struct {
 type ResponsesOutput struct {
 	Type    string                   `json:"type"`
 	ID      string                   `json:"id"`
-	Status  string                   `json:"status"`
-	Role    string                   `json:"role"`
-	Content []ResponsesOutputContent `json:"content"`
-	Quality string                   `json:"quality"`
-	Size    string                   `json:"size"`
+	Status  string                   `json:"status,omitempty"`
+	Role    string                   `json:"role,omitempty"`
+	Content []ResponsesOutputContent `json:"content,omitempty"`
+	Quality string                   `json:"quality,omitempty"`
+	Size    string                   `json:"size,omitempty"`
+	// function_call
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// reasoning
+	Summary []ResponsesOutputContent `json:"summary,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -353,6 +359,15 @@ type ResponsesStreamResponse struct {
 	Response *OpenAIResponsesResponse `json:"response,omitempty"`
 	Delta    string                   `json:"delta,omitempty"`
 	Item     *ResponsesOutput         `json:"item,omitempty"`
+	// 以下字段仅在由 Chat Completions 转换生成事件时使用
+	SequenceNumber int                     `json:"sequence_number"`
+	OutputIndex    *int                    `json:"output_index,omitempty"`
+	ItemID         string                  `json:"item_id,omitempty"`
+	ContentIndex   *int                    `json:"content_index,omitempty"`
+	SummaryIndex   *int                    `json:"summary_index,omitempty"`
+	Part           *ResponsesOutputContent `json:"part,omitempty"`
+	Text           *string                 `json:"text,omitempty"`
+	Arguments      *string                 `json:"arguments,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
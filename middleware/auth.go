@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"one-api/common"
@@ -8,6 +9,7 @@ import (
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
+	"os"
 	"strconv"
 	"strings"
 
@@ -171,6 +173,24 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// MetricsAuth 设置了 METRICS_TOKEN 环境变量时，抓取 /metrics 需要携带 Bearer Token，
+// 未设置时只允许管理员访问，指标中包含渠道名称与错误率等信息
+func MetricsAuth() func(c *gin.Context) {
+	metricsToken := os.Getenv("METRICS_TOKEN")
+	return func(c *gin.Context) {
+		if metricsToken == "" {
+			authHelper(c, common.RoleAdminUser)
+			return
+		}
+		auth := c.Request.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+metricsToken)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
 func WssAuth(c *gin.Context) {
 
 }
@@ -307,6 +327,13 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 		c.Set("token_model_limit_enabled", false)
 	}
 	c.Set("token_group", token.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCache)
+	common.SetContextKey(c, constant.ContextKeyTokenRpmLimit, token.RpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenTpmLimit, token.TpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenMaxStreams, token.MaxConcurrentStreams)
+	common.SetContextKey(c, constant.ContextKeyTokenModerationPolicy, token.ModerationPolicy)
+	common.SetContextKey(c, constant.ContextKeyTokenPIIScrub, token.PIIScrub)
+	common.SetContextKey(c, constant.ContextKeyTokenOrgId, token.OrgId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"one-api/common/metrics"
 	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +14,12 @@ type HTTPStats struct {
 
 var globalStats = &HTTPStats{}
 
+func init() {
+	metrics.RegisterGaugeFunc("http_active_connections", "Relay requests currently being served.", func() float64 {
+		return float64(atomic.LoadInt64(&globalStats.activeConnections))
+	})
+}
+
 // StatsMiddleware 统计中间件
 func StatsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
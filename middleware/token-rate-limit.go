@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/common/limiter"
+	"one-api/constant"
+	"one-api/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTokenRequestRateLimit 检查令牌自身的 RPM 限制，与全局模型请求限流相互独立
+func checkTokenRequestRateLimit(c *gin.Context) bool {
+	rpm := common.GetContextKeyInt(c, constant.ContextKeyTokenRpmLimit)
+	if rpm <= 0 {
+		return true
+	}
+	rate := float64(rpm) / 60
+	key := fmt.Sprintf("rateLimit:rpm:token:%d", common.GetContextKeyInt(c, constant.ContextKeyTokenId))
+	allowed, remaining, err := limiter.TakeTokens(context.Background(), key, 1, int64(rpm), rate, false)
+	if err != nil {
+		common.SysLog("check token rpm limit failed: " + err.Error())
+		return true
+	}
+	remaining = max(remaining, 0)
+	c.Header("x-ratelimit-limit-requests", strconv.Itoa(rpm))
+	c.Header("x-ratelimit-remaining-requests", strconv.FormatInt(remaining, 10))
+	c.Header("x-ratelimit-reset-requests", (time.Duration(float64(int64(rpm)-remaining)/rate*1000) * time.Millisecond).String())
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil((1-float64(remaining))/rate))))
+		abortWithOpenAiMessage(c, http.StatusTooManyRequests, fmt.Sprintf("令牌已达到请求数限制：每分钟最多请求%d次", rpm))
+		return false
+	}
+	return true
+}
+
+// TokenRateLimit 令牌自身的 RPM 与 TPM 限制，需放在 TokenAuth 之后，所有转发路由都要经过，包括异步任务
+func TokenRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !checkTokenRequestRateLimit(c) {
+			return
+		}
+		if err := service.CheckTokenTPMLimit(c); err != nil {
+			abortWithOpenAiMessage(c, err.StatusCode, err.Error(), string(err.GetErrorCode()))
+			return
+		}
+		c.Next()
+	}
+}
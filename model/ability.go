@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"one-api/setting/operation_setting"
 	"strings"
 	"sync"
 
@@ -20,6 +21,9 @@ type Ability struct {
 	Priority  *int64  `json:"priority" gorm:"bigint;default:0;index"`
 	Weight    uint    `json:"weight" gorm:"default:0;index"`
 	Tag       *string `json:"tag" gorm:"index"`
+	// 单独禁用某个模型能力时记录原因与时间，整个渠道被禁用时不记录
+	DisabledReason string `json:"disabled_reason,omitempty" gorm:"type:text"`
+	DisabledTime   int64  `json:"disabled_time,omitempty" gorm:"bigint;default:0"`
 }
 
 type AbilityWithChannel struct {
@@ -118,8 +122,17 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	if err != nil {
 		return nil, err
 	}
+	abilities = filterCircuitAllowedAbilities(abilities)
 	channel := Channel{}
-	if len(abilities) > 0 {
+	if len(abilities) > 0 && operation_setting.IsChannelSelectAdaptive(group) {
+		channelIds := make([]int, len(abilities))
+		weights := make([]int, len(abilities))
+		for i, ability_ := range abilities {
+			channelIds[i] = ability_.ChannelId
+			weights[i] = int(ability_.Weight)
+		}
+		channel.Id = abilities[selectAdaptiveChannelIndex(channelIds, weights, model, 10)].ChannelId
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
@@ -142,6 +155,41 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	return &channel, err
 }
 
+// filterCircuitAllowedAbilities 未启用内存缓存时从数据库读取渠道信息，过滤掉处于熔断中的渠道，全部熔断时返回原列表
+func filterCircuitAllowedAbilities(abilities []Ability) []Ability {
+	if !isCircuitBreakerEnabled() || len(abilities) == 0 {
+		return abilities
+	}
+	channelIds := make([]int, len(abilities))
+	for i, ability := range abilities {
+		channelIds[i] = ability.ChannelId
+	}
+	var channels []*Channel
+	if err := DB.Select("id", "channel_info").Where("id IN ?", channelIds).Find(&channels).Error; err != nil {
+		common.SysLog("failed to load channels for circuit breaker: " + err.Error())
+		return abilities
+	}
+	blocked := make(map[int]bool)
+	for _, channel := range channels {
+		if !isChannelCircuitAllowed(channel) {
+			blocked[channel.Id] = true
+		}
+	}
+	if len(blocked) == 0 {
+		return abilities
+	}
+	allowed := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if !blocked[ability.ChannelId] {
+			allowed = append(allowed, ability)
+		}
+	}
+	if len(allowed) == 0 {
+		return abilities
+	}
+	return allowed
+}
+
 func (channel *Channel) AddAbilities(tx *gorm.DB) error {
 	models_ := strings.Split(channel.Models, ",")
 	groups_ := strings.Split(channel.Group, ",")
@@ -260,9 +308,44 @@ func (channel *Channel) UpdateAbilities(tx *gorm.DB) error {
 }
 
 func UpdateAbilityStatus(channelId int, status bool) error {
+	if status {
+		return DB.Model(&Ability{}).Where("channel_id = ?", channelId).Select("enabled", "disabled_reason", "disabled_time").Updates(abilityStatusUpdates(true, "")).Error
+	}
 	return DB.Model(&Ability{}).Where("channel_id = ?", channelId).Select("enabled").Update("enabled", status).Error
 }
 
+func abilityStatusUpdates(status bool, reason string) map[string]interface{} {
+	if status {
+		return map[string]interface{}{"enabled": true, "disabled_reason": "", "disabled_time": 0}
+	}
+	return map[string]interface{}{"enabled": false, "disabled_reason": reason, "disabled_time": common.GetTimestamp()}
+}
+
+// UpdateAbilityStatusByModel 只修改渠道某个模型在所有分组下的能力状态，返回实际变更的行数；渠道状态变化或编辑渠道时会被重置
+func UpdateAbilityStatusByModel(channelId int, model string, status bool, reason string) (int64, error) {
+	result := DB.Model(&Ability{}).Where("channel_id = ? AND model = ? AND enabled = ?", channelId, model, !status).
+		Select("enabled", "disabled_reason", "disabled_time").Updates(abilityStatusUpdates(status, reason))
+	return result.RowsAffected, result.Error
+}
+
+// UpdateAbilityStatusByGroupModel 修改单个 (分组, 模型, 渠道) 能力的状态，返回状态是否发生变化
+func UpdateAbilityStatusByGroupModel(group string, model string, channelId int, status bool, reason string) (bool, error) {
+	result := DB.Model(&Ability{}).Where(commonGroupCol+" = ? AND model = ? AND channel_id = ? AND enabled = ?", group, model, channelId, !status).
+		Select("enabled", "disabled_reason", "disabled_time").Updates(abilityStatusUpdates(status, reason))
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetDisabledAbilities 返回单独被禁用的模型能力，channelId 为 0 时返回所有渠道的
+func GetDisabledAbilities(channelId int) ([]Ability, error) {
+	var abilities []Ability
+	query := DB.Where("enabled = ? AND disabled_time > 0", false)
+	if channelId != 0 {
+		query = query.Where("channel_id = ?", channelId)
+	}
+	err := query.Order("disabled_time desc").Find(&abilities).Error
+	return abilities, err
+}
+
 func UpdateAbilityStatusByTag(tag string, status bool) error {
 	return DB.Model(&Ability{}).Where("tag = ?", tag).Select("enabled").Update("enabled", status).Error
 }
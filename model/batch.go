@@ -0,0 +1,171 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// Batch 网关侧执行的批处理任务，输入输出均为 File 表中的 JSONL 文件
+type Batch struct {
+	Id               int    `json:"id"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	Group            string `json:"group" gorm:"type:varchar(64)"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(64)"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	Errors           string `json:"errors" gorm:"type:text"`
+	Metadata         string `json:"metadata" gorm:"type:text"`
+	RequestTotal     int    `json:"request_total"`
+	RequestCompleted int    `json:"request_completed"`
+	RequestFailed    int    `json:"request_failed"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt        int64  `json:"updated_at" gorm:"bigint;index"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint"`
+}
+
+func GenerateBatchId() string {
+	return "batch_" + common.GetRandomString(24)
+}
+
+func (batch *Batch) IsFinished() bool {
+	switch batch.Status {
+	case BatchStatusFailed, BatchStatusCompleted, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (batch *Batch) Insert() error {
+	now := common.GetTimestamp()
+	batch.CreatedAt = now
+	batch.UpdatedAt = now
+	return DB.Create(batch).Error
+}
+
+// Update 更新进度与状态，同时刷新 updated_at 作为执行心跳
+func (batch *Batch) Update() error {
+	batch.UpdatedAt = common.GetTimestamp()
+	return DB.Model(batch).Select("output_file_id", "error_file_id", "status", "errors",
+		"request_total", "request_completed", "request_failed", "updated_at",
+		"in_progress_at", "finalizing_at", "completed_at", "failed_at", "expired_at",
+		"cancelling_at", "cancelled_at").Updates(batch).Error
+}
+
+// UpdateProgress 只刷新计数，避免覆盖用户并发发起的取消状态
+func (batch *Batch) UpdateProgress() error {
+	batch.UpdatedAt = common.GetTimestamp()
+	return DB.Model(batch).Select("request_total", "request_completed", "request_failed", "updated_at").Updates(batch).Error
+}
+
+func GetBatchStatus(id int) (string, error) {
+	var status string
+	err := DB.Model(&Batch{}).Where("id = ?", id).Select("status").Scan(&status).Error
+	return status, err
+}
+
+// CancelBatch 仅未结束的任务可以取消，validating 状态尚未开始执行，直接置为 cancelled
+func CancelBatch(batch *Batch) error {
+	now := common.GetTimestamp()
+	var result *gorm.DB
+	if batch.Status == BatchStatusValidating {
+		result = DB.Model(&Batch{}).Where("id = ? AND status = ?", batch.Id, BatchStatusValidating).
+			Updates(map[string]interface{}{"status": BatchStatusCancelled, "cancelling_at": now, "cancelled_at": now, "updated_at": now})
+	} else {
+		result = DB.Model(&Batch{}).Where("id = ? AND status IN ?", batch.Id, []string{BatchStatusInProgress, BatchStatusFinalizing}).
+			Updates(map[string]interface{}{"status": BatchStatusCancelling, "cancelling_at": now, "updated_at": now})
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("batch cannot be cancelled in its current status")
+	}
+	return DB.First(batch, batch.Id).Error
+}
+
+func GetUserBatchByBatchId(userId int, batchId string) (*Batch, error) {
+	if batchId == "" || userId == 0 {
+		return nil, errors.New("batch id 或 userId 为空！")
+	}
+	var batch Batch
+	err := DB.Where("batch_id = ? AND user_id = ?", batchId, userId).First(&batch).Error
+	return &batch, err
+}
+
+func GetUserBatches(userId int, after string, limit int) ([]*Batch, error) {
+	var batches []*Batch
+	tx := DB.Where("user_id = ?", userId)
+	if after != "" {
+		cursor, err := GetUserBatchByBatchId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("id < ?", cursor.Id)
+	}
+	err := tx.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// GetInterruptedBatches 返回执行中途中断（如节点重启）仍未结束的任务
+func GetInterruptedBatches() ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status IN ?", []string{BatchStatusInProgress, BatchStatusFinalizing, BatchStatusCancelling}).
+		Find(&batches).Error
+	return batches, err
+}
+
+// ClaimNextBatch 取出最早排队的任务并置为 in_progress，没有排队任务时返回 nil
+func ClaimNextBatch() (*Batch, error) {
+	var batch Batch
+	err := DB.Where("status = ?", BatchStatusValidating).Order("id asc").First(&batch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := common.GetTimestamp()
+	result := DB.Model(&Batch{}).Where("id = ? AND status = ?", batch.Id, BatchStatusValidating).
+		Updates(map[string]interface{}{"status": BatchStatusInProgress, "in_progress_at": now, "updated_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	batch.Status = BatchStatusInProgress
+	batch.InProgressAt = now
+	batch.UpdatedAt = now
+	return &batch, nil
+}
+
+func CountRunningBatches() (int64, error) {
+	var count int64
+	err := DB.Model(&Batch{}).Where("status IN ?", []string{BatchStatusInProgress, BatchStatusFinalizing, BatchStatusCancelling}).Count(&count).Error
+	return count, err
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
 )
@@ -64,6 +65,59 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyLimits         map[int]MultiKeyLimit `json:"multi_key_limits,omitempty"` // key权重与限流配置，key index -> limit
+}
+
+// MultiKeyLimit 多Key模式下单个key的权重与每分钟限额，0 表示使用默认权重或不限制
+type MultiKeyLimit struct {
+	Weight int `json:"weight,omitempty"` // 加权随机与按 token 用量选择时使用，默认 1
+	RPM    int `json:"rpm,omitempty"`
+	TPM    int `json:"tpm,omitempty"`
+}
+
+func (l MultiKeyLimit) weight() int {
+	if l.Weight <= 0 {
+		return 1
+	}
+	return l.Weight
+}
+
+// allow 当前分钟窗口内的请求数或 token 数达到上限时跳过该key，直到窗口重置
+func (l MultiKeyLimit) allow(usage ChannelKeyUsage) bool {
+	if l.RPM > 0 && usage.WindowRequests >= l.RPM {
+		return false
+	}
+	if l.TPM > 0 && usage.WindowTokens >= l.TPM {
+		return false
+	}
+	return true
+}
+
+// needKeyUsage 按用量选择或配置了限额时才需要读取key的用量
+func (c *ChannelInfo) needKeyUsage() bool {
+	if c.MultiKeyMode == constant.MultiKeyModeLeastRecentlyUsed || c.MultiKeyMode == constant.MultiKeyModeLeastTokens {
+		return true
+	}
+	for _, limit := range c.MultiKeyLimits {
+		if limit.RPM > 0 || limit.TPM > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filterKeyIndexes 过滤后没有剩余的key时返回原列表，避免渠道完全不可用
+func filterKeyIndexes(indexes []int, keep func(idx int) bool) []int {
+	filtered := make([]int, 0, len(indexes))
+	for _, idx := range indexes {
+		if keep(idx) {
+			filtered = append(filtered, idx)
+		}
+	}
+	if len(filtered) == 0 {
+		return indexes
+	}
+	return filtered
 }
 
 // Value implements driver.Valuer interface
@@ -101,9 +155,37 @@ func (channel *Channel) GetKeys() []string {
 	return keys
 }
 
+// PickEnabledKey 随机返回一个启用且未熔断的密钥，不推进轮询位置也不记录用量，用于内容审核等内部调用
+func (channel *Channel) PickEnabledKey() (string, *types.NewAPIError) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key, nil
+	}
+	keys := channel.GetKeys()
+	if len(keys) == 0 {
+		return "", types.NewError(errors.New("no keys available"), types.ErrorCodeChannelNoAvailableKey)
+	}
+	lock := GetChannelPollingLock(channel.Id)
+	lock.Lock()
+	enabledIdx := make([]int, 0, len(keys))
+	for i := range keys {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[i]; !ok || status == common.ChannelStatusEnabled {
+			enabledIdx = append(enabledIdx, i)
+		}
+	}
+	lock.Unlock()
+	if len(enabledIdx) == 0 {
+		return keys[0], nil
+	}
+	enabledIdx = filterKeyIndexes(enabledIdx, func(idx int) bool {
+		return CircuitBreakerAllow(channel.Id, idx)
+	})
+	return keys[enabledIdx[rand.Intn(len(enabledIdx))]], nil
+}
+
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
+		markCircuitProbe(channel.Id, 0)
 		return channel.Key, 0, nil
 	}
 
@@ -114,6 +196,12 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		return "", 0, types.NewError(errors.New("no keys available"), types.ErrorCodeChannelNoAvailableKey)
 	}
 
+	// 用量统计可能读取 Redis，在获取轮询锁之前取快照，避免同一渠道的请求排队等待网络往返
+	var usages map[int]ChannelKeyUsage
+	if channel.ChannelInfo.needKeyUsage() {
+		usages = GetChannelKeyUsages(channel.Id)
+	}
+
 	lock := GetChannelPollingLock(channel.Id)
 	lock.Lock()
 	defer lock.Unlock()
@@ -141,11 +229,39 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	if len(enabledIdx) == 0 {
 		return keys[0], 0, nil
 	}
+	// 依次跳过处于熔断中的密钥与达到 RPM/TPM 上限的密钥；全部被跳过时仍从上一步的结果中选择，交由上游与重试处理
+	enabledIdx = filterKeyIndexes(enabledIdx, func(idx int) bool {
+		return CircuitBreakerAllow(channel.Id, idx)
+	})
+	limits := channel.ChannelInfo.MultiKeyLimits
+	if len(limits) > 0 {
+		enabledIdx = filterKeyIndexes(enabledIdx, func(idx int) bool {
+			return limits[idx].allow(usages[idx])
+		})
+	}
+	candidates := make(map[int]bool, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		candidates[idx] = true
+	}
+	getStatus = func(idx int) int {
+		if candidates[idx] {
+			return common.ChannelStatusEnabled
+		}
+		return common.ChannelStatusAutoDisabled
+	}
+	var selectedIdx int
+	defer func() {
+		markCircuitProbe(channel.Id, selectedIdx)
+		keyIndex := selectedIdx
+		gopool.Go(func() {
+			recordChannelKeyUsage(channel.Id, keyIndex, 1, 0)
+		})
+	}()
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
 		// Randomly pick one enabled key
-		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
+		selectedIdx = enabledIdx[rand.Intn(len(enabledIdx))]
 		return keys[selectedIdx], selectedIdx, nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
@@ -175,14 +291,56 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 			if getStatus(idx) == common.ChannelStatusEnabled {
 				// update polling index for next call (point to the next position)
 				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
+				selectedIdx = idx
 				return keys[idx], idx, nil
 			}
 		}
 		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		selectedIdx = enabledIdx[0]
+		return keys[selectedIdx], selectedIdx, nil
+	case constant.MultiKeyModeWeighted:
+		totalWeight := 0
+		for _, idx := range enabledIdx {
+			totalWeight += limits[idx].weight()
+		}
+		r := rand.Intn(totalWeight)
+		for _, idx := range enabledIdx {
+			r -= limits[idx].weight()
+			if r < 0 {
+				selectedIdx = idx
+				break
+			}
+		}
+		return keys[selectedIdx], selectedIdx, nil
+	case constant.MultiKeyModeLeastRecentlyUsed:
+		selectedIdx = enabledIdx[0]
+		for _, idx := range enabledIdx[1:] {
+			if usages[idx].LastUsedAt < usages[selectedIdx].LastUsedAt {
+				selectedIdx = idx
+			}
+		}
+		return keys[selectedIdx], selectedIdx, nil
+	case constant.MultiKeyModeLeastTokens:
+		// token 用量按权重折算，权重高的key可以承担更多用量；用量相同时选择最久未使用的key
+		less := func(a, b int) bool {
+			scoreA := float64(usages[a].WindowTokens) / float64(limits[a].weight())
+			scoreB := float64(usages[b].WindowTokens) / float64(limits[b].weight())
+			if scoreA != scoreB {
+				return scoreA < scoreB
+			}
+			return usages[a].LastUsedAt < usages[b].LastUsedAt
+		}
+		selectedIdx = enabledIdx[0]
+		for _, idx := range enabledIdx[1:] {
+			if less(idx, selectedIdx) {
+				selectedIdx = idx
+			}
+		}
+		return keys[selectedIdx], selectedIdx, nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		selectedIdx = enabledIdx[0]
+		return keys[selectedIdx], selectedIdx, nil
 	}
 }
 
@@ -483,6 +641,11 @@ func (channel *Channel) Update() error {
 				}
 			}
 		}
+		for idx := range channel.ChannelInfo.MultiKeyLimits {
+			if idx >= channel.ChannelInfo.MultiKeySize {
+				delete(channel.ChannelInfo.MultiKeyLimits, idx)
+			}
+		}
 	}
 	var err error
 	err = DB.Model(channel).Updates(channel).Error
@@ -514,6 +677,27 @@ func (channel *Channel) UpdateBalance(balance float64) {
 	}
 }
 
+// UpdateWeight 修改渠道及其能力的权重
+func (channel *Channel) UpdateWeight(weight uint) error {
+	err := DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("weight", weight).Error
+	if err != nil {
+		return err
+	}
+	channel.Weight = &weight
+	err = DB.Model(&Ability{}).Where("channel_id = ?", channel.Id).Update("weight", weight).Error
+	if err != nil {
+		return err
+	}
+	CacheUpdateChannelWeight(channel.Id, weight)
+	return nil
+}
+
+// UpdateOtherInfo 只保存渠道的 other_info 字段
+func (channel *Channel) UpdateOtherInfo(otherInfo map[string]interface{}) error {
+	channel.SetOtherInfo(otherInfo)
+	return DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("other_info", channel.OtherInfo).Error
+}
+
 func (channel *Channel) Delete() error {
 	var err error
 	err = DB.Delete(channel).Error
@@ -521,7 +705,10 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	return DeleteChannelTestResults(channel.Id)
 }
 
 var channelStatusLock sync.Mutex
@@ -577,6 +764,12 @@ func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int, reason
 		}
 		if status == common.ChannelStatusEnabled {
 			delete(channel.ChannelInfo.MultiKeyStatusList, keyIndex)
+			delete(channel.ChannelInfo.MultiKeyDisabledReason, keyIndex)
+			delete(channel.ChannelInfo.MultiKeyDisabledTime, keyIndex)
+			// 所有密钥被禁用导致的渠道自动禁用，在有密钥恢复后一并恢复
+			if channel.Status == common.ChannelStatusAutoDisabled {
+				channel.Status = common.ChannelStatusEnabled
+			}
 		} else {
 			channel.ChannelInfo.MultiKeyStatusList[keyIndex] = status
 			if channel.ChannelInfo.MultiKeyDisabledReason == nil {
@@ -598,6 +791,17 @@ func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int, reason
 	}
 }
 
+// isMultiKeyDisabled 判断多Key渠道中的某个密钥是否处于禁用状态
+func isMultiKeyDisabled(channel *Channel, usingKey string) bool {
+	for i, key := range channel.GetKeys() {
+		if key == usingKey {
+			_, disabled := channel.ChannelInfo.MultiKeyStatusList[i]
+			return disabled
+		}
+	}
+	return false
+}
+
 func UpdateChannelStatus(channelId int, usingKey string, status int, reason string) bool {
 	if common.MemoryCacheEnabled {
 		channelStatusLock.Lock()
@@ -638,11 +842,11 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 	if err != nil {
 		return false
 	} else {
-		if channel.Status == status {
-			return false
-		}
-
 		if channel.ChannelInfo.IsMultiKey {
+			// 多Key渠道的状态变化发生在单个密钥上，不能按渠道状态提前返回
+			if status == common.ChannelStatusEnabled && channel.Status != common.ChannelStatusAutoDisabled && !isMultiKeyDisabled(channel, usingKey) {
+				return false
+			}
 			beforeStatus := channel.Status
 			// Protect map writes with the same per-channel lock used by readers
 			pollingLock := GetChannelPollingLock(channelId)
@@ -653,6 +857,9 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 				shouldUpdateAbilities = true
 			}
 		} else {
+			if channel.Status == status {
+				return false
+			}
 			info := channel.GetOtherInfo()
 			info["status_reason"] = reason
 			info["status_time"] = common.GetTimestamp()
@@ -0,0 +1,226 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 熔断器按 渠道 + 密钥索引 维护，单密钥渠道使用索引 0；状态仅保存在当前节点内存中。
+// 熔断只处理 429、5xx 等暂时性错误，密钥失效等永久性错误仍走自动禁用。
+
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+)
+
+type circuitBreakerKey struct {
+	ChannelId int
+	KeyIndex  int
+}
+
+type circuitBreaker struct {
+	state       string
+	failures    int // closed 状态下的连续失败次数
+	successes   int // half-open 状态下的连续成功次数
+	trips       int // 恢复前连续熔断的次数，用于计算冷却时间
+	openedAt    time.Time
+	coolDown    time.Duration
+	lastProbeAt time.Time
+	lastError   string
+}
+
+type CircuitBreakerState struct {
+	ChannelId   int    `json:"channel_id"`
+	KeyIndex    int    `json:"key_index"`
+	State       string `json:"state"`
+	Failures    int    `json:"failures"`
+	Trips       int    `json:"trips"`
+	OpenedAt    int64  `json:"opened_at"`
+	CoolDownSec int64  `json:"cool_down_seconds"`
+	LastError   string `json:"last_error"`
+}
+
+var circuitBreakers = make(map[circuitBreakerKey]*circuitBreaker)
+var circuitBreakersLock sync.Mutex
+
+func isCircuitBreakerEnabled() bool {
+	return operation_setting.GetCircuitBreakerSetting().Enabled
+}
+
+// allow 调用方需持有 circuitBreakersLock；冷却结束时在此处转为 half-open
+func (b *circuitBreaker) allow(key circuitBreakerKey, now time.Time) bool {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	switch b.state {
+	case CircuitStateOpen:
+		if now.Before(b.openedAt.Add(b.coolDown)) {
+			return false
+		}
+		b.state = CircuitStateHalfOpen
+		b.successes = 0
+		b.lastProbeAt = time.Time{}
+		common.SysLog(fmt.Sprintf("circuit breaker half-open: channel #%d key #%d", key.ChannelId, key.KeyIndex))
+		return true
+	case CircuitStateHalfOpen:
+		return now.Sub(b.lastProbeAt) >= time.Duration(setting.ProbeIntervalSeconds)*time.Second
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) trip(key circuitBreakerKey, now time.Time) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	b.trips++
+	coolDown := time.Duration(setting.CoolDownSeconds) * time.Second
+	maxCoolDown := time.Duration(setting.MaxCoolDownSeconds) * time.Second
+	for i := 1; i < b.trips && coolDown < maxCoolDown; i++ {
+		coolDown *= 2
+	}
+	if maxCoolDown > 0 && coolDown > maxCoolDown {
+		coolDown = maxCoolDown
+	}
+	b.state = CircuitStateOpen
+	b.openedAt = now
+	b.coolDown = coolDown
+	b.failures = 0
+	b.successes = 0
+	common.SysLog(fmt.Sprintf("circuit breaker open: channel #%d key #%d, cool down %s, last error: %s", key.ChannelId, key.KeyIndex, coolDown, b.lastError))
+}
+
+// CircuitBreakerAllow 判断渠道的某个密钥当前是否可以接收请求，不会占用探测名额
+func CircuitBreakerAllow(channelId int, keyIndex int) bool {
+	if !isCircuitBreakerEnabled() {
+		return true
+	}
+	key := circuitBreakerKey{ChannelId: channelId, KeyIndex: keyIndex}
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	b, ok := circuitBreakers[key]
+	if !ok {
+		return true
+	}
+	return b.allow(key, time.Now())
+}
+
+// markCircuitProbe 密钥被选中后调用，half-open 状态下记录探测时间以限制探测流量
+func markCircuitProbe(channelId int, keyIndex int) {
+	if !isCircuitBreakerEnabled() {
+		return
+	}
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	if b, ok := circuitBreakers[circuitBreakerKey{ChannelId: channelId, KeyIndex: keyIndex}]; ok && b.state == CircuitStateHalfOpen {
+		b.lastProbeAt = time.Now()
+	}
+}
+
+// isChannelCircuitAllowed 多密钥渠道只要还有一个密钥未熔断就视为可用。
+// 这里不读取 MultiKeyStatusList，避免在持有 channelSyncLock 时再获取渠道轮询锁
+func isChannelCircuitAllowed(channel *Channel) bool {
+	if !channel.ChannelInfo.IsMultiKey {
+		return CircuitBreakerAllow(channel.Id, 0)
+	}
+	for i := 0; i < channel.ChannelInfo.MultiKeySize; i++ {
+		if CircuitBreakerAllow(channel.Id, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func RecordCircuitBreakerSuccess(channelId int, keyIndex int) {
+	if !isCircuitBreakerEnabled() {
+		return
+	}
+	key := circuitBreakerKey{ChannelId: channelId, KeyIndex: keyIndex}
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	b, ok := circuitBreakers[key]
+	if !ok {
+		return
+	}
+	switch b.state {
+	case CircuitStateHalfOpen:
+		b.successes++
+		if b.successes >= operation_setting.GetCircuitBreakerSetting().HalfOpenSuccesses {
+			common.SysLog(fmt.Sprintf("circuit breaker closed: channel #%d key #%d", channelId, keyIndex))
+			delete(circuitBreakers, key)
+		}
+	case CircuitStateClosed:
+		delete(circuitBreakers, key)
+	}
+}
+
+func RecordCircuitBreakerFailure(channelId int, keyIndex int, reason string) {
+	if !isCircuitBreakerEnabled() {
+		return
+	}
+	key := circuitBreakerKey{ChannelId: channelId, KeyIndex: keyIndex}
+	now := time.Now()
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	b, ok := circuitBreakers[key]
+	if !ok {
+		b = &circuitBreaker{state: CircuitStateClosed}
+		circuitBreakers[key] = b
+	}
+	b.lastError = reason
+	switch b.state {
+	case CircuitStateHalfOpen:
+		b.trip(key, now)
+	case CircuitStateClosed:
+		b.failures++
+		if b.failures >= operation_setting.GetCircuitBreakerSetting().FailureThreshold {
+			b.trip(key, now)
+		}
+	}
+}
+
+// ResetCircuitBreakers channelId 为 0 时重置全部渠道
+func ResetCircuitBreakers(channelId int) {
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	for key := range circuitBreakers {
+		if channelId == 0 || key.ChannelId == channelId {
+			delete(circuitBreakers, key)
+		}
+	}
+}
+
+func GetCircuitBreakerStates(channelId int) []CircuitBreakerState {
+	now := time.Now()
+	circuitBreakersLock.Lock()
+	states := make([]CircuitBreakerState, 0, len(circuitBreakers))
+	for key, b := range circuitBreakers {
+		if channelId != 0 && key.ChannelId != channelId {
+			continue
+		}
+		// 顺便推进冷却结束的熔断器，保证展示的状态准确
+		b.allow(key, now)
+		state := CircuitBreakerState{
+			ChannelId:   key.ChannelId,
+			KeyIndex:    key.KeyIndex,
+			State:       b.state,
+			Failures:    b.failures,
+			Trips:       b.trips,
+			CoolDownSec: int64(b.coolDown.Seconds()),
+			LastError:   b.lastError,
+		}
+		if !b.openedAt.IsZero() {
+			state.OpenedAt = b.openedAt.Unix()
+		}
+		states = append(states, state)
+	}
+	circuitBreakersLock.Unlock()
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].ChannelId != states[j].ChannelId {
+			return states[i].ChannelId < states[j].ChannelId
+		}
+		return states[i].KeyIndex < states[j].KeyIndex
+	})
+	return states
+}
@@ -7,6 +7,7 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"sort"
 	"strings"
@@ -33,8 +34,13 @@ func InitChannelCache() {
 	var abilities []*Ability
 	DB.Find(&abilities)
 	groups := make(map[string]bool)
+	// 渠道启用但单独被禁用的模型能力，不加入缓存
+	disabledAbilities := make(map[string]bool)
 	for _, ability := range abilities {
 		groups[ability.Group] = true
+		if !ability.Enabled {
+			disabledAbilities[fmt.Sprintf("%d|%s|%s", ability.ChannelId, ability.Group, ability.Model)] = true
+		}
 	}
 	newGroup2model2channels := make(map[string]map[string][]int)
 	for group := range groups {
@@ -48,6 +54,9 @@ func InitChannelCache() {
 		for _, group := range groups {
 			models := strings.Split(channel.Models, ",")
 			for _, model := range models {
+				if disabledAbilities[fmt.Sprintf("%d|%s|%s", channel.Id, group, model)] {
+					continue
+				}
 				if _, ok := newGroup2model2channels[group][model]; !ok {
 					newGroup2model2channels[group][model] = make([]int, 0)
 				}
@@ -150,6 +159,8 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		return nil, nil
 	}
 
+	channels = filterCircuitAllowedChannels(channels)
+
 	if len(channels) == 1 {
 		if channel, ok := channelsIDM[channels[0]]; ok {
 			return channel, nil
@@ -190,6 +201,15 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// 平滑系数
 	smoothingFactor := 10
+	if operation_setting.IsChannelSelectAdaptive(group) {
+		channelIds := make([]int, len(targetChannels))
+		weights := make([]int, len(targetChannels))
+		for i, channel := range targetChannels {
+			channelIds[i] = channel.Id
+			weights[i] = channel.GetWeight()
+		}
+		return targetChannels[selectAdaptiveChannelIndex(channelIds, weights, model, smoothingFactor)], nil
+	}
 	// Calculate the total weight of all channels up to endIdx
 	totalWeight := 0
 	for _, channel := range targetChannels {
@@ -209,6 +229,24 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	return nil, errors.New("channel not found")
 }
 
+// filterCircuitAllowedChannels 过滤掉处于熔断中的渠道，全部熔断时返回原列表，调用方需持有 channelSyncLock
+func filterCircuitAllowedChannels(channels []int) []int {
+	if !isCircuitBreakerEnabled() {
+		return channels
+	}
+	allowed := make([]int, 0, len(channels))
+	for _, channelId := range channels {
+		channel, ok := channelsIDM[channelId]
+		if !ok || isChannelCircuitAllowed(channel) {
+			allowed = append(allowed, channelId)
+		}
+	}
+	if len(allowed) == 0 {
+		return channels
+	}
+	return allowed
+}
+
 func CacheGetChannel(id int) (*Channel, error) {
 	if !common.MemoryCacheEnabled {
 		return GetChannelById(id, true)
@@ -241,6 +279,33 @@ func CacheGetChannelInfo(id int) (*ChannelInfo, error) {
 	return &c.ChannelInfo, nil
 }
 
+// CacheDisableAbility 从缓存中移除单个 (分组, 模型, 渠道) 组合
+func CacheDisableAbility(group string, model string, channelId int) {
+	if !common.MemoryCacheEnabled {
+		return
+	}
+	channelSyncLock.Lock()
+	defer channelSyncLock.Unlock()
+	channels := group2model2channels[group][model]
+	for i, id := range channels {
+		if id == channelId {
+			group2model2channels[group][model] = append(channels[:i:i], channels[i+1:]...)
+			break
+		}
+	}
+}
+
+func CacheUpdateChannelWeight(id int, weight uint) {
+	if !common.MemoryCacheEnabled {
+		return
+	}
+	channelSyncLock.Lock()
+	defer channelSyncLock.Unlock()
+	if channel, ok := channelsIDM[id]; ok {
+		channel.Weight = &weight
+	}
+}
+
 func CacheUpdateChannelStatus(id int, status int) {
 	if !common.MemoryCacheEnabled {
 		return
@@ -0,0 +1,192 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多Key渠道按 渠道 + 密钥索引 统计每个密钥在固定一分钟窗口内的请求数与 token 数，用于按用量选择密钥和 RPM/TPM 限制。
+// 启用 Redis 时在多个节点间共享，否则仅保存在当前节点内存中
+
+const channelKeyUsageWindowSeconds = 60
+
+type ChannelKeyUsage struct {
+	LastUsedAt     int64 `json:"last_used_at"`    // 最近一次被选中的时间（毫秒）
+	WindowRequests int   `json:"window_requests"` // 当前分钟窗口内的请求数
+	WindowTokens   int   `json:"window_tokens"`   // 当前分钟窗口内的 token 数
+	WindowResetAt  int64 `json:"window_reset_at"` // 当前窗口的结束时间
+	TotalRequests  int64 `json:"total_requests"`
+	TotalTokens    int64 `json:"total_tokens"`
+}
+
+type channelKeyUsageKey struct {
+	ChannelId int
+	KeyIndex  int
+}
+
+type channelKeyUsageState struct {
+	slot  int64
+	usage ChannelKeyUsage
+}
+
+var channelKeyUsages = make(map[channelKeyUsageKey]*channelKeyUsageState)
+var channelKeyUsagesLock sync.Mutex
+
+func channelKeyUsageSlot(now time.Time) int64 {
+	return now.Unix() / channelKeyUsageWindowSeconds
+}
+
+func channelKeyUsageRedisKey(channelId int) string {
+	return fmt.Sprintf("channel_key_usage:%d", channelId)
+}
+
+func channelKeyUsageWindowRedisKey(channelId int, slot int64) string {
+	return fmt.Sprintf("channel_key_usage:%d:%d", channelId, slot)
+}
+
+// GetChannelKeyUsages 返回渠道各个密钥的用量，没有记录的密钥不在结果中
+func GetChannelKeyUsages(channelId int) map[int]ChannelKeyUsage {
+	now := time.Now()
+	slot := channelKeyUsageSlot(now)
+	resetAt := (slot + 1) * channelKeyUsageWindowSeconds
+	result := make(map[int]ChannelKeyUsage)
+	if common.RedisEnabled {
+		ctx := context.Background()
+		totals, err := common.RDB.HGetAll(ctx, channelKeyUsageRedisKey(channelId)).Result()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to get key usage of channel #%d: %v", channelId, err))
+			return result
+		}
+		window, err := common.RDB.HGetAll(ctx, channelKeyUsageWindowRedisKey(channelId, slot)).Result()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to get key usage of channel #%d: %v", channelId, err))
+			return result
+		}
+		// 字段格式为 "<key index>:<last|req|tok>"
+		parse := func(fields map[string]string, inWindow bool) {
+			for field, value := range fields {
+				indexStr, kind, ok := strings.Cut(field, ":")
+				if !ok {
+					continue
+				}
+				keyIndex, err := strconv.Atoi(indexStr)
+				if err != nil {
+					continue
+				}
+				n, _ := strconv.ParseInt(value, 10, 64)
+				usage := result[keyIndex]
+				usage.WindowResetAt = resetAt
+				switch {
+				case kind == "last":
+					usage.LastUsedAt = n
+				case kind == "req" && inWindow:
+					usage.WindowRequests = int(n)
+				case kind == "tok" && inWindow:
+					usage.WindowTokens = int(n)
+				case kind == "req":
+					usage.TotalRequests = n
+				case kind == "tok":
+					usage.TotalTokens = n
+				}
+				result[keyIndex] = usage
+			}
+		}
+		parse(totals, false)
+		parse(window, true)
+		return result
+	}
+
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	for key, state := range channelKeyUsages {
+		if key.ChannelId != channelId {
+			continue
+		}
+		usage := state.usage
+		if state.slot != slot {
+			usage.WindowRequests = 0
+			usage.WindowTokens = 0
+		}
+		usage.WindowResetAt = resetAt
+		result[key.KeyIndex] = usage
+	}
+	return result
+}
+
+func recordChannelKeyUsage(channelId int, keyIndex int, requests int, tokens int) {
+	now := time.Now()
+	slot := channelKeyUsageSlot(now)
+	if common.RedisEnabled {
+		ctx := context.Background()
+		totalsKey := channelKeyUsageRedisKey(channelId)
+		windowKey := channelKeyUsageWindowRedisKey(channelId, slot)
+		pipe := common.RDB.Pipeline()
+		if requests > 0 {
+			pipe.HSet(ctx, totalsKey, fmt.Sprintf("%d:last", keyIndex), now.UnixMilli())
+			pipe.HIncrBy(ctx, totalsKey, fmt.Sprintf("%d:req", keyIndex), int64(requests))
+			pipe.HIncrBy(ctx, windowKey, fmt.Sprintf("%d:req", keyIndex), int64(requests))
+		}
+		if tokens > 0 {
+			pipe.HIncrBy(ctx, totalsKey, fmt.Sprintf("%d:tok", keyIndex), int64(tokens))
+			pipe.HIncrBy(ctx, windowKey, fmt.Sprintf("%d:tok", keyIndex), int64(tokens))
+		}
+		pipe.Expire(ctx, windowKey, 2*channelKeyUsageWindowSeconds*time.Second)
+		if _, err := pipe.Exec(ctx); err != nil {
+			common.SysLog(fmt.Sprintf("failed to record key usage of channel #%d key #%d: %v", channelId, keyIndex, err))
+		}
+		return
+	}
+
+	key := channelKeyUsageKey{ChannelId: channelId, KeyIndex: keyIndex}
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	state, ok := channelKeyUsages[key]
+	if !ok {
+		state = &channelKeyUsageState{}
+		channelKeyUsages[key] = state
+	}
+	if state.slot != slot {
+		state.slot = slot
+		state.usage.WindowRequests = 0
+		state.usage.WindowTokens = 0
+	}
+	if requests > 0 {
+		state.usage.LastUsedAt = now.UnixMilli()
+		state.usage.WindowRequests += requests
+		state.usage.TotalRequests += int64(requests)
+	}
+	if tokens > 0 {
+		state.usage.WindowTokens += tokens
+		state.usage.TotalTokens += int64(tokens)
+	}
+}
+
+// RecordChannelKeyTokens 结算后累加密钥消耗的 token 数，供 TPM 限制与按用量选择使用
+func RecordChannelKeyTokens(channelId int, keyIndex int, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	recordChannelKeyUsage(channelId, keyIndex, 0, tokens)
+}
+
+// ResetChannelKeyUsage 删除密钥后索引会重新排列，需要清空渠道的用量统计
+func ResetChannelKeyUsage(channelId int) {
+	if common.RedisEnabled {
+		ctx := context.Background()
+		slot := channelKeyUsageSlot(time.Now())
+		common.RDB.Del(ctx, channelKeyUsageRedisKey(channelId), channelKeyUsageWindowRedisKey(channelId, slot), channelKeyUsageWindowRedisKey(channelId, slot-1))
+		return
+	}
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	for key := range channelKeyUsages {
+		if key.ChannelId == channelId {
+			delete(channelKeyUsages, key)
+		}
+	}
+}
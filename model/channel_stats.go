@@ -0,0 +1,231 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"one-api/setting/operation_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 渠道健康度统计仅保存在当前节点内存中，用于自适应选择渠道；多节点部署时各节点独立统计
+const channelStatBucketCount = 10
+
+type channelStatKey struct {
+	ChannelId int
+	Model     string
+}
+
+type channelStatBucket struct {
+	slot         int64
+	success      int64
+	failure      int64
+	ttftSum      int64
+	ttftCount    int64
+	latencySum   int64
+	latencyCount int64
+	responseSum  int64 // 流式请求取首字时间，非流式取总耗时，用于打分
+}
+
+type channelStatWindow struct {
+	buckets [channelStatBucketCount]channelStatBucket
+}
+
+type ChannelScore struct {
+	ChannelId    int     `json:"channel_id"`
+	Model        string  `json:"model"`
+	Success      int64   `json:"success"`
+	Failure      int64   `json:"failure"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgTTFTMs    int64   `json:"avg_ttft_ms"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+	Factor       float64 `json:"factor"` // 自适应模式下对渠道权重的缩放比例
+	responseMs   float64
+}
+
+var channelStats = make(map[channelStatKey]*channelStatWindow)
+var channelStatsLock sync.Mutex
+
+func channelStatBucketSeconds() int64 {
+	seconds := int64(operation_setting.GetChannelSelectSetting().WindowSeconds) / channelStatBucketCount
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// currentBucket 调用方需持有 channelStatsLock
+func (w *channelStatWindow) currentBucket(now int64) *channelStatBucket {
+	slot := now / channelStatBucketSeconds()
+	bucket := &w.buckets[slot%channelStatBucketCount]
+	if bucket.slot != slot {
+		*bucket = channelStatBucket{slot: slot}
+	}
+	return bucket
+}
+
+func getChannelStatWindow(channelId int, model string) *channelStatWindow {
+	key := channelStatKey{ChannelId: channelId, Model: model}
+	window, ok := channelStats[key]
+	if !ok {
+		window = &channelStatWindow{}
+		channelStats[key] = window
+	}
+	return window
+}
+
+// RecordChannelSuccess ttft 为 0 表示非流式请求
+func RecordChannelSuccess(channelId int, model string, ttft time.Duration, latency time.Duration) {
+	channelStatsLock.Lock()
+	defer channelStatsLock.Unlock()
+	bucket := getChannelStatWindow(channelId, model).currentBucket(time.Now().Unix())
+	bucket.success++
+	bucket.latencySum += latency.Milliseconds()
+	bucket.latencyCount++
+	if ttft > 0 {
+		bucket.ttftSum += ttft.Milliseconds()
+		bucket.ttftCount++
+		bucket.responseSum += ttft.Milliseconds()
+	} else {
+		bucket.responseSum += latency.Milliseconds()
+	}
+}
+
+func RecordChannelFailure(channelId int, model string) {
+	channelStatsLock.Lock()
+	defer channelStatsLock.Unlock()
+	bucket := getChannelStatWindow(channelId, model).currentBucket(time.Now().Unix())
+	bucket.failure++
+}
+
+// summarize 调用方需持有 channelStatsLock
+func (w *channelStatWindow) summarize(key channelStatKey, now int64) ChannelScore {
+	score := ChannelScore{ChannelId: key.ChannelId, Model: key.Model, Factor: 1}
+	minSlot := now/channelStatBucketSeconds() - channelStatBucketCount + 1
+	var ttftSum, ttftCount, latencySum, latencyCount, responseSum int64
+	for _, bucket := range w.buckets {
+		if bucket.slot < minSlot {
+			continue
+		}
+		score.Success += bucket.success
+		score.Failure += bucket.failure
+		ttftSum += bucket.ttftSum
+		ttftCount += bucket.ttftCount
+		latencySum += bucket.latencySum
+		latencyCount += bucket.latencyCount
+		responseSum += bucket.responseSum
+	}
+	if total := score.Success + score.Failure; total > 0 {
+		score.SuccessRate = float64(score.Success) / float64(total)
+	}
+	if ttftCount > 0 {
+		score.AvgTTFTMs = ttftSum / ttftCount
+	}
+	if latencyCount > 0 {
+		score.AvgLatencyMs = latencySum / latencyCount
+	}
+	if score.Success > 0 {
+		score.responseMs = float64(responseSum) / float64(score.Success)
+	}
+	return score
+}
+
+// applyScoreFactors 根据同一批候选渠道的统计计算权重缩放比例：
+// 成功率按平方惩罚，响应时间与候选中最快的渠道比较
+func applyScoreFactors(scores []ChannelScore) {
+	setting := operation_setting.GetChannelSelectSetting()
+	bestResponse := math.MaxFloat64
+	for _, score := range scores {
+		if score.Success+score.Failure >= int64(setting.MinSamples) && score.responseMs > 0 && score.responseMs < bestResponse {
+			bestResponse = score.responseMs
+		}
+	}
+	for i := range scores {
+		score := &scores[i]
+		if score.Success+score.Failure < int64(setting.MinSamples) {
+			score.Factor = 1
+			continue
+		}
+		factor := score.SuccessRate * score.SuccessRate
+		if setting.LatencyWeight > 0 && score.responseMs > 0 && bestResponse < math.MaxFloat64 {
+			factor *= math.Pow(bestResponse/score.responseMs, setting.LatencyWeight)
+		}
+		score.Factor = math.Max(factor, setting.MinFactor)
+	}
+}
+
+func getChannelScores(channelIds []int, model string) []ChannelScore {
+	now := time.Now().Unix()
+	scores := make([]ChannelScore, len(channelIds))
+	channelStatsLock.Lock()
+	for i, channelId := range channelIds {
+		key := channelStatKey{ChannelId: channelId, Model: model}
+		if window, ok := channelStats[key]; ok {
+			scores[i] = window.summarize(key, now)
+		} else {
+			scores[i] = ChannelScore{ChannelId: channelId, Model: model, Factor: 1}
+		}
+	}
+	channelStatsLock.Unlock()
+	applyScoreFactors(scores)
+	return scores
+}
+
+// selectAdaptiveChannelIndex 按 (权重 + 平滑系数) * 健康度缩放比例 加权随机，返回选中的下标
+func selectAdaptiveChannelIndex(channelIds []int, weights []int, model string, smoothingFactor int) int {
+	scores := getChannelScores(channelIds, model)
+	totalWeight := 0.0
+	effectiveWeights := make([]float64, len(channelIds))
+	for i := range channelIds {
+		effectiveWeights[i] = float64(weights[i]+smoothingFactor) * scores[i].Factor
+		totalWeight += effectiveWeights[i]
+	}
+	randomWeight := rand.Float64() * totalWeight
+	for i, weight := range effectiveWeights {
+		randomWeight -= weight
+		if randomWeight < 0 {
+			return i
+		}
+	}
+	return len(channelIds) - 1
+}
+
+// GetChannelScores 返回当前窗口内的渠道统计，model 或 channelId 为空时不过滤；
+// 缩放比例按同一模型下所有有统计的渠道计算
+func GetChannelScores(model string, channelId int) []ChannelScore {
+	now := time.Now().Unix()
+	model2scores := make(map[string][]ChannelScore)
+	channelStatsLock.Lock()
+	for key, window := range channelStats {
+		if model != "" && key.Model != model {
+			continue
+		}
+		score := window.summarize(key, now)
+		if score.Success+score.Failure == 0 {
+			// 窗口内已无数据，顺便清理
+			delete(channelStats, key)
+			continue
+		}
+		model2scores[key.Model] = append(model2scores[key.Model], score)
+	}
+	channelStatsLock.Unlock()
+
+	result := make([]ChannelScore, 0)
+	for _, scores := range model2scores {
+		applyScoreFactors(scores)
+		for _, score := range scores {
+			if channelId != 0 && score.ChannelId != channelId {
+				continue
+			}
+			result = append(result, score)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Model != result[j].Model {
+			return result[i].Model < result[j].Model
+		}
+		return result[i].ChannelId < result[j].ChannelId
+	})
+	return result
+}
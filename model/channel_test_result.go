@@ -0,0 +1,42 @@
+package model
+
+// ChannelTestResult 渠道测试矩阵中 模型 × 端点类型 的最近一次测试结果，每次测试渠道时整体替换
+type ChannelTestResult struct {
+	Id           int    `json:"id"`
+	ChannelId    int    `json:"channel_id" gorm:"index"`
+	Model        string `json:"model" gorm:"type:varchar(255)"`
+	EndpointType string `json:"endpoint_type" gorm:"type:varchar(64)"`
+	Success      bool   `json:"success"`
+	Latency      int64  `json:"latency"` // 毫秒
+	Message      string `json:"message" gorm:"type:text"`
+	TestedAt     int64  `json:"tested_at" gorm:"bigint"`
+}
+
+func GetChannelTestResults(channelId int) ([]ChannelTestResult, error) {
+	var results []ChannelTestResult
+	err := DB.Where("channel_id = ?", channelId).Order("model asc, endpoint_type asc").Find(&results).Error
+	return results, err
+}
+
+// SaveChannelTestResults 用本次测试结果替换渠道之前的测试记录
+func SaveChannelTestResults(channelId int, results []ChannelTestResult) error {
+	tx := DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Where("channel_id = ?", channelId).Delete(&ChannelTestResult{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(results) > 0 {
+		if err := tx.CreateInBatches(results, 50).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func DeleteChannelTestResults(channelId int) error {
+	return DB.Where("channel_id = ?", channelId).Delete(&ChannelTestResult{}).Error
+}
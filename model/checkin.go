@@ -233,6 +233,14 @@ func CreateCheckIn(userId int, reward int) error {
 		tx.Rollback()
 		return err
 	}
+	if err := recordQuotaLedgerTx(tx, userId, reward, QuotaLedgerSource{
+		Type:       QuotaLedgerTypeCheckin,
+		SourceRef:  today,
+		OperatorId: userId,
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FileStatusUploaded  = "uploaded"
+	FileStatusProcessed = "processed"
+	FileStatusError     = "error"
+)
+
+// File 网关自有的文件记录，内容保存在 service 层的文件存储中
+type File struct {
+	Id             int            `json:"id"`
+	FileId         string         `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int            `json:"user_id" gorm:"index"`
+	TokenId        int            `json:"token_id" gorm:"index"`
+	Filename       string         `json:"filename" gorm:"type:varchar(255)"`
+	Purpose        string         `json:"purpose" gorm:"type:varchar(64);index"`
+	Bytes          int64          `json:"bytes"`
+	Status         string         `json:"status" gorm:"type:varchar(20);default:'uploaded'"`
+	StatusDetails  string         `json:"status_details"`
+	StorageKey     string         `json:"-" gorm:"type:varchar(255)"`
+	ChannelId      int            `json:"channel_id" gorm:"index"` // 透传时持有该文件的上游渠道
+	ChannelKeyIdx  int            `json:"channel_key_idx"`         // 多密钥渠道上传时使用的密钥索引
+	UpstreamFileId string         `json:"upstream_file_id" gorm:"type:varchar(128)"`
+	CreatedAt      int64          `json:"created_at" gorm:"bigint;index"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func GenerateFileId() string {
+	return "file-" + common.GetRandomString(24)
+}
+
+func (file *File) Insert() error {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+func (file *File) Update() error {
+	return DB.Model(file).Select("status", "status_details", "bytes", "storage_key", "channel_id", "channel_key_idx", "upstream_file_id").Updates(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+func GetFileByFileId(fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	var file File
+	err := DB.Where("file_id = ?", fileId).First(&file).Error
+	return &file, err
+}
+
+// GetUserFileByFileId 查询时校验归属，避免跨用户访问文件
+func GetUserFileByFileId(userId int, fileId string) (*File, error) {
+	if fileId == "" || userId == 0 {
+		return nil, errors.New("file id 或 userId 为空！")
+	}
+	var file File
+	err := DB.Where("file_id = ? AND user_id = ?", fileId, userId).First(&file).Error
+	return &file, err
+}
+
+// GetUserFileByUpstreamId 根据上游文件 id 反查网关文件
+func GetUserFileByUpstreamId(userId int, channelId int, upstreamFileId string) (*File, error) {
+	var file File
+	err := DB.Where("user_id = ? AND channel_id = ? AND upstream_file_id = ?", userId, channelId, upstreamFileId).First(&file).Error
+	return &file, err
+}
+
+// GetUserFiles 按创建时间倒序列出文件，after 为上一页最后一个 file id
+func GetUserFiles(userId int, purpose string, after string, limit int) ([]*File, error) {
+	var files []*File
+	tx := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		tx = tx.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		cursor, err := GetUserFileByFileId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("id < ?", cursor.Id)
+	}
+	err := tx.Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/constant"
 	"one-api/logger"
 	"one-api/types"
 	"os"
@@ -33,6 +35,7 @@ type Log struct {
 	ChannelId        int    `json:"channel" gorm:"index"`
 	ChannelName      string `json:"channel_name" gorm:"->"`
 	TokenId          int    `json:"token_id" gorm:"default:0;index"`
+	OrgId            int    `json:"org_id" gorm:"default:0;index"`
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
@@ -94,10 +97,47 @@ func RecordLog(userId int, logType int, content string) {
 	}
 }
 
+// withCaptureRequestId 记录了请求内容时在日志中保存 request_id，用于在日志详情中查询
+func withCaptureRequestId(c *gin.Context, other map[string]interface{}) map[string]interface{} {
+	if _, ok := common.GetContextKey(c, constant.ContextKeyBodyCapture); !ok {
+		return other
+	}
+	if other == nil {
+		other = make(map[string]interface{})
+	}
+	other["request_id"] = c.GetString(common.RequestIdKey)
+	return other
+}
+
+// withModerationVerdicts 请求被内容审核标记时在日志中保存审核结果
+func withModerationVerdicts(c *gin.Context, other map[string]interface{}) map[string]interface{} {
+	verdicts, ok := common.GetContextKey(c, constant.ContextKeyModerationVerdicts)
+	if !ok {
+		return other
+	}
+	if other == nil {
+		other = make(map[string]interface{})
+	}
+	other["moderation"] = verdicts
+	return other
+}
+
+// GetLogById 管理员查看日志详情
+func GetLogById(id int) (*Log, error) {
+	var log Log
+	err := LOG_DB.Where("logs.id = ?", id).First(&log).Error
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
 func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string, tokenName string, content string, tokenId int, useTimeSeconds int,
 	isStream bool, group string, other map[string]interface{}) {
 	logger.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
 	username := c.GetString("username")
+	other = withCaptureRequestId(c, other)
+	other = withModerationVerdicts(c, other)
 	otherStr := common.MapToJsonStr(other)
 	// 判断是否需要记录 IP
 	needRecordIp := false
@@ -119,6 +159,7 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		Quota:            0,
 		ChannelId:        channelId,
 		TokenId:          tokenId,
+		OrgId:            common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
 		UseTime:          useTimeSeconds,
 		IsStream:         isStream,
 		Group:            group,
@@ -148,15 +189,23 @@ type RecordConsumeLogParams struct {
 	UseTimeSeconds   int                    `json:"use_time_seconds"`
 	IsStream         bool                   `json:"is_stream"`
 	Group            string                 `json:"group"`
+	OrgId            int                    `json:"org_id"` // 为 0 时使用请求中组织令牌的组织
 	Other            map[string]interface{} `json:"other"`
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	metrics.RecordQuotaConsumed(params.ChannelId, params.ModelName, params.Group, common.GetContextKeyString(c, constant.ContextKeyRelayFormat),
+		params.Quota, params.PromptTokens, params.CompletionTokens)
 	if !common.LogConsumeEnabled {
 		return
 	}
 	logger.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
 	username := c.GetString("username")
+	if params.OrgId == 0 {
+		params.OrgId = common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId)
+	}
+	params.Other = withCaptureRequestId(c, params.Other)
+	params.Other = withModerationVerdicts(c, params.Other)
 	otherStr := common.MapToJsonStr(params.Other)
 	// 判断是否需要记录 IP
 	needRecordIp := false
@@ -178,6 +227,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		Quota:            params.Quota,
 		ChannelId:        params.ChannelId,
 		TokenId:          params.TokenId,
+		OrgId:            params.OrgId,
 		UseTime:          params.UseTimeSeconds,
 		IsStream:         params.IsStream,
 		Group:            params.Group,
@@ -195,12 +245,12 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 	if common.DataExportEnabled {
 		gopool.Go(func() {
-			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
+			LogQuotaData(userId, params.OrgId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
 		})
 	}
 }
 
-func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
+func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string, orgId int) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
 		tx = LOG_DB
@@ -229,6 +279,9 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	if group != "" {
 		tx = tx.Where("logs."+logGroupCol+" = ?", group)
 	}
+	if orgId != 0 {
+		tx = tx.Where("logs.org_id = ?", orgId)
+	}
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -322,6 +375,16 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	return logs, total, err
 }
 
+// GetOrganizationLogs 组织成员查看组织令牌产生的日志，与用户日志一样隐藏渠道等管理员信息
+func GetOrganizationLogs(orgId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	logs, total, err = GetAllLogs(logType, startTimestamp, endTimestamp, modelName, username, tokenName, startIdx, num, 0, "", orgId)
+	if err != nil {
+		return nil, 0, err
+	}
+	formatUserLogs(logs)
+	return logs, total, nil
+}
+
 func SearchAllLogs(keyword string) (logs []*Log, err error) {
 	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	return logs, err
@@ -340,7 +403,7 @@ type Stat struct {
 	CallCount int `json:"call_count"`
 }
 
-func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
+func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string, orgId int) (stat Stat) {
 	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
 
 	// 为rpm和tpm创建单独的查询
@@ -383,6 +446,11 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 		rpmTpmQuery = rpmTpmQuery.Where(logGroupCol+" = ?", group)
 		callCountQuery = callCountQuery.Where(logGroupCol+" = ?", group)
 	}
+	if orgId != 0 {
+		tx = tx.Where("org_id = ?", orgId)
+		rpmTpmQuery = rpmTpmQuery.Where("org_id = ?", orgId)
+		callCountQuery = callCountQuery.Where("org_id = ?", orgId)
+	}
 
 	tx = tx.Where("type = ?", LogTypeConsume)
 	rpmTpmQuery = rpmTpmQuery.Where("type = ?", LogTypeConsume)
@@ -82,7 +82,9 @@ func createRootAccountIfNeed() error {
 			AccessToken: nil,
 			Quota:       100000000,
 		}
-		DB.Create(&rootUser)
+		if err := CreateUserWithQuotaLedger(&rootUser); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -267,11 +269,17 @@ func migrateDB() error {
 		&TwoFA{},
 		&TwoFABackupCode{},
 		&CheckIn{},
+		&File{},
+		&Batch{},
+		&ChannelTestResult{},
+		&QuotaLedger{},
+		&Organization{},
+		&OrganizationMember{},
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	return seedQuotaLedgerOpening()
 }
 
 func migrateDBFast() error {
@@ -300,6 +308,12 @@ func migrateDBFast() error {
 		{&Setup{}, "Setup"},
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&File{}, "File"},
+		{&Batch{}, "Batch"},
+		{&ChannelTestResult{}, "ChannelTestResult"},
+		{&QuotaLedger{}, "QuotaLedger"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -325,12 +339,12 @@ func migrateDBFast() error {
 		}
 	}
 	common.SysLog("database migrated")
-	return nil
+	return seedQuotaLedgerOpening()
 }
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &RequestCapture{}); err != nil {
 		return err
 	}
 	return nil
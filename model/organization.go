@@ -0,0 +1,322 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strconv"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 组织成员角色
+const (
+	OrganizationRoleOwner   = "owner"   // 管理组织、成员与额度
+	OrganizationRoleAdmin   = "admin"   // 管理普通成员，使用组织令牌
+	OrganizationRoleMember  = "member"  // 使用组织令牌
+	OrganizationRoleBilling = "billing" // 查看用量与日志，向组织转入额度
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+// Organization 组织，成员共享组织的额度，组织令牌产生的费用从组织额度中扣除
+type Organization struct {
+	Id          int            `json:"id"`
+	Name        string         `json:"name" gorm:"type:varchar(64);index"`
+	Status      int            `json:"status" gorm:"type:int;default:1"`
+	Quota       int            `json:"quota" gorm:"type:int;default:0"`
+	UsedQuota   int            `json:"used_quota" gorm:"type:int;default:0"`
+	CreatedTime int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员可使用的组织额度上限，0 表示不限制
+type OrganizationMember struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id" gorm:"uniqueIndex:idx_org_member"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_org_member;index"`
+	Role        string `json:"role" gorm:"type:varchar(16)"`
+	QuotaLimit  int    `json:"quota_limit" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	Username    string `json:"username" gorm:"->;-:migration"`
+}
+
+// UserOrganization 用户所在的组织及其在组织中的角色
+type UserOrganization struct {
+	Organization
+	Role            string `json:"role"`
+	QuotaLimit      int    `json:"quota_limit"`
+	MemberUsedQuota int    `json:"member_used_quota"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember, OrganizationRoleBilling:
+		return true
+	}
+	return false
+}
+
+func (m *OrganizationMember) CanManageMembers() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin
+}
+
+func (m *OrganizationMember) CanIssueTokens() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin || m.Role == OrganizationRoleMember
+}
+
+func (m *OrganizationMember) CanViewBilling() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin || m.Role == OrganizationRoleBilling
+}
+
+func (m *OrganizationMember) CanTransferQuota() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleBilling
+}
+
+// CreateOrganization 创建组织并将 ownerId 设为所有者
+func CreateOrganization(org *Organization, ownerId int) error {
+	org.CreatedTime = common.GetTimestamp()
+	if org.Status == 0 {
+		org.Status = OrganizationStatusEnabled
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrgId:       org.Id,
+			UserId:      ownerId,
+			Role:        OrganizationRoleOwner,
+			CreatedTime: org.CreatedTime,
+		}).Error
+	})
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	org := &Organization{}
+	err := DB.First(org, "id = ?", id).Error
+	return org, err
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+func GetUserOrganizations(userId int) (orgs []*UserOrganization, err error) {
+	err = DB.Model(&Organization{}).
+		Select("organizations.*, organization_members.role, organization_members.quota_limit, organization_members.used_quota AS member_used_quota").
+		Joins("JOIN organization_members ON organization_members.org_id = organizations.id").
+		Where("organization_members.user_id = ?", userId).
+		Order("organizations.id desc").
+		Find(&orgs).Error
+	return orgs, err
+}
+
+// Update 更新名称与状态，额度通过 AdjustOrganizationQuota 修改
+func (org *Organization) Update() error {
+	return DB.Model(org).Select("name", "status").Updates(org).Error
+}
+
+// DeleteOrganization 删除组织与成员，组织令牌随之失效
+func DeleteOrganization(id int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, "id = ?", id).Error
+	})
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	member := &OrganizationMember{}
+	err := DB.Where("org_id = ? AND user_id = ?", orgId, userId).First(member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("不是该组织的成员")
+	}
+	return member, err
+}
+
+func GetOrganizationMembers(orgId int) (members []*OrganizationMember, err error) {
+	err = DB.Model(&OrganizationMember{}).
+		Select("organization_members.*, users.username").
+		Joins("LEFT JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.org_id = ?", orgId).
+		Order("organization_members.id").
+		Find(&members).Error
+	return members, err
+}
+
+func CountOrganizationOwners(orgId int) (count int64, err error) {
+	err = DB.Model(&OrganizationMember{}).Where("org_id = ? AND role = ?", orgId, OrganizationRoleOwner).Count(&count).Error
+	return count, err
+}
+
+func (m *OrganizationMember) Insert() error {
+	m.CreatedTime = common.GetTimestamp()
+	return DB.Create(m).Error
+}
+
+// Update 更新角色与额度上限
+func (m *OrganizationMember) Update() error {
+	return DB.Model(m).Select("role", "quota_limit").Updates(m).Error
+}
+
+func RemoveOrganizationMember(orgId int, userId int) error {
+	return DB.Where("org_id = ? AND user_id = ?", orgId, userId).Delete(&OrganizationMember{}).Error
+}
+
+// GetOrganizationAvailableQuota 返回成员可使用的组织额度，组织额度与成员剩余上限取较小者
+func GetOrganizationAvailableQuota(orgId int, userId int) (int, error) {
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return 0, err
+	}
+	if org.Status != OrganizationStatusEnabled {
+		return 0, errors.New("组织已被禁用")
+	}
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return 0, err
+	}
+	if !member.CanIssueTokens() {
+		return 0, errors.New("该角色不能使用组织令牌")
+	}
+	available := org.Quota
+	if member.QuotaLimit > 0 {
+		available = min(available, member.QuotaLimit-member.UsedQuota)
+	}
+	return available, nil
+}
+
+// changeOrganizationQuota 修改组织额度并记录账本；countUsed 为 true 时同时计入组织与成员的已用额度
+func changeOrganizationQuota(orgId int, userId int, delta int, countUsed bool, source QuotaLedgerSource) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"quota": gorm.Expr("quota + ?", delta)}
+		if countUsed {
+			updates["used_quota"] = gorm.Expr("used_quota - ?", delta)
+		}
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(updates).Error; err != nil {
+			return err
+		}
+		if countUsed && userId != 0 {
+			err := tx.Model(&OrganizationMember{}).Where("org_id = ? AND user_id = ?", orgId, userId).
+				Update("used_quota", gorm.Expr("used_quota - ?", delta)).Error
+			if err != nil {
+				return err
+			}
+		}
+		ledger := source.newLedger(userId, delta)
+		ledger.OrgId = orgId
+		return insertQuotaLedgers(tx, &Organization{}, orgId, []*QuotaLedger{ledger})
+	})
+}
+
+// DecreaseOrganizationQuota 组织令牌消费时扣除组织额度
+func DecreaseOrganizationQuota(orgId int, userId int, quota int, source QuotaLedgerSource) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return changeOrganizationQuota(orgId, userId, -quota, true, source)
+}
+
+// IncreaseOrganizationQuota 退还组织令牌消费的额度
+func IncreaseOrganizationQuota(orgId int, userId int, quota int, source QuotaLedgerSource) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return changeOrganizationQuota(orgId, userId, quota, true, source)
+}
+
+// RefundQuota 退还额度，orgId 不为 0 时退还到组织
+func RefundQuota(userId int, orgId int, quota int, source QuotaLedgerSource) error {
+	if orgId != 0 {
+		return IncreaseOrganizationQuota(orgId, userId, quota, source)
+	}
+	return IncreaseUserQuota(userId, quota, false, source)
+}
+
+// AdjustOrganizationQuota 管理员直接设置组织额度
+func AdjustOrganizationQuota(orgId int, quota int, operatorId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		org := &Organization{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(org, "id = ?", orgId).Error; err != nil {
+			return err
+		}
+		oldQuota := org.Quota
+		if oldQuota == quota {
+			return nil
+		}
+		if err := tx.Model(org).Update("quota", quota).Error; err != nil {
+			return err
+		}
+		ledger := QuotaLedgerSource{Type: QuotaLedgerTypeAdminAdjust, OperatorId: operatorId}.newLedger(0, quota-oldQuota)
+		ledger.OrgId = orgId
+		return insertQuotaLedgers(tx, &Organization{}, orgId, []*QuotaLedger{ledger})
+	})
+}
+
+// TransferQuotaToOrganization 将用户自己的额度转入组织
+func TransferQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	// 先写入暂存的扣费，余额判断以数据库为准
+	if common.BatchUpdateEnabled {
+		if err := flushUserQuotaRecord(userId); err != nil {
+			return err
+		}
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).Where("id = ? AND status = ?", orgId, OrganizationStatusEnabled).
+			Update("quota", gorm.Expr("quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("组织不存在或已被禁用")
+		}
+		// 在更新语句中判断余额，并发转入时不会扣成负数
+		result = tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户额度不足")
+		}
+		source := QuotaLedgerSource{Type: QuotaLedgerTypeOrgTransfer, OperatorId: userId}
+		source.SourceRef = strconv.Itoa(orgId)
+		if err := recordQuotaLedgerTx(tx, userId, -quota, source); err != nil {
+			return err
+		}
+		source.SourceRef = strconv.Itoa(userId)
+		ledger := source.newLedger(userId, quota)
+		ledger.OrgId = orgId
+		return insertQuotaLedgers(tx, &Organization{}, orgId, []*QuotaLedger{ledger})
+	})
+	if err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+			common.SysLog(fmt.Sprintf("failed to decrease user quota cache: %s", err.Error()))
+		}
+	})
+	return nil
+}
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 账本记录类型
+const (
+	QuotaLedgerTypeOpening     = iota + 1 // 启用账本时的期初余额
+	QuotaLedgerTypeRegister               // 注册赠送
+	QuotaLedgerTypeInvite                 // 使用邀请码赠送
+	QuotaLedgerTypeTopUp                  // 在线充值
+	QuotaLedgerTypeRedemption             // 兑换码
+	QuotaLedgerTypeCheckin                // 签到
+	QuotaLedgerTypeAffTransfer            // 邀请额度转入
+	QuotaLedgerTypeConsume                // 消费，包括预扣费与结算时的补扣或退还
+	QuotaLedgerTypeRefund                 // 请求或任务失败后退还
+	QuotaLedgerTypeAdminAdjust            // 管理员修改额度
+	QuotaLedgerTypeOrgTransfer            // 用户额度转入组织
+)
+
+// QuotaLedger 用户与组织额度的变动记录，只追加不修改。OrgId 不为 0 时 Delta 与 BalanceAfter 是组织额度，
+// UserId 为产生变动的成员。同一用户 OrgId 为 0 的记录的 Delta 之和应等于 User.Quota，同一组织记录的 Delta 之和应等于 Organization.Quota，
+// 同一令牌所有记录的 TokenUsedDelta 之和应等于 Token.UsedQuota
+type QuotaLedger struct {
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	OrgId          int    `json:"org_id" gorm:"index;default:0"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	Type           int    `json:"type" gorm:"index"`
+	Delta          int    `json:"delta"`
+	BalanceAfter   int    `json:"balance_after"`
+	TokenId        int    `json:"token_id" gorm:"index;default:0"`
+	TokenUsedDelta int    `json:"token_used_delta"`                          // 令牌已用额度的变化
+	SourceRef      string `json:"source_ref" gorm:"type:varchar(128);index"` // 订单号、兑换码 ID、请求 ID 等
+	OperatorId     int    `json:"operator_id"`                               // 0 表示系统
+	Remark         string `json:"remark"`
+}
+
+// QuotaLedgerSource 额度变动的来源，由修改额度的调用方提供
+type QuotaLedgerSource struct {
+	Type           int
+	TokenId        int
+	TokenUsedDelta int
+	SourceRef      string
+	OperatorId     int
+	Remark         string
+}
+
+func (source QuotaLedgerSource) newLedger(userId int, delta int) *QuotaLedger {
+	return &QuotaLedger{
+		UserId:         userId,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           source.Type,
+		Delta:          delta,
+		TokenId:        source.TokenId,
+		TokenUsedDelta: source.TokenUsedDelta,
+		SourceRef:      source.SourceRef,
+		OperatorId:     source.OperatorId,
+		Remark:         source.Remark,
+	}
+}
+
+// insertQuotaLedgers 在修改额度的事务中写入记录，balanceModel 为 &User{} 或 &Organization{}。
+// entries 按发生顺序排列，最后一条的余额为当前余额，之前的依次倒推
+func insertQuotaLedgers(tx *gorm.DB, balanceModel any, id int, entries []*QuotaLedger) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var balance int
+	if err := tx.Model(balanceModel).Where("id = ?", id).Select("quota").Find(&balance).Error; err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].BalanceAfter = balance
+		balance -= entries[i].Delta
+	}
+	return tx.Create(entries).Error
+}
+
+// recordQuotaLedgerTx 在已修改额度的事务中记录一次变动
+func recordQuotaLedgerTx(tx *gorm.DB, userId int, delta int, source QuotaLedgerSource) error {
+	return insertQuotaLedgers(tx, &User{}, userId, []*QuotaLedger{source.newLedger(userId, delta)})
+}
+
+// updateUserQuotaWithLedgers 修改用户额度并写入对应的记录，delta 为 entries 的 Delta 之和
+func updateUserQuotaWithLedgers(userId int, delta int, entries []*QuotaLedger) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", delta)).Error; err != nil {
+			return err
+		}
+		return insertQuotaLedgers(tx, &User{}, userId, entries)
+	})
+}
+
+// CreateUserWithQuotaLedger 在同一事务中创建用户并记录初始额度，如注册赠送、创建初始管理员
+func CreateUserWithQuotaLedger(user *User) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if user.Quota == 0 {
+			return nil
+		}
+		ledger := QuotaLedgerSource{Type: QuotaLedgerTypeRegister}.newLedger(user.Id, user.Quota)
+		ledger.BalanceAfter = user.Quota
+		return tx.Create(ledger).Error
+	})
+}
+
+// seedQuotaLedgerOpening 账本为空时为已有的用户与令牌写入期初记录，之后的变动都在账本中追加。
+// 在同一事务中写入，部分失败时账本保持为空，下次启动重新写入
+func seedQuotaLedgerOpening() error {
+	var count int64
+	if err := DB.Model(&QuotaLedger{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var users []User
+		err := tx.Select("id", "quota").Where("quota <> 0").FindInBatches(&users, 500, func(_ *gorm.DB, batch int) error {
+			entries := make([]*QuotaLedger, 0, len(users))
+			for _, user := range users {
+				entries = append(entries, &QuotaLedger{
+					UserId:       user.Id,
+					CreatedAt:    now,
+					Type:         QuotaLedgerTypeOpening,
+					Delta:        user.Quota,
+					BalanceAfter: user.Quota,
+				})
+			}
+			return tx.Create(entries).Error
+		}).Error
+		if err != nil {
+			return err
+		}
+		var tokens []Token
+		return tx.Select("id", "user_id", "used_quota").Where("used_quota <> 0").FindInBatches(&tokens, 500, func(_ *gorm.DB, batch int) error {
+			entries := make([]*QuotaLedger, 0, len(tokens))
+			for _, token := range tokens {
+				entries = append(entries, &QuotaLedger{
+					UserId:         token.UserId,
+					CreatedAt:      now,
+					Type:           QuotaLedgerTypeOpening,
+					TokenId:        token.Id,
+					TokenUsedDelta: token.UsedQuota,
+				})
+			}
+			return tx.Create(entries).Error
+		}).Error
+	})
+}
+
+// GetQuotaLedgers 按条件分页查询记录，userId、orgId、tokenId、ledgerType 为 0 时不过滤
+func GetQuotaLedgers(userId int, orgId int, tokenId int, ledgerType int, startTimestamp int64, endTimestamp int64, startIdx int, num int) (ledgers []*QuotaLedger, total int64, err error) {
+	tx := DB.Model(&QuotaLedger{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if orgId != 0 {
+		tx = tx.Where("org_id = ?", orgId)
+	}
+	if tokenId != 0 {
+		tx = tx.Where("token_id = ?", tokenId)
+	}
+	if ledgerType != 0 {
+		tx = tx.Where("type = ?", ledgerType)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&ledgers).Error
+	return ledgers, total, err
+}
+
+// QuotaDrift 账本合计与实际额度不一致的用户或令牌
+type QuotaDrift struct {
+	UserId   int `json:"user_id,omitempty"`
+	OrgId    int `json:"org_id,omitempty"`
+	TokenId  int `json:"token_id,omitempty"`
+	Expected int `json:"expected"` // 账本合计
+	Actual   int `json:"actual"`
+	Drift    int `json:"drift"` // Actual - Expected
+}
+
+// GetUserQuotaDrifts 比较每个用户的 Delta 之和与 User.Quota，组织额度的变动不计入用户
+func GetUserQuotaDrifts() ([]QuotaDrift, error) {
+	sums := DB.Model(&QuotaLedger{}).Select("user_id, SUM(delta) AS total").Where("org_id = 0").Group("user_id")
+	var drifts []QuotaDrift
+	err := DB.Model(&User{}).
+		Select("users.id AS user_id, COALESCE(l.total, 0) AS expected, users.quota AS actual, users.quota - COALESCE(l.total, 0) AS drift").
+		Joins("LEFT JOIN (?) AS l ON l.user_id = users.id", sums).
+		Where("users.quota <> COALESCE(l.total, 0)").
+		Scan(&drifts).Error
+	return drifts, err
+}
+
+// GetTokenUsedQuotaDrifts 比较每个令牌的 TokenUsedDelta 之和与 Token.UsedQuota
+func GetTokenUsedQuotaDrifts() ([]QuotaDrift, error) {
+	sums := DB.Model(&QuotaLedger{}).Select("token_id, SUM(token_used_delta) AS total").Where("token_id <> 0").Group("token_id")
+	var drifts []QuotaDrift
+	err := DB.Model(&Token{}).
+		Select("tokens.user_id AS user_id, tokens.id AS token_id, COALESCE(l.total, 0) AS expected, tokens.used_quota AS actual, tokens.used_quota - COALESCE(l.total, 0) AS drift").
+		Joins("LEFT JOIN (?) AS l ON l.token_id = tokens.id", sums).
+		Where("tokens.used_quota <> COALESCE(l.total, 0)").
+		Scan(&drifts).Error
+	return drifts, err
+}
+
+// GetOrganizationQuotaDrifts 比较每个组织的 Delta 之和与 Organization.Quota
+func GetOrganizationQuotaDrifts() ([]QuotaDrift, error) {
+	sums := DB.Model(&QuotaLedger{}).Select("org_id, SUM(delta) AS total").Where("org_id <> 0").Group("org_id")
+	var drifts []QuotaDrift
+	err := DB.Model(&Organization{}).
+		Select("organizations.id AS org_id, COALESCE(l.total, 0) AS expected, organizations.quota AS actual, organizations.quota - COALESCE(l.total, 0) AS drift").
+		Joins("LEFT JOIN (?) AS l ON l.org_id = organizations.id", sums).
+		Where("organizations.quota <> COALESCE(l.total, 0)").
+		Scan(&drifts).Error
+	return drifts, err
+}
@@ -245,6 +245,14 @@ func Redeem(key string, userId int) (quota int, err error) {
 			if err != nil {
 				return err
 			}
+			err = recordQuotaLedgerTx(tx, userId, redemption.Quota, QuotaLedgerSource{
+				Type:       QuotaLedgerTypeRedemption,
+				SourceRef:  strconv.Itoa(redemption.Id),
+				OperatorId: userId,
+			})
+			if err != nil {
+				return err
+			}
 			redemption.RedeemedTime = common.GetTimestamp()
 			redemption.Status = common.RedemptionCodeStatusUsed
 			redemption.UsedUserId = userId
@@ -276,6 +284,14 @@ func Redeem(key string, userId int) (quota int, err error) {
 			if err != nil {
 				return err
 			}
+			err = recordQuotaLedgerTx(tx, userId, redemption.Quota, QuotaLedgerSource{
+				Type:       QuotaLedgerTypeRedemption,
+				SourceRef:  strconv.Itoa(redemption.Id),
+				OperatorId: userId,
+			})
+			if err != nil {
+				return err
+			}
 
 			// 更新礼品码使用信息
 			redemption.UsedCount++
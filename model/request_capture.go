@@ -0,0 +1,40 @@
+package model
+
+// RequestCapture 调试用的请求与响应内容，与日志分开存放在日志库中，按 request_id 关联日志
+type RequestCapture struct {
+	Id               int    `json:"id"`
+	RequestId        string `json:"request_id" gorm:"type:varchar(64);index"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id"`
+	ChannelId        int    `json:"channel_id"`
+	ModelName        string `json:"model_name"`
+	IsStream         bool   `json:"is_stream"`
+	RequestPath      string `json:"request_path"`
+	RelayFormat      string `json:"relay_format" gorm:"type:varchar(32)"`
+	ClientRequest    string `json:"client_request" gorm:"type:text"`
+	UpstreamRequest  string `json:"upstream_request" gorm:"type:text"`
+	UpstreamResponse string `json:"upstream_response" gorm:"type:text"`
+	// 流式响应拼接后的完整文本
+	ResponseText string `json:"response_text" gorm:"type:text"`
+	Truncated    bool   `json:"truncated"`
+}
+
+func CreateRequestCapture(capture *RequestCapture) error {
+	return LOG_DB.Create(capture).Error
+}
+
+func GetRequestCaptureByRequestId(requestId string) (*RequestCapture, error) {
+	var capture RequestCapture
+	err := LOG_DB.Where("request_id = ?", requestId).Order("id desc").First(&capture).Error
+	if err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// DeleteRequestCapturesBefore 删除早于指定时间的记录，返回删除的行数
+func DeleteRequestCapturesBefore(timestamp int64) (int64, error) {
+	result := LOG_DB.Where("created_at < ?", timestamp).Delete(&RequestCapture{})
+	return result.RowsAffected, result.Error
+}
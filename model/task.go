@@ -53,6 +53,11 @@ func (t *Task) GetData(v any) error {
 
 type Properties struct {
 	Input string `json:"input"`
+	// 以下字段用于任务完成后按实际用量结算
+	TokenId  int    `json:"token_id,omitempty"`
+	Group    string `json:"group,omitempty"`
+	KeyIndex int    `json:"key_index,omitempty"` // 多密钥渠道提交任务时使用的密钥索引
+	OrgId    int    `json:"org_id,omitempty"`    // 组织令牌提交的任务，失败时退还到组织
 }
 
 func (m *Properties) Scan(val interface{}) error {
@@ -85,6 +90,9 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.RelayInfo)
 		Progress:   "0%",
 		ChannelId:  relayInfo.ChannelId,
 		Platform:   platform,
+		Properties: Properties{
+			OrgId: relayInfo.OrgId,
+		},
 	}
 	return t
 }
@@ -210,6 +218,23 @@ func GetByTaskId(userId int, taskId string) (*Task, bool, error) {
 	return task, exist, err
 }
 
+// GetUserPlatformTasks 按提交顺序倒序列出用户某平台的任务，after 为上一页最后一个 task id
+func GetUserPlatformTasks(userId int, platform constant.TaskPlatform, after string, limit int) ([]*Task, error) {
+	var tasks []*Task
+	query := DB.Where("user_id = ? and platform = ?", userId, platform)
+	if after != "" {
+		cursor, exist, err := GetByTaskId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			query = query.Where("id < ?", cursor.ID)
+		}
+	}
+	err := query.Order("id desc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
 func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	if len(taskIds) == 0 {
 		return nil, nil
@@ -88,7 +88,10 @@ func Recharge(referenceId string, customerId string) (err error) {
 			return err
 		}
 
-		return nil
+		return recordQuotaLedgerTx(tx, topUp.UserId, int(quota), QuotaLedgerSource{
+			Type:      QuotaLedgerTypeTopUp,
+			SourceRef: topUp.TradeNo,
+		})
 	})
 
 	if err != nil {
@@ -22,6 +22,9 @@ const (
 var batchUpdateStores []map[int]int
 var batchUpdateLocks []sync.Mutex
 
+// 与 BatchUpdateTypeUserQuota 一起写入的账本记录，使用同一把锁
+var batchQuotaLedgers = make(map[int][]*QuotaLedger)
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int))
@@ -48,6 +51,33 @@ func addNewRecord(type_ int, id int, value int) {
 	}
 }
 
+// addUserQuotaRecord 暂存用户额度的变动及其账本记录，刷新时在同一事务中写入
+func addUserQuotaRecord(id int, value int, ledger *QuotaLedger) {
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	defer batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	batchUpdateStores[BatchUpdateTypeUserQuota][id] += value
+	batchQuotaLedgers[id] = append(batchQuotaLedgers[id], ledger)
+}
+
+// FlushBatchUpdate 立即写入本节点暂存的所有变动
+func FlushBatchUpdate() {
+	batchUpdate()
+}
+
+// flushUserQuotaRecord 立即写入某个用户暂存的额度变动，用于需要按数据库余额判断的操作
+func flushUserQuotaRecord(id int) error {
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	value, ok := batchUpdateStores[BatchUpdateTypeUserQuota][id]
+	ledgers := batchQuotaLedgers[id]
+	delete(batchUpdateStores[BatchUpdateTypeUserQuota], id)
+	delete(batchQuotaLedgers, id)
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	if !ok {
+		return nil
+	}
+	return updateUserQuotaWithLedgers(id, value, ledgers)
+}
+
 func batchUpdate() {
 	// check if there's any data to update
 	hasData := false
@@ -70,12 +100,17 @@ func batchUpdate() {
 		batchUpdateLocks[i].Lock()
 		store := batchUpdateStores[i]
 		batchUpdateStores[i] = make(map[int]int)
+		var ledgers map[int][]*QuotaLedger
+		if i == BatchUpdateTypeUserQuota {
+			ledgers = batchQuotaLedgers
+			batchQuotaLedgers = make(map[int][]*QuotaLedger)
+		}
 		batchUpdateLocks[i].Unlock()
 		// TODO: maybe we can combine updates with same key?
 		for key, value := range store {
 			switch i {
 			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
+				err := updateUserQuotaWithLedgers(key, value, ledgers[key])
 				if err != nil {
 					common.SysLog("failed to batch update user quota: " + err.Error())
 				}
@@ -2,11 +2,14 @@ package aws
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"one-api/dto"
 	"one-api/relay/channel/claude"
 	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
 	"one-api/setting/model_setting"
 	"one-api/types"
 
@@ -16,6 +19,7 @@ import (
 const (
 	RequestModeCompletion = 1
 	RequestModeMessage    = 2
+	RequestModeConverse   = 3
 )
 
 type Adaptor struct {
@@ -28,6 +32,13 @@ func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dt
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	if !isClaudeModel(awsBaseModelID(info, request.Model)) {
+		openAIRequest, err := service.ClaudeToOpenAIRequest(*request, info)
+		if err != nil {
+			return nil, err
+		}
+		return a.convertConverseRequest(c, openAIRequest)
+	}
 	c.Set("request_model", request.Model)
 	c.Set("converted_request", request)
 	return request, nil
@@ -64,16 +75,10 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if request == nil {
 		return nil, errors.New("request is nil")
 	}
-	// 检查是否为Nova模型
-	if isNovaModel(request.Model) {
-		novaReq := convertToNovaRequest(request)
-		c.Set("request_model", request.Model)
-		c.Set("converted_request", novaReq)
-		c.Set("is_nova_model", true)
-		return novaReq, nil
+	if !isClaudeModel(awsBaseModelID(info, request.Model)) {
+		return a.convertConverseRequest(c, request)
 	}
 
-	// 原有的Claude模型处理逻辑
 	var claudeReq *dto.ClaudeRequest
 	var err error
 	claudeReq, err = claude.RequestOpenAI2ClaudeMessage(c, *request)
@@ -82,17 +87,35 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	}
 	c.Set("request_model", claudeReq.Model)
 	c.Set("converted_request", claudeReq)
-	c.Set("is_nova_model", false)
 	return claudeReq, err
 }
 
+func (a *Adaptor) convertConverseRequest(c *gin.Context, request *dto.GeneralOpenAIRequest) (any, error) {
+	converseReq, err := requestOpenAI2Converse(c, request)
+	if err != nil {
+		return nil, err
+	}
+	a.RequestMode = RequestModeConverse
+	c.Set("request_model", request.Model)
+	c.Set("converted_request", converseReq)
+	return converseReq, nil
+}
+
 func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
 	return nil, nil
 }
 
 func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	awsModelId := awsBaseModelID(info, request.Model)
+	if !isTitanEmbeddingModel(awsModelId) && !isCohereEmbeddingModel(awsModelId) {
+		return nil, fmt.Errorf("embedding model %s is not supported, only Titan and Cohere embedding models are available", request.Model)
+	}
+	if len(request.ParseInput()) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	c.Set("request_model", request.Model)
+	c.Set("converted_request", &request)
+	return &request, nil
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
@@ -105,9 +128,16 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
-	if info.IsStream {
+	switch {
+	case info.RelayMode == relayconstant.RelayModeEmbeddings:
+		err, usage = awsEmbeddingHandler(c, info)
+	case a.RequestMode == RequestModeConverse && info.IsStream:
+		err, usage = converseStreamHandler(c, info)
+	case a.RequestMode == RequestModeConverse:
+		err, usage = converseHandler(c, info)
+	case info.IsStream:
 		err, usage = awsStreamHandler(c, resp, info, a.RequestMode)
-	} else {
+	default:
 		err, usage = awsHandler(c, info, a.RequestMode)
 	}
 	return
@@ -26,6 +26,18 @@ var awsModelIDMap = map[string]string{
 	"nova-reel-v1:0":    "amazon.nova-reel-v1:0",
 	"nova-reel-v1:1":    "amazon.nova-reel-v1:1",
 	"nova-sonic-v1:0":   "amazon.nova-sonic-v1:0",
+	// 其他模型通过 Converse 接口调用，未列出的 Bedrock modelId 也可以直接使用
+	"llama3-3-70b-instruct-v1:0":        "meta.llama3-3-70b-instruct-v1:0",
+	"llama4-maverick-17b-instruct-v1:0": "meta.llama4-maverick-17b-instruct-v1:0",
+	"mistral-large-2407-v1:0":           "mistral.mistral-large-2407-v1:0",
+	"pixtral-large-2502-v1:0":           "mistral.pixtral-large-2502-v1:0",
+	"command-r-plus-v1:0":               "cohere.command-r-plus-v1:0",
+	"deepseek-r1-v1:0":                  "deepseek.r1-v1:0",
+	// Embedding models
+	"titan-embed-text-v1":          "amazon.titan-embed-text-v1",
+	"titan-embed-text-v2:0":        "amazon.titan-embed-text-v2:0",
+	"cohere-embed-english-v3":      "cohere.embed-english-v3",
+	"cohere-embed-multilingual-v3": "cohere.embed-multilingual-v3",
 }
 
 var awsModelCanCrossRegionMap = map[string]map[string]bool{
@@ -112,6 +124,19 @@ var awsModelCanCrossRegionMap = map[string]map[string]bool{
 		"eu":   true,
 		"apac": true,
 	},
+	"meta.llama3-3-70b-instruct-v1:0": {
+		"us": true,
+	},
+	"meta.llama4-maverick-17b-instruct-v1:0": {
+		"us": true,
+	},
+	"mistral.pixtral-large-2502-v1:0": {
+		"us": true,
+		"eu": true,
+	},
+	"deepseek.r1-v1:0": {
+		"us": true,
+	},
 }
 
 var awsRegionCrossModelPrefixMap = map[string]string{
@@ -122,7 +147,21 @@ var awsRegionCrossModelPrefixMap = map[string]string{
 
 var ChannelName = "aws"
 
-// 判断是否为Nova模型
-func isNovaModel(modelId string) bool {
-	return strings.HasPrefix(modelId, "nova-")
+// isClaudeModel 判断 modelId 是否为 Anthropic 模型，包括跨区域 ID 与系统推理配置文件 ARN。
+// Claude 使用 InvokeModel 透传原生请求，其他模型走 Converse 接口
+func isClaudeModel(awsModelId string) bool {
+	return strings.Contains(awsModelId, "anthropic.")
+}
+
+func isTitanEmbeddingModel(awsModelId string) bool {
+	return strings.Contains(awsModelId, "amazon.titan-embed")
+}
+
+// isTitanEmbeddingV2Model 只有 Titan Text Embeddings v2 接受 dimensions 参数
+func isTitanEmbeddingV2Model(awsModelId string) bool {
+	return strings.Contains(awsModelId, "amazon.titan-embed-text-v2")
+}
+
+func isCohereEmbeddingModel(awsModelId string) bool {
+	return strings.Contains(awsModelId, "cohere.embed")
 }
@@ -1,7 +1,7 @@
 package aws
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"net/http"
 	"one-api/common"
@@ -9,6 +9,7 @@ import (
 	"one-api/relay/channel/claude"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
+	"one-api/service"
 	"one-api/types"
 	"strings"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	bedrockruntimeTypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/auth/bearer"
 )
 
@@ -57,6 +59,41 @@ func wrapErr(err error) *dto.OpenAIErrorWithStatusCode {
 	}
 }
 
+// awsErrorStatusCode 按 Bedrock 的错误类型返回对应的状态码，无法识别时返回 500
+func awsErrorStatusCode(err error) int {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return http.StatusInternalServerError
+	}
+	switch apiErr.(type) {
+	case *bedrockruntimeTypes.ValidationException:
+		return http.StatusBadRequest
+	case *bedrockruntimeTypes.AccessDeniedException:
+		return http.StatusForbidden
+	case *bedrockruntimeTypes.ResourceNotFoundException:
+		return http.StatusNotFound
+	case *bedrockruntimeTypes.ConflictException:
+		return http.StatusConflict
+	case *bedrockruntimeTypes.ModelTimeoutException:
+		return http.StatusRequestTimeout
+	case *bedrockruntimeTypes.ThrottlingException, *bedrockruntimeTypes.ServiceQuotaExceededException:
+		return http.StatusTooManyRequests
+	case *bedrockruntimeTypes.ModelNotReadyException, *bedrockruntimeTypes.ServiceUnavailableException:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func awsInvokeError(err error, operation string) *types.NewAPIError {
+	return types.NewOpenAIError(errors.Wrap(err, operation), types.ErrorCodeAwsInvokeError, awsErrorStatusCode(err))
+}
+
+// awsSSEData 将分块按 SSE 格式记录到请求记录中
+func awsSSEData(data string) []byte {
+	return []byte("data: " + data + "\n\n")
+}
+
 func awsRegionPrefix(awsRegionId string) string {
 	parts := strings.Split(awsRegionId, "-")
 	regionPrefix := ""
@@ -87,31 +124,34 @@ func awsModelID(requestModel string) string {
 	return requestModel
 }
 
+// awsBaseModelID 优先使用渠道配置的推理配置文件，否则按映射表转换，未收录的模型名原样作为 modelId
+func awsBaseModelID(info *relaycommon.RelayInfo, requestModel string) string {
+	if profile := info.ChannelOtherSettings.AwsInferenceProfiles[requestModel]; profile != "" {
+		return profile
+	}
+	return awsModelID(requestModel)
+}
+
+// awsRequestModelID 返回实际调用的 modelId，推理配置文件原样使用，其余模型在支持时加上跨区域前缀
+func awsRequestModelID(info *relaycommon.RelayInfo, requestModel string, region string) string {
+	if profile := info.ChannelOtherSettings.AwsInferenceProfiles[requestModel]; profile != "" {
+		return profile
+	}
+	awsModelId := awsModelID(requestModel)
+	awsRegionPrefix := awsRegionPrefix(region)
+	if awsModelCanCrossRegion(awsModelId, awsRegionPrefix) {
+		awsModelId = awsModelCrossRegion(awsModelId, awsRegionPrefix)
+	}
+	return awsModelId
+}
+
 func awsHandler(c *gin.Context, info *relaycommon.RelayInfo, requestMode int) (*types.NewAPIError, *dto.Usage) {
 	awsCli, err := newAwsClient(c, info)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
 	}
 
-	awsModelId := awsModelID(c.GetString("request_model"))
-	// 检查是否为Nova模型
-	isNova, _ := c.Get("is_nova_model")
-	if isNova == true {
-		// Nova模型也支持跨区域
-		awsRegionPrefix := awsRegionPrefix(awsCli.Options().Region)
-		canCrossRegion := awsModelCanCrossRegion(awsModelId, awsRegionPrefix)
-		if canCrossRegion {
-			awsModelId = awsModelCrossRegion(awsModelId, awsRegionPrefix)
-		}
-		return handleNovaRequest(c, awsCli, info, awsModelId)
-	}
-
-	// 原有的Claude处理逻辑
-	awsRegionPrefix := awsRegionPrefix(awsCli.Options().Region)
-	canCrossRegion := awsModelCanCrossRegion(awsModelId, awsRegionPrefix)
-	if canCrossRegion {
-		awsModelId = awsModelCrossRegion(awsModelId, awsRegionPrefix)
-	}
+	awsModelId := awsRequestModelID(info, c.GetString("request_model"), awsCli.Options().Region)
 
 	awsReq := &bedrockruntime.InvokeModelInput{
 		ModelId:     aws.String(awsModelId),
@@ -130,10 +170,13 @@ func awsHandler(c *gin.Context, info *relaycommon.RelayInfo, requestMode int) (*
 		return types.NewError(errors.Wrap(err, "marshal request"), types.ErrorCodeBadResponseBody), nil
 	}
 
+	service.CaptureUpstreamRequest(c, info.ChannelId, bytes.NewReader(awsReq.Body))
+
 	awsResp, err := awsCli.InvokeModel(c.Request.Context(), awsReq)
 	if err != nil {
-		return types.NewOpenAIError(errors.Wrap(err, "InvokeModel"), types.ErrorCodeAwsInvokeError, http.StatusInternalServerError), nil
+		return awsInvokeError(err, "InvokeModel"), nil
 	}
+	service.CaptureUpstreamResponseData(c, awsResp.Body, false)
 
 	claudeInfo := &claude.ClaudeResponseInfo{
 		ResponseId:   helper.GetResponseID(c),
@@ -161,13 +204,7 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
 	}
 
-	awsModelId := awsModelID(c.GetString("request_model"))
-
-	awsRegionPrefix := awsRegionPrefix(awsCli.Options().Region)
-	canCrossRegion := awsModelCanCrossRegion(awsModelId, awsRegionPrefix)
-	if canCrossRegion {
-		awsModelId = awsModelCrossRegion(awsModelId, awsRegionPrefix)
-	}
+	awsModelId := awsRequestModelID(info, c.GetString("request_model"), awsCli.Options().Region)
 
 	awsReq := &bedrockruntime.InvokeModelWithResponseStreamInput{
 		ModelId:     aws.String(awsModelId),
@@ -187,9 +224,11 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		return types.NewError(errors.Wrap(err, "marshal request"), types.ErrorCodeBadResponseBody), nil
 	}
 
+	service.CaptureUpstreamRequest(c, info.ChannelId, bytes.NewReader(awsReq.Body))
+
 	awsResp, err := awsCli.InvokeModelWithResponseStream(c.Request.Context(), awsReq)
 	if err != nil {
-		return types.NewOpenAIError(errors.Wrap(err, "InvokeModelWithResponseStream"), types.ErrorCodeAwsInvokeError, http.StatusInternalServerError), nil
+		return awsInvokeError(err, "InvokeModelWithResponseStream"), nil
 	}
 	stream := awsResp.GetStream()
 	defer stream.Close()
@@ -202,12 +241,26 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		Usage:        &dto.Usage{},
 	}
 
+	// SDK 直接返回事件，不经过 StreamScannerHandler，占位符还原与敏感词检测在这里处理
+	outputFilter := service.NewStreamOutputFilter(c)
+	handleChunks := func(chunks []string) *types.NewAPIError {
+		for _, chunk := range chunks {
+			if respErr := claude.HandleStreamResponseData(c, info, claudeInfo, chunk, RequestModeMessage); respErr != nil {
+				return respErr
+			}
+		}
+		return nil
+	}
+
+	stopped := false
 	for event := range stream.Events() {
 		switch v := event.(type) {
 		case *bedrockruntimeTypes.ResponseStreamMemberChunk:
 			info.SetFirstResponseTime()
-			respErr := claude.HandleStreamResponseData(c, info, claudeInfo, string(v.Value.Bytes), RequestModeMessage)
-			if respErr != nil {
+			service.CaptureUpstreamResponseData(c, awsSSEData(string(v.Value.Bytes)), true)
+			var ready []string
+			ready, stopped = outputFilter.Push(string(v.Value.Bytes))
+			if respErr := handleChunks(ready); respErr != nil {
 				return respErr, nil
 			}
 		case *bedrockruntimeTypes.UnknownUnionMember:
@@ -217,79 +270,108 @@ func awsStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 			fmt.Println("union is nil or unknown type")
 			return types.NewError(errors.New("nil or unknown response type"), types.ErrorCodeInvalidRequest), nil
 		}
+		if stopped {
+			break
+		}
+	}
+
+	if !stopped {
+		if respErr := handleChunks(outputFilter.Flush()); respErr != nil {
+			return respErr, nil
+		}
 	}
 
 	claude.HandleStreamFinalResponse(c, info, claudeInfo, RequestModeMessage)
 	return nil, claudeInfo.Usage
 }
 
-// Nova模型处理函数
-func handleNovaRequest(c *gin.Context, awsCli *bedrockruntime.Client, info *relaycommon.RelayInfo, awsModelId string) (*types.NewAPIError, *dto.Usage) {
-	novaReq_, ok := c.Get("converted_request")
-	if !ok {
-		return types.NewError(errors.New("nova request not found"), types.ErrorCodeInvalidRequest), nil
-	}
-	novaReq := novaReq_.(*NovaRequest)
+// cohereEmbeddingBatchSize Cohere Embed 单次调用最多接受的文本数
+const cohereEmbeddingBatchSize = 96
 
-	// 使用InvokeModel API，但使用Nova格式的请求体
-	awsReq := &bedrockruntime.InvokeModelInput{
+func invokeAwsModel(c *gin.Context, info *relaycommon.RelayInfo, awsCli *bedrockruntime.Client, awsModelId string, body any, out any) *types.NewAPIError {
+	reqBody, err := common.Marshal(body)
+	if err != nil {
+		return types.NewError(errors.Wrap(err, "marshal request"), types.ErrorCodeConvertRequestFailed)
+	}
+	service.CaptureUpstreamRequest(c, info.ChannelId, bytes.NewReader(reqBody))
+	awsResp, err := awsCli.InvokeModel(c.Request.Context(), &bedrockruntime.InvokeModelInput{
 		ModelId:     aws.String(awsModelId),
 		Accept:      aws.String("application/json"),
 		ContentType: aws.String("application/json"),
+		Body:        reqBody,
+	})
+	if err != nil {
+		return awsInvokeError(err, "InvokeModel")
 	}
+	service.CaptureUpstreamResponseData(c, awsResp.Body, false)
+	if err := common.Unmarshal(awsResp.Body, out); err != nil {
+		return types.NewOpenAIError(errors.Wrap(err, "unmarshal response"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	return nil
+}
 
-	reqBody, err := json.Marshal(novaReq)
+// awsEmbeddingHandler 通过 InvokeModel 调用 Titan 或 Cohere 向量模型，并转换为 OpenAI 格式
+func awsEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.Usage) {
+	awsCli, err := newAwsClient(c, info)
 	if err != nil {
-		return types.NewError(errors.Wrap(err, "marshal nova request"), types.ErrorCodeBadResponseBody), nil
+		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
+	}
+	request_, ok := c.Get("converted_request")
+	if !ok {
+		return types.NewError(errors.New("aws embedding request not found"), types.ErrorCodeInvalidRequest), nil
+	}
+	request := request_.(*dto.EmbeddingRequest)
+	awsModelId := awsRequestModelID(info, c.GetString("request_model"), awsCli.Options().Region)
+	inputs := request.ParseInput()
+
+	response := &dto.OpenAIEmbeddingResponse{
+		Object: "list",
+		Model:  info.UpstreamModelName,
+		Data:   make([]dto.OpenAIEmbeddingResponseItem, 0, len(inputs)),
+	}
+	if isCohereEmbeddingModel(awsModelId) {
+		for start := 0; start < len(inputs); start += cohereEmbeddingBatchSize {
+			end := min(start+cohereEmbeddingBatchSize, len(inputs))
+			var cohereResp CohereEmbeddingResponse
+			cohereReq := CohereEmbeddingRequest{Texts: inputs[start:end], InputType: "search_document", Truncate: "END"}
+			if apiErr := invokeAwsModel(c, info, awsCli, awsModelId, cohereReq, &cohereResp); apiErr != nil {
+				return apiErr, nil
+			}
+			for _, embedding := range cohereResp.Embeddings {
+				response.Data = append(response.Data, dto.OpenAIEmbeddingResponseItem{
+					Object:    "embedding",
+					Index:     len(response.Data),
+					Embedding: embedding,
+				})
+			}
+		}
+		// Cohere 不返回用量，按预估的输入 token 计费
+		response.Usage.PromptTokens = info.PromptTokens
+	} else {
+		for i, input := range inputs {
+			var titanResp TitanEmbeddingResponse
+			titanReq := TitanEmbeddingRequest{InputText: input}
+			if isTitanEmbeddingV2Model(awsModelId) {
+				titanReq.Dimensions = request.Dimensions
+			}
+			if apiErr := invokeAwsModel(c, info, awsCli, awsModelId, titanReq, &titanResp); apiErr != nil {
+				return apiErr, nil
+			}
+			response.Data = append(response.Data, dto.OpenAIEmbeddingResponseItem{
+				Object:    "embedding",
+				Index:     i,
+				Embedding: titanResp.Embedding,
+			})
+			response.Usage.PromptTokens += titanResp.InputTextTokenCount
+		}
 	}
-	awsReq.Body = reqBody
+	response.Usage.TotalTokens = response.Usage.PromptTokens
 
-	awsResp, err := awsCli.InvokeModel(c.Request.Context(), awsReq)
+	data, err := common.Marshal(response)
 	if err != nil {
-		return types.NewError(errors.Wrap(err, "InvokeModel"), types.ErrorCodeChannelAwsClientError), nil
-	}
-
-	// 解析Nova响应
-	var novaResp struct {
-		Output struct {
-			Message struct {
-				Content []struct {
-					Text string `json:"text"`
-				} `json:"content"`
-			} `json:"message"`
-		} `json:"output"`
-		Usage struct {
-			InputTokens  int `json:"inputTokens"`
-			OutputTokens int `json:"outputTokens"`
-			TotalTokens  int `json:"totalTokens"`
-		} `json:"usage"`
-	}
-
-	if err := json.Unmarshal(awsResp.Body, &novaResp); err != nil {
-		return types.NewError(errors.Wrap(err, "unmarshal nova response"), types.ErrorCodeBadResponseBody), nil
-	}
-
-	// 构造OpenAI格式响应
-	response := dto.OpenAITextResponse{
-		Id:      helper.GetResponseID(c),
-		Object:  "chat.completion",
-		Created: common.GetTimestamp(),
-		Model:   info.UpstreamModelName,
-		Choices: []dto.OpenAITextResponseChoice{{
-			Index: 0,
-			Message: dto.Message{
-				Role:    "assistant",
-				Content: novaResp.Output.Message.Content[0].Text,
-			},
-			FinishReason: "stop",
-		}},
-		Usage: dto.Usage{
-			PromptTokens:     novaResp.Usage.InputTokens,
-			CompletionTokens: novaResp.Usage.OutputTokens,
-			TotalTokens:      novaResp.Usage.TotalTokens,
-		},
+		return types.NewError(err, types.ErrorCodeBadResponseBody), nil
 	}
-
-	c.JSON(http.StatusOK, response)
+	c.Writer.Header().Set("Content-Type", "application/json")
+	service.IOCopyBytesGracefully(c, nil, data)
 	return nil, &response.Usage
 }
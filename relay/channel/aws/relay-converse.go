@@ -0,0 +1,537 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/relay/channel/openai"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	bedrockruntimeTypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// requestOpenAI2Converse 将 OpenAI 请求转换为 Bedrock Converse 请求，ModelId 在调用时按区域填充
+func requestOpenAI2Converse(c *gin.Context, request *dto.GeneralOpenAIRequest) (*bedrockruntime.ConverseInput, error) {
+	input := &bedrockruntime.ConverseInput{}
+	documentCount := 0
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		switch message.Role {
+		case "system", "developer":
+			if text := message.StringContent(); text != "" {
+				input.System = append(input.System, &bedrockruntimeTypes.SystemContentBlockMemberText{Value: text})
+			}
+		case "tool":
+			input.Messages = appendConverseMessage(input.Messages, bedrockruntimeTypes.ConversationRoleUser, &bedrockruntimeTypes.ContentBlockMemberToolResult{
+				Value: bedrockruntimeTypes.ToolResultBlock{
+					ToolUseId: aws.String(message.ToolCallId),
+					Content: []bedrockruntimeTypes.ToolResultContentBlock{
+						&bedrockruntimeTypes.ToolResultContentBlockMemberText{Value: message.StringContent()},
+					},
+				},
+			})
+		default:
+			role := bedrockruntimeTypes.ConversationRoleUser
+			if message.Role == "assistant" {
+				role = bedrockruntimeTypes.ConversationRoleAssistant
+			}
+			blocks, err := converseContentBlocks(c, message, &documentCount)
+			if err != nil {
+				return nil, err
+			}
+			if role == bedrockruntimeTypes.ConversationRoleAssistant {
+				for _, toolCall := range message.ParseToolCalls() {
+					arguments := make(map[string]any)
+					if toolCall.Function.Arguments != "" {
+						if err := common.UnmarshalJsonStr(toolCall.Function.Arguments, &arguments); err != nil {
+							return nil, fmt.Errorf("tool call %s arguments is not a json object: %w", toolCall.ID, err)
+						}
+					}
+					blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberToolUse{
+						Value: bedrockruntimeTypes.ToolUseBlock{
+							ToolUseId: aws.String(toolCall.ID),
+							Name:      aws.String(toolCall.Function.Name),
+							Input:     document.NewLazyDocument(arguments),
+						},
+					})
+				}
+			}
+			input.Messages = appendConverseMessage(input.Messages, role, blocks...)
+		}
+	}
+
+	inferenceConfig := &bedrockruntimeTypes.InferenceConfiguration{}
+	if maxTokens := max(request.MaxTokens, request.MaxCompletionTokens); maxTokens > 0 {
+		inferenceConfig.MaxTokens = aws.Int32(int32(maxTokens))
+	}
+	if request.Temperature != nil {
+		inferenceConfig.Temperature = aws.Float32(float32(*request.Temperature))
+	}
+	if request.TopP != 0 {
+		inferenceConfig.TopP = aws.Float32(float32(request.TopP))
+	}
+	inferenceConfig.StopSequences = parseStopSequences(request.Stop)
+	input.InferenceConfig = inferenceConfig
+
+	toolConfig, err := converseToolConfig(request)
+	if err != nil {
+		return nil, err
+	}
+	input.ToolConfig = toolConfig
+	return input, nil
+}
+
+// appendConverseMessage Converse 要求 user 与 assistant 交替出现，相同角色的连续消息合并为一条
+func appendConverseMessage(messages []bedrockruntimeTypes.Message, role bedrockruntimeTypes.ConversationRole, blocks ...bedrockruntimeTypes.ContentBlock) []bedrockruntimeTypes.Message {
+	if len(blocks) == 0 {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, bedrockruntimeTypes.Message{Role: role, Content: blocks})
+}
+
+func converseContentBlocks(c *gin.Context, message *dto.Message, documentCount *int) ([]bedrockruntimeTypes.ContentBlock, error) {
+	var blocks []bedrockruntimeTypes.ContentBlock
+	if message.IsStringContent() {
+		// Converse 不接受空白的文本块
+		if text := message.StringContent(); strings.TrimSpace(text) != "" {
+			blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberText{Value: text})
+		}
+		return blocks, nil
+	}
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case dto.ContentTypeText:
+			if strings.TrimSpace(part.Text) != "" {
+				blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberText{Value: part.Text})
+			}
+		case dto.ContentTypeImageURL:
+			imageUrl := part.GetImageMedia()
+			if imageUrl == nil {
+				continue
+			}
+			mimeType, data, err := converseFileData(c, imageUrl.Url)
+			if err != nil {
+				return nil, err
+			}
+			format := strings.TrimPrefix(mimeType, "image/")
+			if format == "jpg" {
+				format = "jpeg"
+			}
+			blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberImage{
+				Value: bedrockruntimeTypes.ImageBlock{
+					Format: bedrockruntimeTypes.ImageFormat(format),
+					Source: &bedrockruntimeTypes.ImageSourceMemberBytes{Value: data},
+				},
+			})
+		case dto.ContentTypeFile:
+			file := part.GetFile()
+			if file == nil || file.FileData == "" {
+				continue
+			}
+			mimeType, data, err := converseFileData(c, file.FileData)
+			if err != nil {
+				return nil, err
+			}
+			*documentCount++
+			blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberDocument{
+				Value: bedrockruntimeTypes.DocumentBlock{
+					// 文档名只能包含字母、数字、空格与少量符号，统一按序号命名
+					Name:   aws.String(fmt.Sprintf("document-%d", *documentCount)),
+					Format: converseDocumentFormat(mimeType, file.FileName),
+					Source: &bedrockruntimeTypes.DocumentSourceMemberBytes{Value: data},
+				},
+			})
+		}
+	}
+	return blocks, nil
+}
+
+// converseFileData 读取 URL 或 base64 数据，Converse 需要原始字节
+func converseFileData(c *gin.Context, url string) (string, []byte, error) {
+	var mimeType, base64Data string
+	if strings.HasPrefix(url, "http") {
+		fileData, err := service.GetFileBase64FromUrl(c, url, "formatting file for Bedrock Converse")
+		if err != nil {
+			return "", nil, fmt.Errorf("get file base64 from url failed: %s", err.Error())
+		}
+		mimeType, base64Data = fileData.MimeType, fileData.Base64Data
+	} else {
+		var err error
+		mimeType, base64Data, err = service.DecodeBase64FileData(url)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return "", nil, fmt.Errorf("decode base64 file data failed: %s", err.Error())
+	}
+	return mimeType, data, nil
+}
+
+func converseDocumentFormat(mimeType string, fileName string) bedrockruntimeTypes.DocumentFormat {
+	switch mimeType {
+	case "application/pdf":
+		return bedrockruntimeTypes.DocumentFormatPdf
+	case "text/csv":
+		return bedrockruntimeTypes.DocumentFormatCsv
+	case "application/msword":
+		return bedrockruntimeTypes.DocumentFormatDoc
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return bedrockruntimeTypes.DocumentFormatDocx
+	case "application/vnd.ms-excel":
+		return bedrockruntimeTypes.DocumentFormatXls
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return bedrockruntimeTypes.DocumentFormatXlsx
+	case "text/html":
+		return bedrockruntimeTypes.DocumentFormatHtml
+	case "text/markdown":
+		return bedrockruntimeTypes.DocumentFormatMd
+	}
+	if idx := strings.LastIndex(fileName, "."); idx != -1 {
+		return bedrockruntimeTypes.DocumentFormat(strings.ToLower(fileName[idx+1:]))
+	}
+	return bedrockruntimeTypes.DocumentFormatTxt
+}
+
+func converseToolConfig(request *dto.GeneralOpenAIRequest) (*bedrockruntimeTypes.ToolConfiguration, error) {
+	if len(request.Tools) == 0 {
+		return nil, nil
+	}
+	toolConfig := &bedrockruntimeTypes.ToolConfiguration{}
+	switch choice := request.ToolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			// Converse 没有 none 选项，不传工具即可
+			return nil, nil
+		case "required":
+			toolConfig.ToolChoice = &bedrockruntimeTypes.ToolChoiceMemberAny{}
+		case "auto":
+			toolConfig.ToolChoice = &bedrockruntimeTypes.ToolChoiceMemberAuto{}
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			toolConfig.ToolChoice = &bedrockruntimeTypes.ToolChoiceMemberTool{
+				Value: bedrockruntimeTypes.SpecificToolChoice{Name: aws.String(common.Interface2String(function["name"]))},
+			}
+		}
+	}
+	for _, tool := range request.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			return nil, fmt.Errorf("tool type %s is not supported by Bedrock Converse", tool.Type)
+		}
+		parameters := tool.Function.Parameters
+		if parameters == nil {
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		spec := bedrockruntimeTypes.ToolSpecification{
+			Name:        aws.String(tool.Function.Name),
+			InputSchema: &bedrockruntimeTypes.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(parameters)},
+		}
+		if tool.Function.Description != "" {
+			spec.Description = aws.String(tool.Function.Description)
+		}
+		toolConfig.Tools = append(toolConfig.Tools, &bedrockruntimeTypes.ToolMemberToolSpec{Value: spec})
+	}
+	return toolConfig, nil
+}
+
+func stopReasonConverse2OpenAI(reason bedrockruntimeTypes.StopReason) string {
+	switch reason {
+	case bedrockruntimeTypes.StopReasonToolUse:
+		return "tool_calls"
+	case bedrockruntimeTypes.StopReasonMaxTokens:
+		return "length"
+	case bedrockruntimeTypes.StopReasonContentFiltered, bedrockruntimeTypes.StopReasonGuardrailIntervened:
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// usageConverse2OpenAI Converse 的 inputTokens 不含缓存部分，这里按 OpenAI 口径把缓存计入 prompt_tokens
+func usageConverse2OpenAI(tokenUsage *bedrockruntimeTypes.TokenUsage) *dto.Usage {
+	usage := &dto.Usage{}
+	if tokenUsage == nil {
+		return usage
+	}
+	cacheRead := int(aws.ToInt32(tokenUsage.CacheReadInputTokens))
+	cacheWrite := int(aws.ToInt32(tokenUsage.CacheWriteInputTokens))
+	usage.PromptTokens = int(aws.ToInt32(tokenUsage.InputTokens)) + cacheRead + cacheWrite
+	usage.CompletionTokens = int(aws.ToInt32(tokenUsage.OutputTokens))
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.PromptTokensDetails.CachedTokens = cacheRead
+	usage.PromptTokensDetails.CachedCreationTokens = cacheWrite
+	return usage
+}
+
+func converseInput(c *gin.Context, info *relaycommon.RelayInfo, awsCli *bedrockruntime.Client) (*bedrockruntime.ConverseInput, error) {
+	converseReq_, ok := c.Get("converted_request")
+	if !ok {
+		return nil, errors.New("aws converse request not found")
+	}
+	converseReq, ok := converseReq_.(*bedrockruntime.ConverseInput)
+	if !ok {
+		return nil, errors.New("invalid aws converse request")
+	}
+	converseReq.ModelId = aws.String(awsRequestModelID(info, c.GetString("request_model"), awsCli.Options().Region))
+	return converseReq, nil
+}
+
+func converseHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
+	}
+	converseReq, err := converseInput(c, info, awsCli)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest), nil
+	}
+
+	captureConverseRequest(c, info, converseReq)
+
+	awsResp, err := awsCli.Converse(c.Request.Context(), converseReq)
+	if err != nil {
+		return awsInvokeError(err, "Converse"), nil
+	}
+
+	message := dto.Message{Role: "assistant"}
+	var content strings.Builder
+	var toolCalls []dto.ToolCallResponse
+	if output, ok := awsResp.Output.(*bedrockruntimeTypes.ConverseOutputMemberMessage); ok {
+		for _, block := range output.Value.Content {
+			switch v := block.(type) {
+			case *bedrockruntimeTypes.ContentBlockMemberText:
+				content.WriteString(v.Value)
+			case *bedrockruntimeTypes.ContentBlockMemberReasoningContent:
+				if reasoning, ok := v.Value.(*bedrockruntimeTypes.ReasoningContentBlockMemberReasoningText); ok {
+					message.ReasoningContent += aws.ToString(reasoning.Value.Text)
+				}
+			case *bedrockruntimeTypes.ContentBlockMemberToolUse:
+				arguments := "{}"
+				if v.Value.Input != nil {
+					if data, err := v.Value.Input.MarshalSmithyDocument(); err == nil {
+						arguments = string(data)
+					}
+				}
+				toolCalls = append(toolCalls, dto.ToolCallResponse{
+					ID:   aws.ToString(v.Value.ToolUseId),
+					Type: "function",
+					Function: dto.FunctionResponse{
+						Name:      aws.ToString(v.Value.Name),
+						Arguments: arguments,
+					},
+				})
+			}
+		}
+	}
+	message.SetStringContent(content.String())
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+
+	usage := usageConverse2OpenAI(awsResp.Usage)
+	response := dto.OpenAITextResponse{
+		Id:      helper.GetResponseID(c),
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Model:   info.UpstreamModelName,
+		Choices: []dto.OpenAITextResponseChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: stopReasonConverse2OpenAI(awsResp.StopReason),
+		}},
+		Usage: *usage,
+	}
+
+	openAIData, err := common.Marshal(response)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody), nil
+	}
+	service.CaptureUpstreamResponseData(c, openAIData, false)
+
+	data := openAIData
+	switch info.RelayFormat {
+	case types.RelayFormatClaude:
+		data, err = common.Marshal(service.ResponseOpenAI2Claude(&response, info))
+	case types.RelayFormatGemini:
+		data, err = common.Marshal(service.ResponseOpenAI2Gemini(&response, info))
+	}
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	service.IOCopyBytesGracefully(c, nil, data)
+	return nil, usage
+}
+
+// captureConverseRequest Converse 请求由 SDK 发送，按 JSON 记录到请求记录中
+func captureConverseRequest(c *gin.Context, info *relaycommon.RelayInfo, converseReq *bedrockruntime.ConverseInput) {
+	data, err := common.Marshal(converseReq)
+	if err != nil {
+		return
+	}
+	service.CaptureUpstreamRequest(c, info.ChannelId, bytes.NewReader(data))
+}
+
+func converseStreamHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
+	}
+	converseReq, err := converseInput(c, info, awsCli)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest), nil
+	}
+
+	captureConverseRequest(c, info, converseReq)
+
+	awsResp, err := awsCli.ConverseStream(c.Request.Context(), &bedrockruntime.ConverseStreamInput{
+		ModelId:                      converseReq.ModelId,
+		System:                       converseReq.System,
+		Messages:                     converseReq.Messages,
+		InferenceConfig:              converseReq.InferenceConfig,
+		ToolConfig:                   converseReq.ToolConfig,
+		AdditionalModelRequestFields: converseReq.AdditionalModelRequestFields,
+	})
+	if err != nil {
+		return awsInvokeError(err, "ConverseStream"), nil
+	}
+	stream := awsResp.GetStream()
+	defer stream.Close()
+
+	helper.SetEventStreamHeaders(c)
+	responseId := helper.GetResponseID(c)
+	createdAt := common.GetTimestamp()
+	model := info.UpstreamModelName
+	usage := &dto.Usage{}
+	// 与 OpenAI 流式处理一致，最后一个分块留给 HandleFinalResponse 处理，Claude/Gemini 格式需要在其中附带用量
+	var lastStreamData string
+	// SDK 直接返回事件，不经过 StreamScannerHandler，占位符还原与敏感词检测在这里处理
+	outputFilter := service.NewStreamOutputFilter(c)
+	stopped := false
+	sendData := func(chunks []string) {
+		for _, data := range chunks {
+			if lastStreamData != "" {
+				if err := openai.HandleStreamFormat(c, info, lastStreamData, false, false); err != nil {
+					logger.LogError(c, "error handling stream format: "+err.Error())
+				}
+			}
+			lastStreamData = data
+		}
+	}
+	send := func(response *dto.ChatCompletionsStreamResponse) {
+		data, err := common.Marshal(response)
+		if err != nil {
+			logger.LogError(c, "marshal converse stream response failed: "+err.Error())
+			return
+		}
+		service.CaptureUpstreamResponseData(c, awsSSEData(string(data)), true)
+		var ready []string
+		ready, stopped = outputFilter.Push(string(data))
+		sendData(ready)
+	}
+	newDelta := func(delta dto.ChatCompletionsStreamResponseChoiceDelta) *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      responseId,
+			Object:  "chat.completion.chunk",
+			Created: createdAt,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: delta}},
+		}
+	}
+	// Converse 的内容块序号包含文本块，OpenAI 的工具调用序号只计工具调用
+	toolIndexes := make(map[int32]int)
+
+	for event := range stream.Events() {
+		switch v := event.(type) {
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberMessageStart:
+			info.SetFirstResponseTime()
+			send(helper.GenerateStartEmptyResponse(responseId, createdAt, model, nil))
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberContentBlockStart:
+			start, ok := v.Value.Start.(*bedrockruntimeTypes.ContentBlockStartMemberToolUse)
+			if !ok {
+				continue
+			}
+			index := len(toolIndexes)
+			toolIndexes[aws.ToInt32(v.Value.ContentBlockIndex)] = index
+			send(newDelta(dto.ChatCompletionsStreamResponseChoiceDelta{
+				ToolCalls: []dto.ToolCallResponse{{
+					Index: common.GetPointer(index),
+					ID:    aws.ToString(start.Value.ToolUseId),
+					Type:  "function",
+					Function: dto.FunctionResponse{
+						Name: aws.ToString(start.Value.Name),
+					},
+				}},
+			}))
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberContentBlockDelta:
+			info.SetFirstResponseTime()
+			var delta dto.ChatCompletionsStreamResponseChoiceDelta
+			switch d := v.Value.Delta.(type) {
+			case *bedrockruntimeTypes.ContentBlockDeltaMemberText:
+				delta.SetContentString(d.Value)
+			case *bedrockruntimeTypes.ContentBlockDeltaMemberReasoningContent:
+				text, ok := d.Value.(*bedrockruntimeTypes.ReasoningContentBlockDeltaMemberText)
+				if !ok {
+					continue
+				}
+				delta.SetReasoningContent(text.Value)
+			case *bedrockruntimeTypes.ContentBlockDeltaMemberToolUse:
+				index := toolIndexes[aws.ToInt32(v.Value.ContentBlockIndex)]
+				delta.ToolCalls = []dto.ToolCallResponse{{
+					Index:    common.GetPointer(index),
+					Function: dto.FunctionResponse{Arguments: aws.ToString(d.Value.Input)},
+				}}
+			default:
+				continue
+			}
+			send(newDelta(delta))
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberMessageStop:
+			send(helper.GenerateStopResponse(responseId, createdAt, model, stopReasonConverse2OpenAI(v.Value.StopReason)))
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberMetadata:
+			usage = usageConverse2OpenAI(v.Value.Usage)
+		}
+		if stopped {
+			break
+		}
+	}
+	if err := stream.Err(); err != nil {
+		if lastStreamData == "" {
+			return awsInvokeError(err, "ConverseStream"), nil
+		}
+		logger.LogError(c, "converse stream error: "+err.Error())
+	}
+	if !stopped {
+		sendData(outputFilter.Flush())
+	}
+
+	if usage.TotalTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+		usage.TotalTokens = info.PromptTokens
+	}
+	if info.RelayFormat == types.RelayFormatOpenAI && lastStreamData != "" {
+		if err := openai.HandleStreamFormat(c, info, lastStreamData, false, false); err != nil {
+			logger.LogError(c, "error handling stream format: "+err.Error())
+		}
+	}
+	openai.HandleFinalResponse(c, info, lastStreamData, responseId, createdAt, model, "", usage, false)
+	return nil, usage
+}
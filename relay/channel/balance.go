@@ -0,0 +1,35 @@
+package channel
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+)
+
+// BalanceFetcher 由支持查询上游余额的适配器实现，返回以美元计的余额
+type BalanceFetcher interface {
+	FetchBalance(client *http.Client, baseURL string, key string) (float64, error)
+}
+
+// DoBalanceRequest 以 Bearer 鉴权请求上游的余额接口并解析 JSON 响应
+func DoBalanceRequest(client *http.Client, url string, key string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return common.Unmarshal(body, v)
+}
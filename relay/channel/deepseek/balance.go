@@ -0,0 +1,47 @@
+package deepseek
+
+import (
+	"errors"
+	"net/http"
+	"one-api/relay/channel"
+	"one-api/setting/operation_setting"
+	"strconv"
+)
+
+type balanceResponse struct {
+	IsAvailable  bool `json:"is_available"`
+	BalanceInfos []struct {
+		Currency        string `json:"currency"`
+		TotalBalance    string `json:"total_balance"`
+		GrantedBalance  string `json:"granted_balance"`
+		ToppedUpBalance string `json:"topped_up_balance"`
+	} `json:"balance_infos"`
+}
+
+func (a *Adaptor) FetchBalance(client *http.Client, baseURL string, key string) (float64, error) {
+	var response balanceResponse
+	if err := channel.DoBalanceRequest(client, baseURL+"/user/balance", key, &response); err != nil {
+		return 0, err
+	}
+	// 优先使用美元余额，只有人民币余额时按汇率换算
+	var usd, cny *float64
+	for _, info := range response.BalanceInfos {
+		balance, err := strconv.ParseFloat(info.TotalBalance, 64)
+		if err != nil {
+			return 0, err
+		}
+		switch info.Currency {
+		case "USD":
+			usd = &balance
+		case "CNY":
+			cny = &balance
+		}
+	}
+	if usd != nil {
+		return *usd, nil
+	}
+	if cny != nil {
+		return *cny / operation_setting.Price, nil
+	}
+	return 0, errors.New("no USD or CNY balance found")
+}
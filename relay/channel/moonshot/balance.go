@@ -0,0 +1,35 @@
+package moonshot
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/relay/channel"
+	"one-api/setting/operation_setting"
+	"strings"
+)
+
+type balanceResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		AvailableBalance float64 `json:"available_balance"`
+		VoucherBalance   float64 `json:"voucher_balance"`
+		CashBalance      float64 `json:"cash_balance"`
+	} `json:"data"`
+	Scode  string `json:"scode"`
+	Status bool   `json:"status"`
+}
+
+func (a *Adaptor) FetchBalance(client *http.Client, baseURL string, key string) (float64, error) {
+	var response balanceResponse
+	if err := channel.DoBalanceRequest(client, baseURL+"/v1/users/me/balance", key, &response); err != nil {
+		return 0, err
+	}
+	if !response.Status || response.Code != 0 {
+		return 0, fmt.Errorf("failed to fetch moonshot balance, status: %v, code: %d, scode: %s", response.Status, response.Code, response.Scode)
+	}
+	// 国际站 api.moonshot.ai 以美元计费，国内站以人民币计费
+	if strings.Contains(baseURL, "moonshot.ai") {
+		return response.Data.AvailableBalance, nil
+	}
+	return response.Data.AvailableBalance / operation_setting.Price, nil
+}
@@ -0,0 +1,44 @@
+package openrouter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+)
+
+type creditResponse struct {
+	Data struct {
+		TotalCredits float64 `json:"total_credits"`
+		TotalUsage   float64 `json:"total_usage"`
+	} `json:"data"`
+}
+
+// BalanceFetcher OpenRouter 渠道复用 OpenAI 适配器，单独实现 credits 查询。
+// 本包被 relay/channel 间接引用，不能使用 channel.DoBalanceRequest
+type BalanceFetcher struct{}
+
+func (f *BalanceFetcher) FetchBalance(client *http.Client, baseURL string, key string) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/credits", nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	var response creditResponse
+	if err := common.Unmarshal(body, &response); err != nil {
+		return 0, err
+	}
+	return response.Data.TotalCredits - response.Data.TotalUsage, nil
+}
@@ -0,0 +1,36 @@
+package siliconflow
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/relay/channel"
+	"one-api/setting/operation_setting"
+	"strconv"
+)
+
+type balanceResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  bool   `json:"status"`
+	Data    struct {
+		Balance       string `json:"balance"`
+		ChargeBalance string `json:"chargeBalance"`
+		TotalBalance  string `json:"totalBalance"`
+	} `json:"data"`
+}
+
+func (a *Adaptor) FetchBalance(client *http.Client, baseURL string, key string) (float64, error) {
+	var response balanceResponse
+	if err := channel.DoBalanceRequest(client, baseURL+"/v1/user/info", key, &response); err != nil {
+		return 0, err
+	}
+	if response.Code != 20000 {
+		return 0, fmt.Errorf("code: %d, message: %s", response.Code, response.Message)
+	}
+	balance, err := strconv.ParseFloat(response.Data.TotalBalance, 64)
+	if err != nil {
+		return 0, err
+	}
+	// 余额单位为人民币
+	return balance / operation_setting.Price, nil
+}
@@ -0,0 +1,204 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/types"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatTranslator 将适配器写出的 Chat Completions 响应转换为客户端请求的格式，
+// 用于让只支持 Chat Completions 的渠道提供 Responses、Gemini 等接口
+type chatTranslator interface {
+	// streamChunk 转换一个流式分块，返回需要写给客户端的 SSE 数据
+	streamChunk(chunk *dto.ChatCompletionsStreamResponse) ([]byte, error)
+	// streamFinish 在适配器处理完成后写出剩余的事件，usage 为适配器统计的用量
+	streamFinish(usage *dto.Usage) ([]byte, error)
+	// response 转换非流式响应
+	response(response *dto.OpenAITextResponse, usage *dto.Usage) (any, error)
+}
+
+// relayViaChatCompletions 以 Chat Completions 格式请求渠道，并通过 translator 把响应转换回客户端的格式，返回用量由调用方计费
+func relayViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, chatRequest *dto.GeneralOpenAIRequest, translator chatTranslator) (*dto.Usage, *types.NewAPIError) {
+	// 适配器按 RelayMode 与 RelayFormat 选择上游接口和输出格式，重试时会复用 info，结束后需要还原
+	relayMode, requestURLPath, relayFormat, includeUsage := info.RelayMode, info.RequestURLPath, info.RelayFormat, info.ShouldIncludeUsage
+	defer func() {
+		info.RelayMode, info.RequestURLPath, info.RelayFormat, info.ShouldIncludeUsage = relayMode, requestURLPath, relayFormat, includeUsage
+	}()
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+	info.RelayFormat = types.RelayFormatOpenAI
+	info.ShouldIncludeUsage = false
+	if chatRequest.Stream && chatRequest.StreamOptions == nil {
+		chatRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+
+	adaptor.Init(info)
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, chatRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+		}
+	}
+	logger.LogDebug(c, "translated chat request body: "+string(jsonData))
+
+	var httpResp *http.Response
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+		if httpResp.StatusCode != http.StatusOK {
+			newAPIError := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+			service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+			return nil, newAPIError
+		}
+	}
+
+	originWriter := c.Writer
+	writer := &chatTranslateWriter{ResponseWriter: originWriter, translator: translator, stream: info.IsStream, status: http.StatusOK}
+	c.Writer = writer
+	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	c.Writer = originWriter
+	if newAPIError != nil {
+		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+		return nil, newAPIError
+	}
+	chatUsage, _ := usage.(*dto.Usage)
+	if chatUsage == nil {
+		chatUsage = &dto.Usage{}
+	}
+	if err := writer.finish(chatUsage); err != nil {
+		logger.LogError(c, "write translated response failed: "+err.Error())
+	}
+	return chatUsage, nil
+}
+
+// chatTranslateWriter 拦截适配器写出的 Chat Completions 响应：
+// 流式响应逐行解析 SSE 分块并立即写出转换后的数据，非流式响应缓存完整内容后在 finish 中转换
+type chatTranslateWriter struct {
+	gin.ResponseWriter
+	translator chatTranslator
+	stream     bool
+	pending    bytes.Buffer
+	status     int
+}
+
+func (w *chatTranslateWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *chatTranslateWriter) Write(data []byte) (int, error) {
+	w.pending.Write(data)
+	if !w.stream {
+		return len(data), nil
+	}
+	for {
+		line, err := w.pending.ReadString('\n')
+		if err != nil {
+			// 不完整的行留到下次写入
+			w.pending.Reset()
+			w.pending.WriteString(line)
+			break
+		}
+		if writeErr := w.handleLine(strings.TrimSpace(line)); writeErr != nil {
+			return 0, writeErr
+		}
+	}
+	return len(data), nil
+}
+
+func (w *chatTranslateWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *chatTranslateWriter) handleLine(line string) error {
+	if strings.HasPrefix(line, ":") {
+		// 保活注释原样转发
+		_, err := w.ResponseWriter.WriteString(line + "\n\n")
+		return err
+	}
+	if !strings.HasPrefix(line, "data:") {
+		return nil
+	}
+	payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if payload == "" || payload == "[DONE]" {
+		return nil
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+		return nil
+	}
+	data, err := w.translator.streamChunk(&chunk)
+	if err != nil {
+		return err
+	}
+	return w.writeStream(data)
+}
+
+func (w *chatTranslateWriter) writeStream(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := w.ResponseWriter.Write(data); err != nil {
+		return err
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+func (w *chatTranslateWriter) finish(usage *dto.Usage) error {
+	if w.stream {
+		data, err := w.translator.streamFinish(usage)
+		if err != nil {
+			return err
+		}
+		return w.writeStream(data)
+	}
+	body := w.pending.Bytes()
+	var textResponse dto.OpenAITextResponse
+	if w.status == http.StatusOK {
+		if err := common.Unmarshal(body, &textResponse); err == nil && textResponse.Error == nil {
+			response, err := w.translator.response(&textResponse, usage)
+			if err != nil {
+				return err
+			}
+			converted, err := common.Marshal(response)
+			if err != nil {
+				return err
+			}
+			body = converted
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := io.Copy(w.ResponseWriter, bytes.NewReader(body))
+	return err
+}
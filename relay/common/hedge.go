@@ -0,0 +1,99 @@
+package common
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// HedgeState 同一个请求的主请求与对冲请求共享，先向客户端输出（或先结算）的一方胜出
+type HedgeState struct {
+	winner           atomic.Int32
+	triggered        atomic.Bool
+	Delay            time.Duration
+	PrimaryChannelId int
+	HedgeChannelId   int
+}
+
+func NewHedgeState(primaryChannelId int, delay time.Duration) *HedgeState {
+	state := &HedgeState{
+		Delay:            delay,
+		PrimaryChannelId: primaryChannelId,
+	}
+	state.winner.Store(-1)
+	return state
+}
+
+// Claim 尝试成为胜出方，已经胜出时同样返回 true
+func (s *HedgeState) Claim(index int) bool {
+	if s.winner.CompareAndSwap(-1, int32(index)) {
+		return true
+	}
+	return s.winner.Load() == int32(index)
+}
+
+// Winner 尚未决出时返回 -1
+func (s *HedgeState) Winner() int {
+	return int(s.winner.Load())
+}
+
+func (s *HedgeState) Trigger(hedgeChannelId int) {
+	s.HedgeChannelId = hedgeChannelId
+	s.triggered.Store(true)
+}
+
+func (s *HedgeState) Triggered() bool {
+	return s.triggered.Load()
+}
+
+// HedgeClaim 计费前调用，对冲中落败的一方返回 false，不应再计费
+func (info *RelayInfo) HedgeClaim() bool {
+	if info.HedgeState == nil {
+		return true
+	}
+	return info.HedgeState.Claim(info.HedgeIndex)
+}
+
+// HedgeLogInfo 写入消费日志 other 字段的对冲信息，未触发对冲时返回 nil
+func (info *RelayInfo) HedgeLogInfo() map[string]interface{} {
+	if info.HedgeState == nil || !info.HedgeState.Triggered() {
+		return nil
+	}
+	winner := "primary"
+	if info.HedgeIndex != 0 {
+		winner = "hedge"
+	}
+	return map[string]interface{}{
+		"delay_ms":           info.HedgeState.Delay.Milliseconds(),
+		"primary_channel_id": info.HedgeState.PrimaryChannelId,
+		"hedge_channel_id":   info.HedgeState.HedgeChannelId,
+		"winner":             winner,
+	}
+}
+
+// CloneForHedge 复制一份供对冲请求使用的 RelayInfo，流式转换过程中会被修改的状态需要独立
+func (info *RelayInfo) CloneForHedge(index int) *RelayInfo {
+	clone := *info
+	clone.HedgeIndex = index
+	clone.ChannelMeta = nil
+	if info.ClaudeConvertInfo != nil {
+		claudeConvertInfo := *info.ClaudeConvertInfo
+		if claudeConvertInfo.Usage != nil {
+			usage := *claudeConvertInfo.Usage
+			claudeConvertInfo.Usage = &usage
+		}
+		clone.ClaudeConvertInfo = &claudeConvertInfo
+	}
+	if info.ResponsesUsageInfo != nil {
+		builtInTools := make(map[string]*BuildInToolInfo, len(info.ResponsesUsageInfo.BuiltInTools))
+		for name, tool := range info.ResponsesUsageInfo.BuiltInTools {
+			toolCopy := *tool
+			builtInTools[name] = &toolCopy
+		}
+		clone.ResponsesUsageInfo = &ResponsesUsageInfo{BuiltInTools: builtInTools}
+	}
+	if info.TaskRelayInfo != nil {
+		taskRelayInfo := *info.TaskRelayInfo
+		clone.TaskRelayInfo = &taskRelayInfo
+	}
+	return &clone
+}
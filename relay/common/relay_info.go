@@ -36,6 +36,46 @@ type ClaudeConvertInfo struct {
 	Done             bool
 }
 
+// GeminiConvertInfo OpenAI 流式响应转换为 Gemini 格式时的状态，工具调用参数分多个分块到达，需要拼接完整后再输出
+type GeminiConvertInfo struct {
+	toolCalls     map[int]*dto.ToolCallResponse
+	toolCallOrder []int
+}
+
+func (g *GeminiConvertInfo) AppendToolCalls(toolCalls []dto.ToolCallResponse) {
+	if g.toolCalls == nil {
+		g.toolCalls = make(map[int]*dto.ToolCallResponse)
+	}
+	for i, toolCall := range toolCalls {
+		index := i
+		if toolCall.Index != nil {
+			index = *toolCall.Index
+		}
+		existing, ok := g.toolCalls[index]
+		if !ok {
+			call := toolCall
+			g.toolCalls[index] = &call
+			g.toolCallOrder = append(g.toolCallOrder, index)
+			continue
+		}
+		if toolCall.Function.Name != "" {
+			existing.Function.Name = toolCall.Function.Name
+		}
+		existing.Function.Arguments += toolCall.Function.Arguments
+	}
+}
+
+// FlushToolCalls 返回已拼接完整的工具调用并清空
+func (g *GeminiConvertInfo) FlushToolCalls() []dto.ToolCallResponse {
+	toolCalls := make([]dto.ToolCallResponse, 0, len(g.toolCallOrder))
+	for _, index := range g.toolCallOrder {
+		toolCalls = append(toolCalls, *g.toolCalls[index])
+	}
+	g.toolCalls = nil
+	g.toolCallOrder = nil
+	return toolCalls
+}
+
 type RerankerInfo struct {
 	Documents       []any
 	ReturnDocuments bool
@@ -85,6 +125,9 @@ type RelayInfo struct {
 	IsStream               bool
 	IsGeminiBatchEmbedding bool
 	IsPlayground           bool
+	IsReplay               bool // 管理员回放的请求，不计费
+	RequestId              string
+	OrgId                  int // 组织令牌的组织，费用从组织额度中扣除
 	UsePrice               bool
 	RelayMode              int
 	OriginModelName        string
@@ -107,6 +150,9 @@ type RelayInfo struct {
 	SendResponseCount      int
 	FinalPreConsumedQuota  int  // 最终预消耗的配额
 	IsClaudeBetaQuery      bool // /v1/messages?beta=true
+	HedgeState             *HedgeState
+	HedgeIndex             int    // 0 为主请求，1 为对冲请求
+	ResponseCacheKey       string // 非空时请求成功后写入响应缓存
 
 	PriceData types.PriceData
 
@@ -114,6 +160,7 @@ type RelayInfo struct {
 
 	ThinkingContentInfo
 	*ClaudeConvertInfo
+	GeminiConvertInfo *GeminiConvertInfo
 	*RerankerInfo
 	*ResponsesUsageInfo
 	*ChannelMeta
@@ -391,6 +438,9 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
+		IsReplay:       common.GetContextKeyBool(c, constant.ContextKeyReplay),
+		RequestId:      c.GetString(common.RequestIdKey),
+		OrgId:          common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
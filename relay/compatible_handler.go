@@ -167,6 +167,16 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
+	var captureWriter *responseCaptureWriter
+	if info.ResponseCacheKey != "" {
+		captureWriter = newResponseCaptureWriter(c.Writer)
+		originWriter := c.Writer
+		c.Writer = captureWriter
+		defer func() {
+			c.Writer = originWriter
+		}()
+	}
+
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
 	if newApiErr != nil {
 		// reset status code 重置状态码
@@ -179,10 +189,22 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	} else {
 		postConsumeQuota(c, info, usage.(*dto.Usage), "")
 	}
+	// 对冲中落败的一方输出已被丢弃，不写入缓存
+	if captureWriter != nil && info.HedgeClaim() {
+		storeResponseCache(c, info, captureWriter, usage.(*dto.Usage))
+	}
 	return nil
 }
 
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	if relayInfo.IsReplay {
+		logger.LogInfo(ctx, "replayed request, skip billing")
+		return
+	}
+	if !relayInfo.HedgeClaim() {
+		logger.LogInfo(ctx, "hedged request lost, skip billing")
+		return
+	}
 	if usage == nil {
 		usage = &dto.Usage{
 			PromptTokens:     relayInfo.PromptTokens,
@@ -388,6 +410,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 			logger.LogError(ctx, "error consuming token remain quota: "+err.Error())
 		}
 	}
+	service.ReconcileTokenRateLimit(ctx, totalTokens)
+	service.RecordChannelKeyTokens(relayInfo, totalTokens)
 
 	logModel := modelName
 	if strings.HasPrefix(logModel, "gpt-4-gizmo") {
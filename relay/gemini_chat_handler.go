@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportsNativeGemini Gemini、Vertex 渠道原生支持，OpenAI 适配器自行完成 Gemini 格式的转换，其他渠道转换为 Chat Completions
+func supportsNativeGemini(info *relaycommon.RelayInfo) bool {
+	switch info.ApiType {
+	case constant.APITypeGemini, constant.APITypeVertexAi, constant.APITypeOpenAI, constant.APITypeOpenRouter, constant.APITypeXinference:
+		return true
+	}
+	return false
+}
+
+func geminiViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.GeminiChatRequest) *types.NewAPIError {
+	chatRequest, err := service.GeminiToOpenAIRequest(request, info)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	usage, newAPIError := relayViaChatCompletions(c, info, adaptor, chatRequest, &geminiTranslator{info: info})
+	if newAPIError != nil {
+		return newAPIError
+	}
+	postConsumeQuota(c, info, usage, "")
+	return nil
+}
+
+// geminiTranslator 带 finishReason 的分块延迟到流结束时输出，以便携带最终的 usageMetadata
+type geminiTranslator struct {
+	info      *relaycommon.RelayInfo
+	lastChunk *dto.GeminiChatResponse
+}
+
+func geminiStreamData(response *dto.GeminiChatResponse) ([]byte, error) {
+	data, err := common.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return []byte("data: " + string(data) + "\n\n"), nil
+}
+
+func (t *geminiTranslator) streamChunk(chunk *dto.ChatCompletionsStreamResponse) ([]byte, error) {
+	response := service.StreamResponseOpenAI2Gemini(chunk, t.info)
+	if response == nil {
+		return nil, nil
+	}
+	for _, candidate := range response.Candidates {
+		if candidate.FinishReason != nil {
+			t.lastChunk = response
+			return nil, nil
+		}
+	}
+	return geminiStreamData(response)
+}
+
+func (t *geminiTranslator) streamFinish(usage *dto.Usage) ([]byte, error) {
+	response := t.lastChunk
+	if response == nil {
+		finishReason := "STOP"
+		response = &dto.GeminiChatResponse{
+			Candidates: []dto.GeminiChatCandidate{{
+				Content:       dto.GeminiChatContent{Role: "model", Parts: []dto.GeminiPart{}},
+				FinishReason:  &finishReason,
+				SafetyRatings: []dto.GeminiChatSafetyRating{},
+			}},
+			PromptFeedback: dto.GeminiChatPromptFeedback{SafetyRatings: []dto.GeminiChatSafetyRating{}},
+		}
+	}
+	response.UsageMetadata = service.GeminiUsageMetadata(usage)
+	return geminiStreamData(response)
+}
+
+func (t *geminiTranslator) response(response *dto.OpenAITextResponse, usage *dto.Usage) (any, error) {
+	geminiResponse := service.ResponseOpenAI2Gemini(response, t.info)
+	geminiResponse.UsageMetadata = service.GeminiUsageMetadata(usage)
+	return geminiResponse, nil
+}
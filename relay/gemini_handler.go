@@ -135,6 +135,12 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 	}
 
+	// 流式转换过程中的状态，每次重试重新开始
+	info.GeminiConvertInfo = &relaycommon.GeminiConvertInfo{}
+	if !supportsNativeGemini(info) {
+		return geminiViaChatCompletions(c, info, adaptor, request)
+	}
+
 	var requestBody io.Reader
 	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
 		body, err := common.GetRequestBody(c)
@@ -3,6 +3,7 @@ package helper
 import (
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
 	relaycommon "one-api/relay/common"
 	"one-api/setting/ratio_setting"
 	"one-api/types"
@@ -38,6 +39,11 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) types.
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// batch discount is folded into the group ratio so every billing path picks it up
+	if discount, ok := common.GetContextKeyType[float64](ctx, constant.ContextKeyBatchDiscountRatio); ok && discount > 0 {
+		groupRatioInfo.GroupRatio *= discount
+	}
+
 	return groupRatioInfo
 }
 
@@ -10,6 +10,7 @@ import (
 	"one-api/constant"
 	"one-api/logger"
 	relaycommon "one-api/relay/common"
+	"one-api/service"
 	"one-api/setting/operation_setting"
 	"strings"
 	"sync"
@@ -166,6 +167,43 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		})
 	}
 
+	// 分块先还原请求中被替换为占位符的敏感信息，开启输出敏感词检测时再经过滑动窗口检测，之后交给 dataHandler
+	outputFilter := service.NewStreamOutputFilter(c)
+
+	// handleData 调用 dataHandler，返回 false 时停止读取
+	handleData := func(data string) bool {
+		// 使用超时机制防止写操作阻塞
+		done := make(chan bool, 1)
+		go func() {
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			done <- dataHandler(data)
+		}()
+
+		select {
+		case success := <-done:
+			return success
+		case <-time.After(10 * time.Second):
+			logger.LogError(c, "data handler timeout")
+			return false
+		case <-ctx.Done():
+			return false
+		case <-stopChan:
+			return false
+		}
+	}
+
+	// handleChunk 经过占位符还原与敏感词检测后交给 handleData，返回 false 时停止读取
+	handleChunk := func(data string) bool {
+		ready, stop := outputFilter.Push(data)
+		for _, chunk := range ready {
+			if !handleData(chunk) {
+				return false
+			}
+		}
+		return !stop
+	}
+
 	// Scanner goroutine with improved error handling
 	wg.Add(1)
 	common.RelayCtxGo(ctx, func() {
@@ -210,25 +248,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			if !strings.HasPrefix(data, "[DONE]") {
 				info.SetFirstResponseTime()
 
-				// 使用超时机制防止写操作阻塞
-				done := make(chan bool, 1)
-				go func() {
-					writeMutex.Lock()
-					defer writeMutex.Unlock()
-					done <- dataHandler(data)
-				}()
-
-				select {
-				case success := <-done:
-					if !success {
-						return
-					}
-				case <-time.After(10 * time.Second):
-					logger.LogError(c, "data handler timeout")
-					return
-				case <-ctx.Done():
-					return
-				case <-stopChan:
+				if !handleChunk(data) {
 					return
 				}
 			} else {
@@ -236,7 +256,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				if common.DebugEnabled {
 					println("received [DONE], stopping scanner")
 				}
-				return
+				break
 			}
 		}
 
@@ -245,6 +265,11 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				logger.LogError(c, "scanner error: "+err.Error())
 			}
 		}
+		for _, chunk := range outputFilter.Flush() {
+			if !handleData(chunk) {
+				return
+			}
+		}
 	})
 
 	// 主循环等待完成或超时
@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"one-api/common"
 	"one-api/constant"
 	"one-api/relay/channel"
 	"one-api/relay/channel/ali"
@@ -21,6 +22,7 @@ import (
 	"one-api/relay/channel/moonshot"
 	"one-api/relay/channel/ollama"
 	"one-api/relay/channel/openai"
+	"one-api/relay/channel/openrouter"
 	"one-api/relay/channel/palm"
 	"one-api/relay/channel/perplexity"
 	"one-api/relay/channel/siliconflow"
@@ -109,6 +111,21 @@ func GetAdaptor(apiType int) channel.Adaptor {
 	return nil
 }
 
+// GetBalanceFetcher 返回渠道类型对应的余额查询实现，不支持时返回 nil
+func GetBalanceFetcher(channelType int) channel.BalanceFetcher {
+	if channelType == constant.ChannelTypeOpenRouter {
+		return &openrouter.BalanceFetcher{}
+	}
+	apiType, ok := common.ChannelType2APIType(channelType)
+	if !ok {
+		return nil
+	}
+	if fetcher, ok := GetAdaptor(apiType).(channel.BalanceFetcher); ok {
+		return fetcher
+	}
+	return nil
+}
+
 func GetTaskPlatform(c *gin.Context) constant.TaskPlatform {
 	channelType := c.GetInt("channel_type")
 	if channelType > 0 {
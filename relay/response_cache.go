@@ -0,0 +1,236 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseCacheKey 返回请求的缓存键，不满足缓存条件时返回空字符串。
+// 只缓存结果确定的请求：temperature 为 0、n 不超过 1 且不带工具；缓存按用户和分组隔离
+func ResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo) string {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || info.IsReplay {
+		return ""
+	}
+	if !operation_setting.IsResponseCacheEnabled(info.UsingGroup, common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache)) {
+		return ""
+	}
+	// 分组或令牌开启了敏感信息替换时不使用缓存，渠道级的开关在转发时判断
+	if operation_setting.IsPIIScrubEnabled(info.UsingGroup, false, common.GetContextKeyBool(c, constant.ContextKeyTokenPIIScrub)) {
+		return ""
+	}
+	request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return ""
+	}
+	if request.Temperature == nil || *request.Temperature != 0 || request.N > 1 || len(request.Tools) > 0 || len(request.Functions) > 0 {
+		return ""
+	}
+	// 流式与非流式共用同一份缓存，user 字段不影响生成结果
+	normalized := *request
+	normalized.Stream = false
+	normalized.StreamOptions = nil
+	normalized.User = ""
+	data, err := common.Marshal(&normalized)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("response_cache:%d:%s:%s", info.UserId, info.UsingGroup, hex.EncodeToString(common.Sha256Raw(data)))
+}
+
+// ServeResponseCache 命中缓存时直接返回缓存的响应并按命中倍率计费，返回 true 表示请求已处理完成。
+// 未命中时记录缓存键，由 TextHelper 在请求成功后写入缓存
+func ServeResponseCache(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	key := ResponseCacheKey(c, info)
+	if key == "" {
+		return false
+	}
+	info.ResponseCacheKey = key
+	entry, err := service.GetResponseCache(key)
+	if err != nil {
+		logger.LogError(c, "get response cache failed: "+err.Error())
+		return false
+	}
+	if entry == nil {
+		return false
+	}
+	logger.LogInfo(c, fmt.Sprintf("response cache hit, cached at %d", entry.CreatedAt))
+
+	// 命中缓存不经过任何渠道
+	info.ChannelMeta = &relaycommon.ChannelMeta{UpstreamModelName: info.OriginModelName}
+	info.SetFirstResponseTime()
+	if info.IsStream {
+		info.ShouldIncludeUsage = true
+		if request, ok := info.Request.(*dto.GeneralOpenAIRequest); ok && request.StreamOptions != nil {
+			info.ShouldIncludeUsage = request.StreamOptions.IncludeUsage
+		}
+		replayResponseCacheStream(c, info, entry)
+	} else {
+		response := entry.Response
+		response.Id = helper.GetResponseID(c)
+		response.Created = common.GetTimestamp()
+		c.JSON(http.StatusOK, response)
+	}
+
+	hitRatio := operation_setting.GetResponseCacheSetting().HitRatio
+	common.SetContextKey(c, constant.ContextKeyResponseCacheHit, true)
+	info.PriceData.GroupRatioInfo.GroupRatio *= hitRatio
+	usage := entry.Usage
+	postConsumeQuota(c, info, &usage, fmt.Sprintf("响应缓存命中，计费倍率 %.2f", hitRatio))
+	return true
+}
+
+func replayResponseCacheStream(c *gin.Context, info *relaycommon.RelayInfo, entry *service.ResponseCacheEntry) {
+	helper.SetEventStreamHeaders(c)
+	id := helper.GetResponseID(c)
+	createdAt := common.GetTimestamp()
+	model := entry.Response.Model
+	_ = helper.ObjectData(c, helper.GenerateStartEmptyResponse(id, createdAt, model, nil))
+	finishReason := constant.FinishReasonStop
+	for _, choice := range entry.Response.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+		if choice.ReasoningContent != "" {
+			delta.SetReasoningContent(choice.ReasoningContent)
+		}
+		delta.SetContentString(choice.StringContent())
+		_ = helper.ObjectData(c, &dto.ChatCompletionsStreamResponse{
+			Id:      id,
+			Object:  "chat.completion.chunk",
+			Created: createdAt,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: choice.Index, Delta: delta}},
+		})
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+	}
+	_ = helper.ObjectData(c, helper.GenerateStopResponse(id, createdAt, model, finishReason))
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(id, createdAt, model, entry.Usage))
+	}
+	helper.Done(c)
+}
+
+// responseCaptureWriter 在写给客户端的同时保留一份响应内容，超过大小上限后放弃缓存
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func newResponseCaptureWriter(w gin.ResponseWriter) *responseCaptureWriter {
+	return &responseCaptureWriter{
+		ResponseWriter: w,
+		limit:          operation_setting.GetResponseCacheSetting().MaxEntryKB * 1024,
+	}
+}
+
+func (w *responseCaptureWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+func (w *responseCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// storeResponseCache 将本次返回给客户端的内容写入缓存，流式响应会先合并为非流式响应
+func storeResponseCache(c *gin.Context, info *relaycommon.RelayInfo, w *responseCaptureWriter, usage *dto.Usage) {
+	if w.overflow || usage == nil || w.Status() != http.StatusOK {
+		return
+	}
+	var response *dto.OpenAITextResponse
+	if info.IsStream {
+		response = mergeStreamResponse(w.body.String())
+	} else {
+		var textResponse dto.OpenAITextResponse
+		if err := common.Unmarshal(w.body.Bytes(), &textResponse); err == nil && textResponse.Error == nil {
+			response = &textResponse
+		}
+	}
+	if response == nil || len(response.Choices) == 0 {
+		return
+	}
+	response.Usage = *usage
+	entry := &service.ResponseCacheEntry{
+		Response:  *response,
+		Usage:     *usage,
+		CreatedAt: common.GetTimestamp(),
+	}
+	if err := service.SetResponseCache(info.ResponseCacheKey, entry); err != nil {
+		logger.LogError(c, "set response cache failed: "+err.Error())
+	}
+}
+
+// mergeStreamResponse 解析返回给客户端的 SSE 数据，流未正常结束或包含工具调用时返回 nil
+func mergeStreamResponse(data string) *dto.OpenAITextResponse {
+	var response dto.OpenAITextResponse
+	var content, reasoning strings.Builder
+	finishReason := ""
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+			return nil
+		}
+		if response.Id == "" {
+			response.Id = chunk.Id
+			response.Model = chunk.Model
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 || len(choice.Delta.ToolCalls) > 0 {
+				return nil
+			}
+			content.WriteString(choice.Delta.GetContentString())
+			reasoning.WriteString(choice.Delta.GetReasoningContent())
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if finishReason == "" {
+		return nil
+	}
+	message := dto.Message{
+		Role:             "assistant",
+		ReasoningContent: reasoning.String(),
+	}
+	message.SetStringContent(content.String())
+	response.Object = "chat.completion"
+	response.Choices = []dto.OpenAITextResponseChoice{{Index: 0, Message: message, FinishReason: finishReason}}
+	return &response
+}
@@ -0,0 +1,68 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportsNativeResponses 使用 OpenAI 适配器的渠道直接转发 /v1/responses，其他渠道转换为 Chat Completions
+func supportsNativeResponses(info *relaycommon.RelayInfo) bool {
+	switch info.ApiType {
+	case constant.APITypeOpenAI, constant.APITypeOpenRouter, constant.APITypeCloudflare:
+		return true
+	}
+	return false
+}
+
+func responsesViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.OpenAIResponsesRequest) *types.NewAPIError {
+	chatRequest, err := service.ResponsesToOpenAIRequest(request)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	translator := &responsesTranslator{request: request, converter: service.NewResponsesStreamConverter(request)}
+	usage, newAPIError := relayViaChatCompletions(c, info, adaptor, chatRequest, translator)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	postConsumeQuota(c, info, usage, "")
+	return nil
+}
+
+type responsesTranslator struct {
+	request   *dto.OpenAIResponsesRequest
+	converter *service.ResponsesStreamConverter
+}
+
+func (t *responsesTranslator) events(events []dto.ResponsesStreamResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "event: %s\ndata: %s\n\n", event.Type, data)
+	}
+	return buf.Bytes(), nil
+}
+
+func (t *responsesTranslator) streamChunk(chunk *dto.ChatCompletionsStreamResponse) ([]byte, error) {
+	return t.events(t.converter.Convert(chunk))
+}
+
+func (t *responsesTranslator) streamFinish(usage *dto.Usage) ([]byte, error) {
+	return t.events(t.converter.Finish(usage))
+}
+
+func (t *responsesTranslator) response(response *dto.OpenAITextResponse, usage *dto.Usage) (any, error) {
+	return service.ResponseOpenAI2Responses(response, t.request, usage), nil
+}
@@ -129,10 +129,17 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/scores", controller.GetChannelScores)
+			channelRoute.GET("/circuit_breakers", controller.GetCircuitBreakers)
+			channelRoute.POST("/circuit_breakers/reset", controller.ResetCircuitBreakers)
+			channelRoute.GET("/abilities/disabled", controller.GetDisabledAbilities)
+			channelRoute.POST("/abilities/enable", controller.EnableAbility)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.GET("/test_matrix/:id", controller.TestChannelMatrix)
+			channelRoute.GET("/test_results/:id", controller.GetChannelTestResults)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -186,12 +193,38 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/name/:name", controller.DeleteRedemptionsByName)
 			redemptionRoute.DELETE("/batch", controller.DeleteRedemptionsByNames)
 		}
+		quotaLedgerRoute := apiRouter.Group("/quota_ledger")
+		{
+			quotaLedgerRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaLedgers)
+			quotaLedgerRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaLedgers)
+			quotaLedgerRoute.GET("/reconcile", middleware.AdminAuth(), controller.GetQuotaReconcileReport)
+			quotaLedgerRoute.POST("/reconcile", middleware.RootAuth(), controller.ReconcileQuota)
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationRoute.GET("/", middleware.AdminAuth(), controller.GetAllOrganizations)
+			organizationRoute.POST("/", middleware.AdminAuth(), controller.CreateOrganization)
+			organizationRoute.PUT("/", middleware.AdminAuth(), controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", middleware.AdminAuth(), controller.DeleteOrganization)
+			organizationRoute.GET("/self", middleware.UserAuth(), controller.GetSelfOrganizations)
+			organizationRoute.POST("/self", middleware.UserAuth(), controller.CreateSelfOrganization)
+			organizationRoute.GET("/:id/member", middleware.UserAuth(), controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/member", middleware.UserAuth(), controller.AddOrganizationMember)
+			organizationRoute.PUT("/:id/member", middleware.UserAuth(), controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/member/:user_id", middleware.UserAuth(), controller.RemoveOrganizationMember)
+			organizationRoute.POST("/:id/transfer", middleware.UserAuth(), controller.TransferQuotaToOrganization)
+			organizationRoute.GET("/:id/log", middleware.UserAuth(), controller.GetOrganizationLogs)
+			organizationRoute.GET("/:id/data", middleware.UserAuth(), controller.GetOrganizationQuotaDates)
+			organizationRoute.GET("/:id/quota_ledger", middleware.UserAuth(), controller.GetOrganizationQuotaLedgers)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/detail/:id", middleware.AdminAuth(), controller.GetLogDetail)
+		logRoute.POST("/replay", middleware.AdminAuth(), controller.ReplayRequest)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
@@ -0,0 +1,12 @@
+package router
+
+import (
+	"one-api/common/metrics"
+	"one-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetMetricsRouter(router *gin.Engine) {
+	router.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(metrics.Handler()))
+}
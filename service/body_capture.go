@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// BodyCapture 收集一次请求的客户端请求、转换后的上游请求与上游响应，请求结束时保存。
+// 重试时只保留最后一次上游请求；对冲请求会并发写入，需要加锁
+type BodyCapture struct {
+	lock      sync.Mutex
+	maxBytes  int
+	record    model.RequestCapture
+	response  bytes.Buffer
+	truncated bool
+}
+
+// 流式响应需要完整内容才能拼接，原始响应按上限的数倍缓存，保存时再截断
+const bodyCaptureStreamFactor = 4
+
+// StartBodyCapture 按配置判断是否记录本次请求，命中时保存客户端请求体。
+// 回放请求总是记录且不限制大小，供回放接口返回
+func StartBodyCapture(c *gin.Context) {
+	setting := operation_setting.GetBodyCaptureSetting()
+	userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	tokenId := common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	maxBytes := setting.MaxBodyBytes
+	if common.GetContextKeyBool(c, constant.ContextKeyReplay) {
+		maxBytes = 0
+	} else if !setting.Enabled {
+		return
+	} else if !slices.Contains(setting.UserIds, userId) && !slices.Contains(setting.TokenIds, tokenId) &&
+		(setting.SampleRate <= 0 || rand.Float64() >= setting.SampleRate) {
+		return
+	}
+	capture := &BodyCapture{
+		maxBytes: maxBytes,
+		record: model.RequestCapture{
+			RequestId:   c.GetString(common.RequestIdKey),
+			CreatedAt:   common.GetTimestamp(),
+			UserId:      userId,
+			TokenId:     tokenId,
+			ModelName:   common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+			RequestPath: c.Request.URL.RequestURI(),
+			RelayFormat: common.GetContextKeyString(c, constant.ContextKeyRelayFormat),
+		},
+	}
+	if isTextContentType(c.Request.Header.Get("Content-Type")) {
+		body, err := common.GetRequestBody(c)
+		if err == nil {
+			capture.record.ClientRequest = string(body)
+		}
+	} else {
+		capture.record.ClientRequest = fmt.Sprintf("[%s body omitted]", c.Request.Header.Get("Content-Type"))
+	}
+	common.SetContextKey(c, constant.ContextKeyBodyCapture, capture)
+}
+
+func getBodyCapture(c *gin.Context) *BodyCapture {
+	capture, ok := common.GetContextKeyType[*BodyCapture](c, constant.ContextKeyBodyCapture)
+	if !ok {
+		return nil
+	}
+	return capture
+}
+
+func isTextContentType(contentType string) bool {
+	return contentType == "" || strings.Contains(contentType, "json") || strings.HasPrefix(contentType, "text/")
+}
+
+// CaptureUpstreamRequest 记录发往上游的请求体，返回可以继续使用的 reader
+func CaptureUpstreamRequest(c *gin.Context, channelId int, requestBody io.Reader) io.Reader {
+	capture := getBodyCapture(c)
+	if capture == nil || requestBody == nil {
+		return requestBody
+	}
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return bytes.NewReader(body)
+	}
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+	capture.record.ChannelId = channelId
+	if isTextContentType(c.Request.Header.Get("Content-Type")) {
+		capture.record.UpstreamRequest = string(body)
+	} else {
+		capture.record.UpstreamRequest = fmt.Sprintf("[%d bytes omitted]", len(body))
+	}
+	capture.response.Reset()
+	capture.truncated = false
+	return bytes.NewReader(body)
+}
+
+// CaptureUpstreamResponse 在客户端读取上游响应的同时复制一份内容
+func CaptureUpstreamResponse(c *gin.Context, resp *http.Response) {
+	capture := getBodyCapture(c)
+	if capture == nil || resp == nil || resp.Body == nil {
+		return
+	}
+	capture.lock.Lock()
+	capture.record.IsStream = strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	capture.lock.Unlock()
+	resp.Body = &captureReadCloser{ReadCloser: resp.Body, capture: capture}
+}
+
+type captureReadCloser struct {
+	io.ReadCloser
+	capture *BodyCapture
+}
+
+func (r *captureReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.capture.appendResponse(p[:n])
+	}
+	return n, err
+}
+
+func (capture *BodyCapture) appendResponse(p []byte) {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+	limit := capture.maxBytes * bodyCaptureStreamFactor
+	remain := limit - capture.response.Len()
+	if limit <= 0 || remain >= len(p) {
+		capture.response.Write(p)
+	} else {
+		if remain > 0 {
+			capture.response.Write(p[:remain])
+		}
+		capture.truncated = true
+	}
+}
+
+// CaptureUpstreamResponseData 记录不经过 http.Response 的上游响应（如 AWS SDK），流式响应按 SSE 格式逐块传入
+func CaptureUpstreamResponseData(c *gin.Context, data []byte, isStream bool) {
+	capture := getBodyCapture(c)
+	if capture == nil {
+		return
+	}
+	capture.lock.Lock()
+	capture.record.IsStream = isStream
+	capture.lock.Unlock()
+	capture.appendResponse(data)
+}
+
+func (capture *BodyCapture) snapshot() model.RequestCapture {
+	capture.lock.Lock()
+	record := capture.record
+	record.UpstreamResponse = capture.response.String()
+	record.Truncated = capture.truncated
+	capture.lock.Unlock()
+
+	if record.IsStream {
+		record.ResponseText = reassembleStreamResponse(record.UpstreamResponse)
+	}
+	return record
+}
+
+// GetBodyCaptureRecord 返回本次请求目前记录的内容（未脱敏），未记录时返回 nil
+func GetBodyCaptureRecord(c *gin.Context) *model.RequestCapture {
+	capture := getBodyCapture(c)
+	if capture == nil {
+		return nil
+	}
+	record := capture.snapshot()
+	return &record
+}
+
+// FinishBodyCapture 脱敏、截断后异步保存记录，回放请求不保存
+func FinishBodyCapture(c *gin.Context) {
+	capture := getBodyCapture(c)
+	if capture == nil || common.GetContextKeyBool(c, constant.ContextKeyReplay) {
+		return
+	}
+	record := capture.snapshot()
+	patterns := operation_setting.GetBodyCaptureSetting().GetRedactPatterns()
+	for _, field := range []*string{&record.ClientRequest, &record.UpstreamRequest, &record.UpstreamResponse, &record.ResponseText} {
+		for _, re := range patterns {
+			*field = re.ReplaceAllString(*field, "[REDACTED]")
+		}
+		if capture.maxBytes > 0 && len(*field) > capture.maxBytes {
+			*field = strings.ToValidUTF8((*field)[:capture.maxBytes], "")
+			record.Truncated = true
+		}
+	}
+	gopool.Go(func() {
+		if err := model.CreateRequestCapture(&record); err != nil {
+			common.SysLog("failed to save request capture: " + err.Error())
+		}
+	})
+}
+
+// captureStreamChunk 兼容 OpenAI Chat Completions、Responses、Claude 与 Gemini 的流式分块
+type captureStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+		Text string `json:"text"`
+	} `json:"choices"`
+	Type       string          `json:"type"`
+	Delta      json.RawMessage `json:"delta"`
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+// reassembleStreamResponse 将流式响应中的文本增量拼接为完整内容
+func reassembleStreamResponse(raw string) string {
+	var text strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var chunk captureStreamChunk
+		if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.ReasoningContent)
+			text.WriteString(choice.Delta.Content)
+			text.WriteString(choice.Text)
+		}
+		for _, candidate := range chunk.Candidates {
+			for _, part := range candidate.Content.Parts {
+				text.WriteString(part.Text)
+			}
+		}
+		if len(chunk.Delta) > 0 {
+			// Responses 的 delta 为字符串，Claude 的 delta 为对象
+			var delta string
+			if chunk.Delta[0] == '"' {
+				if strings.HasSuffix(chunk.Type, ".delta") {
+					_ = common.Unmarshal(chunk.Delta, &delta)
+				}
+			} else {
+				var claudeDelta struct {
+					Text     string `json:"text"`
+					Thinking string `json:"thinking"`
+				}
+				_ = common.Unmarshal(chunk.Delta, &claudeDelta)
+				delta = claudeDelta.Thinking + claudeDelta.Text
+			}
+			text.WriteString(delta)
+		}
+	}
+	return text.String()
+}
+
+var cleanBodyCapturesOnce sync.Once
+
+// AutomaticallyCleanBodyCaptures 每小时删除超过保留天数的记录
+func AutomaticallyCleanBodyCaptures() {
+	cleanBodyCapturesOnce.Do(func() {
+		for {
+			time.Sleep(time.Hour)
+			retentionDays := operation_setting.GetBodyCaptureSetting().RetentionDays
+			if retentionDays <= 0 {
+				continue
+			}
+			deadline := time.Now().AddDate(0, 0, -retentionDays).Unix()
+			rows, err := model.DeleteRequestCapturesBefore(deadline)
+			if err != nil {
+				common.SysLog("failed to clean request captures: " + err.Error())
+			} else if rows > 0 {
+				common.SysLog(fmt.Sprintf("cleaned %d request captures", rows))
+			}
+		}
+	})
+}
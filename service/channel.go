@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/metrics"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
@@ -28,6 +29,7 @@ func DisableChannel(channelError types.ChannelError, reason string) {
 
 	success := model.UpdateChannelStatus(channelError.ChannelId, channelError.UsingKey, common.ChannelStatusAutoDisabled, reason)
 	if success {
+		metrics.RecordChannelAutoDisabled(channelError.ChannelId)
 		subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelError.ChannelName, channelError.ChannelId)
 		content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelError.ChannelName, channelError.ChannelId, reason)
 		NotifyRootUser(formatNotifyType(channelError.ChannelId, common.ChannelStatusAutoDisabled), subject, content)
@@ -47,6 +49,24 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	if !common.AutomaticDisableChannelEnabled {
 		return false
 	}
+	return isPermanentChannelError(channelType, err)
+}
+
+// IsTransientChannelError 429、超时与 5xx 等暂时性错误交给熔断器处理，不永久禁用渠道；
+// 余额不足、密钥失效等即使返回 429/5xx 也视为永久性错误
+func IsTransientChannelError(channelType int, err *types.NewAPIError) bool {
+	if err == nil || types.IsChannelError(err) || types.IsSkipRetryError(err) {
+		return false
+	}
+	switch {
+	case err.StatusCode == http.StatusTooManyRequests, err.StatusCode == http.StatusRequestTimeout, err.StatusCode/100 == 5:
+		return !isPermanentChannelError(channelType, err)
+	default:
+		return false
+	}
+}
+
+func isPermanentChannelError(channelType int, err *types.NewAPIError) bool {
 	if err == nil {
 		return false
 	}
@@ -95,6 +115,49 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	return search
 }
 
+// IsModelSpecificError 上游明确表示模型不存在时，只影响当前模型，不应禁用整个渠道。
+// 不支持某个参数是客户端请求的问题，不据此禁用模型，否则一个用户的请求会影响所有用户
+func IsModelSpecificError(err *types.NewAPIError) bool {
+	if !common.AutomaticDisableChannelEnabled {
+		return false
+	}
+	if err == nil || types.IsChannelError(err) || types.IsSkipRetryError(err) {
+		return false
+	}
+	if err.StatusCode != http.StatusBadRequest && err.StatusCode != http.StatusNotFound {
+		return false
+	}
+	oaiErr := err.ToOpenAIError()
+	switch oaiErr.Code {
+	case "model_not_found", "unsupported_model":
+		return true
+	}
+	lowerMessage := strings.ToLower(err.Error())
+	switch oaiErr.Type {
+	case "model_not_found":
+		return true
+	case "not_found_error":
+		// Anthropic 模型不存在时返回 "model: <name>"，其他 404 可能是渠道地址配置错误
+		return strings.HasPrefix(lowerMessage, "model:")
+	}
+	return strings.Contains(lowerMessage, "model_not_found") ||
+		strings.Contains(lowerMessage, "does not exist") && strings.Contains(lowerMessage, "model")
+}
+
+// DisableAbility 只禁用渠道在某个分组下的某个模型，其他模型继续可用，由渠道测试恢复
+func DisableAbility(channelError types.ChannelError, group string, modelName string, reason string) {
+	changed, err := model.UpdateAbilityStatusByGroupModel(group, modelName, channelError.ChannelId, false, reason)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to disable ability: channel_id=%d, group=%s, model=%s, error=%v", channelError.ChannelId, group, modelName, err))
+		return
+	}
+	if !changed {
+		return
+	}
+	model.CacheDisableAbility(group, modelName, channelError.ChannelId)
+	common.SysLog(fmt.Sprintf("通道「%s」（#%d）的模型 %s（分组 %s）已被禁用，原因：%s", channelError.ChannelName, channelError.ChannelId, modelName, group, reason))
+}
+
 func ShouldEnableChannel(newAPIError *types.NewAPIError, status int) bool {
 	if !common.AutomaticEnableChannelEnabled {
 		return false
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+)
+
+// other_info 中记录低余额状态的字段，用于避免重复通知以及在余额恢复后还原权重
+const (
+	otherInfoLowBalance         = "low_balance"
+	otherInfoLowBalanceOriginal = "low_balance_origin_weight"
+)
+
+// CheckChannelBalance 在余额更新后检查渠道的低余额阈值：
+// 首次低于阈值时通知管理员，并按设置降低渠道权重；余额恢复后还原权重
+func CheckChannelBalance(channel *model.Channel, balance float64) {
+	setting := channel.GetSetting()
+	info := channel.GetOtherInfo()
+	wasLow, _ := info[otherInfoLowBalance].(bool)
+	isLow := setting.LowBalanceThreshold > 0 && balance < setting.LowBalanceThreshold
+	if isLow == wasLow {
+		return
+	}
+
+	if isLow {
+		info[otherInfoLowBalance] = true
+		content := fmt.Sprintf("通道「%s」（#%d）余额 %.4f 低于阈值 %.4f", channel.Name, channel.Id, balance, setting.LowBalanceThreshold)
+		if setting.LowBalanceWeight != nil && *setting.LowBalanceWeight < uint(channel.GetWeight()) {
+			info[otherInfoLowBalanceOriginal] = channel.GetWeight()
+			if err := channel.UpdateWeight(*setting.LowBalanceWeight); err != nil {
+				common.SysLog(fmt.Sprintf("failed to lower weight of channel #%d: %v", channel.Id, err))
+			} else {
+				content += fmt.Sprintf("，权重已调整为 %d", *setting.LowBalanceWeight)
+			}
+		}
+		common.SysLog(content)
+		NotifyRootUser(fmt.Sprintf("%s_%d_low_balance", dto.NotifyTypeChannelUpdate, channel.Id), fmt.Sprintf("通道「%s」（#%d）余额不足", channel.Name, channel.Id), content)
+	} else {
+		delete(info, otherInfoLowBalance)
+		// JSON 反序列化后数字为 float64
+		if origin, ok := info[otherInfoLowBalanceOriginal].(float64); ok {
+			if err := channel.UpdateWeight(uint(origin)); err != nil {
+				common.SysLog(fmt.Sprintf("failed to restore weight of channel #%d: %v", channel.Id, err))
+			}
+			delete(info, otherInfoLowBalanceOriginal)
+		}
+		common.SysLog(fmt.Sprintf("通道「%s」（#%d）余额已恢复至 %.4f", channel.Name, channel.Id, balance))
+	}
+	if err := channel.UpdateOtherInfo(info); err != nil {
+		common.SysLog(fmt.Sprintf("failed to update other info of channel #%d: %v", channel.Id, err))
+	}
+}
@@ -470,6 +470,10 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		Stream: info.IsStream,
 	}
 
+	// Gemini 的工具调用没有 ID，按函数名将响应与最早尚未响应的调用对应起来
+	callCount := 0
+	pendingCallIds := make(map[string][]string)
+
 	// 转换 messages
 	var messages []dto.Message
 	for _, content := range geminiRequest.Contents {
@@ -481,6 +485,10 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		var mediaContents []dto.MediaContent
 		var toolCalls []dto.ToolCallRequest
 		for _, part := range content.Parts {
+			if part.Thought {
+				// 历史中的思考内容不回传给上游
+				continue
+			}
 			if part.Text != "" {
 				mediaContent := dto.MediaContent{
 					Type: "text",
@@ -488,15 +496,7 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				}
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.InlineData != nil {
-				mediaContent := dto.MediaContent{
-					Type: "image_url",
-					ImageUrl: &dto.MessageImageUrl{
-						Url:      fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
-						Detail:   "auto",
-						MimeType: part.InlineData.MimeType,
-					},
-				}
-				mediaContents = append(mediaContents, mediaContent)
+				mediaContents = append(mediaContents, geminiInlineDataToOpenAI(part.InlineData))
 			} else if part.FileData != nil {
 				mediaContent := dto.MediaContent{
 					Type: "image_url",
@@ -509,8 +509,11 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.FunctionCall != nil {
 				// 处理 Gemini 的工具调用
+				callCount++
+				callId := fmt.Sprintf("call_%d", callCount)
+				pendingCallIds[part.FunctionCall.FunctionName] = append(pendingCallIds[part.FunctionCall.FunctionName], callId)
 				toolCall := dto.ToolCallRequest{
-					ID:   fmt.Sprintf("call_%d", len(toolCalls)+1), // 生成唯一ID
+					ID:   callId,
 					Type: "function",
 					Function: dto.FunctionRequest{
 						Name:      part.FunctionCall.FunctionName,
@@ -520,20 +523,28 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				toolCalls = append(toolCalls, toolCall)
 			} else if part.FunctionResponse != nil {
 				// 处理 Gemini 的工具响应，创建单独的 tool 消息
+				var callId string
+				if ids := pendingCallIds[part.FunctionResponse.Name]; len(ids) > 0 {
+					callId = ids[0]
+					pendingCallIds[part.FunctionResponse.Name] = ids[1:]
+				} else {
+					callCount++
+					callId = fmt.Sprintf("call_%d", callCount)
+				}
 				toolMessage := dto.Message{
 					Role:       "tool",
-					ToolCallId: fmt.Sprintf("call_%d", len(toolCalls)), // 使用对应的调用ID
+					ToolCallId: callId,
 				}
 				toolMessage.SetStringContent(toJSONString(part.FunctionResponse.Response))
 				messages = append(messages, toolMessage)
 			}
 		}
 
-		// 设置消息内容
+		// 设置消息内容，文本与工具调用可以同时存在
 		if len(toolCalls) > 0 {
-			// 如果有工具调用，设置工具调用
 			message.SetToolCalls(toolCalls)
-		} else if len(mediaContents) == 1 && mediaContents[0].Type == "text" {
+		}
+		if len(mediaContents) == 1 && mediaContents[0].Type == "text" {
 			// 如果只有一个文本内容，直接设置字符串
 			message.Content = mediaContents[0].Text
 		} else if len(mediaContents) > 0 {
@@ -549,24 +560,54 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 
 	openaiRequest.Messages = messages
 
-	if geminiRequest.GenerationConfig.Temperature != nil {
-		openaiRequest.Temperature = geminiRequest.GenerationConfig.Temperature
+	generationConfig := geminiRequest.GenerationConfig
+	if generationConfig.Temperature != nil {
+		openaiRequest.Temperature = generationConfig.Temperature
 	}
-	if geminiRequest.GenerationConfig.TopP > 0 {
-		openaiRequest.TopP = geminiRequest.GenerationConfig.TopP
+	if generationConfig.TopP > 0 {
+		openaiRequest.TopP = generationConfig.TopP
 	}
-	if geminiRequest.GenerationConfig.TopK > 0 {
-		openaiRequest.TopK = int(geminiRequest.GenerationConfig.TopK)
+	if generationConfig.TopK > 0 {
+		openaiRequest.TopK = int(generationConfig.TopK)
 	}
-	if geminiRequest.GenerationConfig.MaxOutputTokens > 0 {
-		openaiRequest.MaxTokens = geminiRequest.GenerationConfig.MaxOutputTokens
+	if generationConfig.MaxOutputTokens > 0 {
+		openaiRequest.MaxTokens = generationConfig.MaxOutputTokens
 	}
 	// gemini stop sequences 最多 5 个，openai stop 最多 4 个
-	if len(geminiRequest.GenerationConfig.StopSequences) > 0 {
-		openaiRequest.Stop = geminiRequest.GenerationConfig.StopSequences[:4]
+	if stops := generationConfig.StopSequences; len(stops) > 0 {
+		if len(stops) > 4 {
+			stops = stops[:4]
+		}
+		openaiRequest.Stop = stops
+	}
+	if generationConfig.CandidateCount > 0 {
+		openaiRequest.N = generationConfig.CandidateCount
+	}
+	if generationConfig.PresencePenalty != nil {
+		openaiRequest.PresencePenalty = float64(*generationConfig.PresencePenalty)
 	}
-	if geminiRequest.GenerationConfig.CandidateCount > 0 {
-		openaiRequest.N = geminiRequest.GenerationConfig.CandidateCount
+	if generationConfig.FrequencyPenalty != nil {
+		openaiRequest.FrequencyPenalty = float64(*generationConfig.FrequencyPenalty)
+	}
+	if generationConfig.Seed != 0 {
+		openaiRequest.Seed = float64(generationConfig.Seed)
+	}
+	if generationConfig.ResponseMimeType == "application/json" {
+		var schema any
+		if len(generationConfig.ResponseJsonSchema) > 0 {
+			schema = generationConfig.ResponseJsonSchema
+		} else if generationConfig.ResponseSchema != nil {
+			schema = generationConfig.ResponseSchema
+		}
+		if schema != nil {
+			jsonSchema, err := json.Marshal(dto.FormatJsonSchema{Name: "response", Schema: schema})
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal response schema: %w", err)
+			}
+			openaiRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: jsonSchema}
+		} else {
+			openaiRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		}
 	}
 
 	// 转换工具调用
@@ -575,19 +616,20 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		for _, tool := range geminiRequest.GetTools() {
 			if tool.FunctionDeclarations != nil {
 				// 将 Gemini 的 FunctionDeclarations 转换为 OpenAI 的 ToolCallRequest
-				functionDeclarations, ok := tool.FunctionDeclarations.([]dto.FunctionRequest)
-				if ok {
-					for _, function := range functionDeclarations {
-						openAITool := dto.ToolCallRequest{
-							Type: "function",
-							Function: dto.FunctionRequest{
-								Name:        function.Name,
-								Description: function.Description,
-								Parameters:  function.Parameters,
-							},
-						}
-						tools = append(tools, openAITool)
+				functionDeclarations, err := common.Any2Type[[]dto.FunctionRequest](tool.FunctionDeclarations)
+				if err != nil {
+					return nil, fmt.Errorf("invalid function declarations: %w", err)
+				}
+				for _, function := range functionDeclarations {
+					openAITool := dto.ToolCallRequest{
+						Type: "function",
+						Function: dto.FunctionRequest{
+							Name:        function.Name,
+							Description: function.Description,
+							Parameters:  function.Parameters,
+						},
 					}
+					tools = append(tools, openAITool)
 				}
 			}
 		}
@@ -595,6 +637,24 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 			openaiRequest.Tools = tools
 		}
 	}
+	if geminiRequest.ToolConfig != nil && geminiRequest.ToolConfig.FunctionCallingConfig != nil {
+		config := geminiRequest.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(string(config.Mode)) {
+		case "AUTO":
+			openaiRequest.ToolChoice = "auto"
+		case "NONE":
+			openaiRequest.ToolChoice = "none"
+		case "ANY":
+			if len(config.AllowedFunctionNames) == 1 {
+				openaiRequest.ToolChoice = map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": config.AllowedFunctionNames[0]},
+				}
+			} else {
+				openaiRequest.ToolChoice = "required"
+			}
+		}
+	}
 
 	// gemini system instructions
 	if geminiRequest.SystemInstructions != nil {
@@ -609,6 +669,41 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 	return openaiRequest, nil
 }
 
+// geminiInlineDataToOpenAI 图片转换为 image_url，音频转换为 input_audio，其他类型（如 PDF）作为文件传递
+func geminiInlineDataToOpenAI(inlineData *dto.GeminiInlineData) dto.MediaContent {
+	mimeType := inlineData.MimeType
+	switch {
+	case strings.HasPrefix(mimeType, "audio/"):
+		format := strings.TrimPrefix(strings.TrimPrefix(mimeType, "audio/"), "x-")
+		if format == "mpeg" {
+			format = "mp3"
+		}
+		return dto.MediaContent{
+			Type: dto.ContentTypeInputAudio,
+			InputAudio: &dto.MessageInputAudio{
+				Data:   inlineData.Data,
+				Format: format,
+			},
+		}
+	case mimeType == "" || strings.HasPrefix(mimeType, "image/"):
+		return dto.MediaContent{
+			Type: dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{
+				Url:      fmt.Sprintf("data:%s;base64,%s", mimeType, inlineData.Data),
+				Detail:   "auto",
+				MimeType: mimeType,
+			},
+		}
+	default:
+		return dto.MediaContent{
+			Type: dto.ContentTypeFile,
+			File: &dto.MessageFile{
+				FileData: fmt.Sprintf("data:%s;base64,%s", mimeType, inlineData.Data),
+			},
+		}
+	}
+}
+
 func convertGeminiRoleToOpenAI(geminiRole string) string {
 	switch geminiRole {
 	case "user":
@@ -632,6 +727,46 @@ func extractTextFromGeminiParts(parts []dto.GeminiPart) string {
 	return strings.Join(texts, "\n")
 }
 
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// GeminiUsageMetadata 将 OpenAI 用量转换为 Gemini 的 usageMetadata，candidatesTokenCount 不包含思考 token
+func GeminiUsageMetadata(usage *dto.Usage) dto.GeminiUsageMetadata {
+	thoughts := usage.CompletionTokenDetails.ReasoningTokens
+	return dto.GeminiUsageMetadata{
+		PromptTokenCount:     usage.PromptTokens,
+		CandidatesTokenCount: usage.CompletionTokens - thoughts,
+		ThoughtsTokenCount:   thoughts,
+		TotalTokenCount:      usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+func toolCallToGeminiPart(name string, arguments string) dto.GeminiPart {
+	// 解析参数
+	var args map[string]interface{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			args = map[string]interface{}{"arguments": arguments}
+		}
+	} else {
+		args = make(map[string]interface{})
+	}
+	return dto.GeminiPart{
+		FunctionCall: &dto.FunctionCall{
+			FunctionName: name,
+			Arguments:    args,
+		},
+	}
+}
+
 // ResponseOpenAI2Gemini 将 OpenAI 响应转换为 Gemini 格式
 func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
 	geminiResponse := &dto.GeminiChatResponse{
@@ -639,11 +774,7 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 		PromptFeedback: dto.GeminiChatPromptFeedback{
 			SafetyRatings: []dto.GeminiChatSafetyRating{},
 		},
-		UsageMetadata: dto.GeminiUsageMetadata{
-			PromptTokenCount:     openAIResponse.PromptTokens,
-			CandidatesTokenCount: openAIResponse.CompletionTokens,
-			TotalTokenCount:      openAIResponse.PromptTokens + openAIResponse.CompletionTokens,
-		},
+		UsageMetadata: GeminiUsageMetadata(&openAIResponse.Usage),
 	}
 
 	for _, choice := range openAIResponse.Choices {
@@ -653,19 +784,7 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 		}
 
 		// 设置结束原因
-		var finishReason string
-		switch choice.FinishReason {
-		case "stop":
-			finishReason = "STOP"
-		case "length":
-			finishReason = "MAX_TOKENS"
-		case "content_filter":
-			finishReason = "SAFETY"
-		case "tool_calls":
-			finishReason = "STOP"
-		default:
-			finishReason = "STOP"
-		}
+		finishReason := finishReasonOpenAI2Gemini(choice.FinishReason)
 		candidate.FinishReason = &finishReason
 
 		// 转换消息内容
@@ -674,37 +793,19 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 			Parts: make([]dto.GeminiPart, 0),
 		}
 
-		// 处理工具调用
-		toolCalls := choice.Message.ParseToolCalls()
-		if len(toolCalls) > 0 {
-			for _, toolCall := range toolCalls {
-				// 解析参数
-				var args map[string]interface{}
-				if toolCall.Function.Arguments != "" {
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-						args = map[string]interface{}{"arguments": toolCall.Function.Arguments}
-					}
-				} else {
-					args = make(map[string]interface{})
-				}
-
-				part := dto.GeminiPart{
-					FunctionCall: &dto.FunctionCall{
-						FunctionName: toolCall.Function.Name,
-						Arguments:    args,
-					},
-				}
-				content.Parts = append(content.Parts, part)
-			}
-		} else {
-			// 处理文本内容
-			textContent := choice.Message.StringContent()
-			if textContent != "" {
-				part := dto.GeminiPart{
-					Text: textContent,
-				}
-				content.Parts = append(content.Parts, part)
-			}
+		// 思考内容在前，其次是文本，最后是工具调用
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: reasoning, Thought: true})
+		}
+		if textContent := choice.Message.StringContent(); textContent != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: textContent})
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			content.Parts = append(content.Parts, toolCallToGeminiPart(toolCall.Function.Name, toolCall.Function.Arguments))
 		}
 
 		candidate.Content = content
@@ -714,15 +815,25 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 	return geminiResponse
 }
 
-// StreamResponseOpenAI2Gemini 将 OpenAI 流式响应转换为 Gemini 格式
+// StreamResponseOpenAI2Gemini 将 OpenAI 流式响应转换为 Gemini 格式。
+// info.GeminiConvertInfo 不为空时工具调用参数会拼接完整，在收到 finish_reason 时一次性输出，与 Gemini 原生流一致
 func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
+	convertInfo := info.GeminiConvertInfo
+
 	// 检查是否有实际内容或结束标志
 	hasContent := false
 	hasFinishReason := false
 	for _, choice := range openAIResponse.Choices {
-		if len(choice.Delta.GetContentString()) > 0 || (choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0) {
+		if len(choice.Delta.GetContentString()) > 0 || len(choice.Delta.GetReasoningContent()) > 0 {
 			hasContent = true
 		}
+		if len(choice.Delta.ToolCalls) > 0 {
+			if convertInfo == nil {
+				hasContent = true
+			} else {
+				convertInfo.AppendToolCalls(choice.Delta.ToolCalls)
+			}
+		}
 		if choice.FinishReason != nil {
 			hasFinishReason = true
 		}
@@ -733,16 +844,20 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 		return nil
 	}
 
+	usageMetadata := dto.GeminiUsageMetadata{
+		PromptTokenCount:     info.PromptTokens,
+		CandidatesTokenCount: 0, // 流式响应中可能没有完整的 usage 信息
+		TotalTokenCount:      info.PromptTokens,
+	}
+	if ValidUsage(openAIResponse.Usage) {
+		usageMetadata = GeminiUsageMetadata(openAIResponse.Usage)
+	}
 	geminiResponse := &dto.GeminiChatResponse{
 		Candidates: make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
 		PromptFeedback: dto.GeminiChatPromptFeedback{
 			SafetyRatings: []dto.GeminiChatSafetyRating{},
 		},
-		UsageMetadata: dto.GeminiUsageMetadata{
-			PromptTokenCount:     info.PromptTokens,
-			CandidatesTokenCount: 0, // 流式响应中可能没有完整的 usage 信息
-			TotalTokenCount:      info.PromptTokens,
-		},
+		UsageMetadata: usageMetadata,
 	}
 
 	for _, choice := range openAIResponse.Choices {
@@ -753,19 +868,7 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 
 		// 设置结束原因
 		if choice.FinishReason != nil {
-			var finishReason string
-			switch *choice.FinishReason {
-			case "stop":
-				finishReason = "STOP"
-			case "length":
-				finishReason = "MAX_TOKENS"
-			case "content_filter":
-				finishReason = "SAFETY"
-			case "tool_calls":
-				finishReason = "STOP"
-			default:
-				finishReason = "STOP"
-			}
+			finishReason := finishReasonOpenAI2Gemini(*choice.FinishReason)
 			candidate.FinishReason = &finishReason
 		}
 
@@ -775,35 +878,20 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 			Parts: make([]dto.GeminiPart, 0),
 		}
 
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: reasoning, Thought: true})
+		}
+		if textContent := choice.Delta.GetContentString(); textContent != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: textContent})
+		}
 		// 处理工具调用
-		if choice.Delta.ToolCalls != nil {
+		if convertInfo == nil {
 			for _, toolCall := range choice.Delta.ToolCalls {
-				// 解析参数
-				var args map[string]interface{}
-				if toolCall.Function.Arguments != "" {
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-						args = map[string]interface{}{"arguments": toolCall.Function.Arguments}
-					}
-				} else {
-					args = make(map[string]interface{})
-				}
-
-				part := dto.GeminiPart{
-					FunctionCall: &dto.FunctionCall{
-						FunctionName: toolCall.Function.Name,
-						Arguments:    args,
-					},
-				}
-				content.Parts = append(content.Parts, part)
+				content.Parts = append(content.Parts, toolCallToGeminiPart(toolCall.Function.Name, toolCall.Function.Arguments))
 			}
-		} else {
-			// 处理文本内容
-			textContent := choice.Delta.GetContentString()
-			if textContent != "" {
-				part := dto.GeminiPart{
-					Text: textContent,
-				}
-				content.Parts = append(content.Parts, part)
+		} else if choice.FinishReason != nil {
+			for _, toolCall := range convertInfo.FlushToolCalls() {
+				content.Parts = append(content.Parts, toolCallToGeminiPart(toolCall.Function.Name, toolCall.Function.Arguments))
 			}
 		}
 
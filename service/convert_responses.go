@@ -0,0 +1,657 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"strings"
+)
+
+// Responses API 与 Chat Completions 之间的转换，用于让只支持 Chat Completions 的渠道提供 /v1/responses
+
+type responsesInputItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallId    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+}
+
+type responsesInputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageUrl string `json:"image_url"`
+	Detail   string `json:"detail"`
+	FileId   string `json:"file_id"`
+	FileData string `json:"file_data"`
+	FileUrl  string `json:"file_url"`
+	Filename string `json:"filename"`
+}
+
+type responsesTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parameters  any    `json:"parameters"`
+}
+
+type responsesText struct {
+	Format *struct {
+		Type        string          `json:"type"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Schema      any             `json:"schema"`
+		Strict      json.RawMessage `json:"strict"`
+	} `json:"format"`
+	Verbosity json.RawMessage `json:"verbosity"`
+}
+
+func isJSONString(data json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(data))
+	return strings.HasPrefix(trimmed, "\"")
+}
+
+// ResponsesToOpenAIRequest 将 Responses 请求转换为 Chat Completions 请求。
+// 内置工具（web_search、file_search 等）和 previous_response_id 依赖上游的状态，无法转换
+func ResponsesToOpenAIRequest(request *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	if request.PreviousResponseID != "" {
+		return nil, errors.New("previous_response_id is not supported on this channel, send the full conversation in input instead")
+	}
+	openAIRequest := &dto.GeneralOpenAIRequest{
+		Model:       request.Model,
+		Stream:      request.Stream,
+		MaxTokens:   request.MaxOutputTokens,
+		TopP:        request.TopP,
+		Temperature: request.Temperature,
+		User:        request.User,
+	}
+	if request.Reasoning != nil {
+		openAIRequest.ReasoningEffort = request.Reasoning.Effort
+	}
+	if len(request.ParallelToolCalls) > 0 {
+		var parallel bool
+		if err := common.Unmarshal(request.ParallelToolCalls, &parallel); err == nil {
+			openAIRequest.ParallelTooCalls = &parallel
+		}
+	}
+
+	if len(request.Instructions) > 0 {
+		var instructions string
+		if err := common.Unmarshal(request.Instructions, &instructions); err != nil {
+			return nil, fmt.Errorf("invalid instructions: %w", err)
+		}
+		if instructions != "" {
+			message := dto.Message{Role: "system"}
+			message.SetStringContent(instructions)
+			openAIRequest.Messages = append(openAIRequest.Messages, message)
+		}
+	}
+	messages, err := responsesInputToMessages(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	openAIRequest.Messages = append(openAIRequest.Messages, messages...)
+
+	if len(request.Tools) > 0 {
+		var tools []responsesTool
+		if err := common.Unmarshal(request.Tools, &tools); err != nil {
+			return nil, fmt.Errorf("invalid tools: %w", err)
+		}
+		for _, tool := range tools {
+			if tool.Type != "function" {
+				return nil, fmt.Errorf("built-in tool %s is not supported on this channel", tool.Type)
+			}
+			openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCallRequest{
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.Parameters,
+				},
+			})
+		}
+	}
+	if len(request.ToolChoice) > 0 {
+		if isJSONString(request.ToolChoice) {
+			var choice string
+			_ = common.Unmarshal(request.ToolChoice, &choice)
+			openAIRequest.ToolChoice = choice
+		} else {
+			var choice responsesTool
+			if err := common.Unmarshal(request.ToolChoice, &choice); err != nil {
+				return nil, fmt.Errorf("invalid tool_choice: %w", err)
+			}
+			if choice.Type != "function" {
+				return nil, fmt.Errorf("tool_choice %s is not supported on this channel", choice.Type)
+			}
+			openAIRequest.ToolChoice = map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": choice.Name},
+			}
+		}
+	}
+
+	if len(request.Text) > 0 {
+		var text responsesText
+		if err := common.Unmarshal(request.Text, &text); err != nil {
+			return nil, fmt.Errorf("invalid text: %w", err)
+		}
+		openAIRequest.Verbosity = text.Verbosity
+		if text.Format != nil {
+			switch text.Format.Type {
+			case "json_object":
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+			case "json_schema":
+				schema, err := common.Marshal(dto.FormatJsonSchema{
+					Name:        text.Format.Name,
+					Description: text.Format.Description,
+					Schema:      text.Format.Schema,
+					Strict:      text.Format.Strict,
+				})
+				if err != nil {
+					return nil, err
+				}
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: schema}
+			}
+		}
+	}
+	return openAIRequest, nil
+}
+
+// responsesInputToMessages input 可以是字符串或由消息、function_call、function_call_output 组成的数组，
+// 连续的 function_call 会合并到同一条 assistant 消息中
+func responsesInputToMessages(input json.RawMessage) ([]dto.Message, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	if isJSONString(input) {
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		message := dto.Message{Role: "user"}
+		message.SetStringContent(text)
+		return []dto.Message{message}, nil
+	}
+	var items []responsesInputItem
+	if err := common.Unmarshal(input, &items); err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	var messages []dto.Message
+	var pendingCalls []dto.ToolCallRequest
+	flushCalls := func() {
+		if len(pendingCalls) == 0 {
+			return
+		}
+		// 上一条 assistant 消息只有文本时，把工具调用附加在该消息上
+		if n := len(messages); n > 0 && messages[n-1].Role == "assistant" && len(messages[n-1].ToolCalls) == 0 {
+			messages[n-1].SetToolCalls(pendingCalls)
+		} else {
+			message := dto.Message{Role: "assistant", Content: ""}
+			message.SetToolCalls(pendingCalls)
+			messages = append(messages, message)
+		}
+		pendingCalls = nil
+	}
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			flushCalls()
+			message, err := responsesMessageToOpenAI(item)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		case "function_call":
+			pendingCalls = append(pendingCalls, dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+		case "function_call_output":
+			flushCalls()
+			message := dto.Message{Role: "tool", ToolCallId: item.CallId}
+			message.SetStringContent(responsesOutputText(item.Output))
+			messages = append(messages, message)
+		case "reasoning":
+			// 推理内容无法回传给 Chat Completions 上游
+			continue
+		default:
+			return nil, fmt.Errorf("input item type %s is not supported on this channel", item.Type)
+		}
+	}
+	flushCalls()
+	return messages, nil
+}
+
+func responsesMessageToOpenAI(item responsesInputItem) (dto.Message, error) {
+	role := item.Role
+	if role == "developer" {
+		role = "system"
+	}
+	message := dto.Message{Role: role}
+	if len(item.Content) == 0 || isJSONString(item.Content) {
+		var text string
+		if len(item.Content) > 0 {
+			if err := common.Unmarshal(item.Content, &text); err != nil {
+				return message, fmt.Errorf("invalid message content: %w", err)
+			}
+		}
+		message.SetStringContent(text)
+		return message, nil
+	}
+	var parts []responsesInputContent
+	if err := common.Unmarshal(item.Content, &parts); err != nil {
+		return message, fmt.Errorf("invalid message content: %w", err)
+	}
+	contents := make([]dto.MediaContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: part.Text})
+		case "refusal":
+			continue
+		case "input_image":
+			if part.ImageUrl == "" {
+				return message, errors.New("input_image with file_id is not supported on this channel")
+			}
+			contents = append(contents, dto.MediaContent{
+				Type:     dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{Url: part.ImageUrl, Detail: part.Detail},
+			})
+		case "input_file":
+			if part.FileUrl != "" {
+				return message, errors.New("input_file with file_url is not supported on this channel")
+			}
+			contents = append(contents, dto.MediaContent{
+				Type: dto.ContentTypeFile,
+				File: &dto.MessageFile{FileName: part.Filename, FileData: part.FileData, FileId: part.FileId},
+			})
+		default:
+			return message, fmt.Errorf("content type %s is not supported on this channel", part.Type)
+		}
+	}
+	// assistant 历史消息只含文本时使用字符串，兼容不支持数组内容的上游
+	if role == "assistant" {
+		var text strings.Builder
+		for _, content := range contents {
+			text.WriteString(content.Text)
+		}
+		message.SetStringContent(text.String())
+		return message, nil
+	}
+	message.SetMediaContent(contents)
+	return message, nil
+}
+
+// responsesOutputText function_call_output 的 output 可以是字符串或内容数组
+func responsesOutputText(output json.RawMessage) string {
+	if len(output) == 0 {
+		return ""
+	}
+	if isJSONString(output) {
+		var text string
+		_ = common.Unmarshal(output, &text)
+		return text
+	}
+	var parts []responsesInputContent
+	if err := common.Unmarshal(output, &parts); err != nil {
+		return string(output)
+	}
+	var text strings.Builder
+	for _, part := range parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+func responsesUsage(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	converted := *usage
+	converted.InputTokens = usage.PromptTokens
+	converted.OutputTokens = usage.CompletionTokens
+	converted.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	converted.InputTokensDetails = &dto.InputTokenDetails{CachedTokens: usage.PromptTokensDetails.CachedTokens}
+	return &converted
+}
+
+func responsesStatus(finishReason string) (string, *dto.IncompleteDetails) {
+	switch finishReason {
+	case "length":
+		return "incomplete", &dto.IncompleteDetails{Reasoning: "max_output_tokens"}
+	case "content_filter":
+		return "incomplete", &dto.IncompleteDetails{Reasoning: "content_filter"}
+	}
+	return "completed", nil
+}
+
+func newResponsesResponse(request *dto.OpenAIResponsesRequest, id string, createdAt int, model string) *dto.OpenAIResponsesResponse {
+	response := &dto.OpenAIResponsesResponse{
+		ID:                id,
+		Object:            "response",
+		CreatedAt:         createdAt,
+		Status:            "in_progress",
+		Model:             model,
+		Output:            []dto.ResponsesOutput{},
+		ParallelToolCalls: true,
+		Reasoning:         request.Reasoning,
+		ToolChoice:        "auto",
+		Tools:             []map[string]any{},
+		TopP:              request.TopP,
+		Truncation:        "disabled",
+		MaxOutputTokens:   int(request.MaxOutputTokens),
+		Metadata:          request.Metadata,
+	}
+	if request.Temperature != nil {
+		response.Temperature = *request.Temperature
+	}
+	if len(request.Instructions) > 0 {
+		_ = common.Unmarshal(request.Instructions, &response.Instructions)
+	}
+	if len(request.Tools) > 0 {
+		_ = common.Unmarshal(request.Tools, &response.Tools)
+	}
+	return response
+}
+
+func newResponsesItemID(prefix string) string {
+	return prefix + "_" + common.GetUUID()
+}
+
+// ResponseOpenAI2Responses 将 Chat Completions 非流式响应转换为 Responses 响应，usage 使用渠道处理后得到的用量
+func ResponseOpenAI2Responses(openAIResponse *dto.OpenAITextResponse, request *dto.OpenAIResponsesRequest, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	response := newResponsesResponse(request, newResponsesItemID("resp"), int(common.GetTimestamp()), openAIResponse.Model)
+	finishReason := ""
+	if len(openAIResponse.Choices) > 0 {
+		choice := openAIResponse.Choices[0]
+		finishReason = choice.FinishReason
+		reasoning := choice.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Reasoning
+		}
+		if reasoning != "" {
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:    "reasoning",
+				ID:      newResponsesItemID("rs"),
+				Summary: []dto.ResponsesOutputContent{{Type: "summary_text", Text: reasoning}},
+			})
+		}
+		if text := choice.StringContent(); text != "" {
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:    "message",
+				ID:      newResponsesItemID("msg"),
+				Status:  "completed",
+				Role:    "assistant",
+				Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}},
+			})
+		}
+		for _, toolCall := range choice.ParseToolCalls() {
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:      "function_call",
+				ID:        newResponsesItemID("fc"),
+				Status:    "completed",
+				CallId:    toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+	}
+	response.Status, response.IncompleteDetails = responsesStatus(finishReason)
+	response.Usage = responsesUsage(usage)
+	return response
+}
+
+type responsesStreamToolCall struct {
+	itemID      string
+	outputIndex int
+	callId      string
+	name        string
+	arguments   strings.Builder
+}
+
+// ResponsesStreamConverter 将 Chat Completions 流式分块转换为 Responses 流式事件，
+// 依次产生 reasoning、message 与 function_call 输出项，response.completed 在 Finish 中结合最终用量生成
+type ResponsesStreamConverter struct {
+	request  *dto.OpenAIResponsesRequest
+	response *dto.OpenAIResponsesResponse
+	sequence int
+
+	reasoningIndex int
+	reasoning      strings.Builder
+	messageIndex   int
+	text           strings.Builder
+	toolCalls      map[int]*responsesStreamToolCall
+	toolCallOrder  []int
+	finishReason   string
+}
+
+func NewResponsesStreamConverter(request *dto.OpenAIResponsesRequest) *ResponsesStreamConverter {
+	return &ResponsesStreamConverter{
+		request:        request,
+		reasoningIndex: -1,
+		messageIndex:   -1,
+		toolCalls:      make(map[int]*responsesStreamToolCall),
+	}
+}
+
+func (s *ResponsesStreamConverter) event(eventType string) dto.ResponsesStreamResponse {
+	event := dto.ResponsesStreamResponse{Type: eventType, SequenceNumber: s.sequence}
+	s.sequence++
+	return event
+}
+
+func (s *ResponsesStreamConverter) itemEvent(eventType string, outputIndex int, itemID string) dto.ResponsesStreamResponse {
+	event := s.event(eventType)
+	event.OutputIndex = common.GetPointer(outputIndex)
+	event.ItemID = itemID
+	return event
+}
+
+func (s *ResponsesStreamConverter) addItem(item dto.ResponsesOutput) (int, dto.ResponsesStreamResponse) {
+	index := len(s.response.Output)
+	s.response.Output = append(s.response.Output, item)
+	event := s.event(dto.ResponsesOutputTypeItemAdded)
+	event.OutputIndex = common.GetPointer(index)
+	event.Item = &item
+	return index, event
+}
+
+func (s *ResponsesStreamConverter) doneItem(index int, item dto.ResponsesOutput) dto.ResponsesStreamResponse {
+	s.response.Output[index] = item
+	event := s.event(dto.ResponsesOutputTypeItemDone)
+	event.OutputIndex = common.GetPointer(index)
+	event.Item = &item
+	return event
+}
+
+func (s *ResponsesStreamConverter) start(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	model := chunk.Model
+	if model == "" {
+		model = s.request.Model
+	}
+	s.response = newResponsesResponse(s.request, newResponsesItemID("resp"), int(common.GetTimestamp()), model)
+	created := s.event("response.created")
+	created.Response = s.snapshot()
+	inProgress := s.event("response.in_progress")
+	inProgress.Response = s.snapshot()
+	return []dto.ResponsesStreamResponse{created, inProgress}
+}
+
+func (s *ResponsesStreamConverter) snapshot() *dto.OpenAIResponsesResponse {
+	response := *s.response
+	response.Output = append([]dto.ResponsesOutput{}, s.response.Output...)
+	return &response
+}
+
+func (s *ResponsesStreamConverter) closeReasoning() []dto.ResponsesStreamResponse {
+	if s.reasoningIndex < 0 {
+		return nil
+	}
+	index := s.reasoningIndex
+	s.reasoningIndex = -1
+	item := s.response.Output[index]
+	text := s.reasoning.String()
+	part := dto.ResponsesOutputContent{Type: "summary_text", Text: text}
+	item.Summary = []dto.ResponsesOutputContent{part}
+
+	textDone := s.itemEvent("response.reasoning_summary_text.done", index, item.ID)
+	textDone.SummaryIndex = common.GetPointer(0)
+	textDone.Text = common.GetPointer(text)
+	partDone := s.itemEvent("response.reasoning_summary_part.done", index, item.ID)
+	partDone.SummaryIndex = common.GetPointer(0)
+	partDone.Part = &part
+	return []dto.ResponsesStreamResponse{textDone, partDone, s.doneItem(index, item)}
+}
+
+func (s *ResponsesStreamConverter) closeMessage() []dto.ResponsesStreamResponse {
+	if s.messageIndex < 0 {
+		return nil
+	}
+	index := s.messageIndex
+	s.messageIndex = -1
+	item := s.response.Output[index]
+	text := s.text.String()
+	part := dto.ResponsesOutputContent{Type: "output_text", Text: text, Annotations: []interface{}{}}
+	item.Status = "completed"
+	item.Content = []dto.ResponsesOutputContent{part}
+
+	textDone := s.itemEvent("response.output_text.done", index, item.ID)
+	textDone.ContentIndex = common.GetPointer(0)
+	textDone.Text = common.GetPointer(text)
+	partDone := s.itemEvent("response.content_part.done", index, item.ID)
+	partDone.ContentIndex = common.GetPointer(0)
+	partDone.Part = &part
+	return []dto.ResponsesStreamResponse{textDone, partDone, s.doneItem(index, item)}
+}
+
+func (s *ResponsesStreamConverter) closeToolCalls() []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	for _, key := range s.toolCallOrder {
+		call := s.toolCalls[key]
+		arguments := call.arguments.String()
+		argumentsDone := s.itemEvent("response.function_call_arguments.done", call.outputIndex, call.itemID)
+		argumentsDone.Arguments = common.GetPointer(arguments)
+		events = append(events, argumentsDone, s.doneItem(call.outputIndex, dto.ResponsesOutput{
+			Type:      "function_call",
+			ID:        call.itemID,
+			Status:    "completed",
+			CallId:    call.callId,
+			Name:      call.name,
+			Arguments: arguments,
+		}))
+	}
+	s.toolCalls = make(map[int]*responsesStreamToolCall)
+	s.toolCallOrder = nil
+	return events
+}
+
+// Convert 转换一个流式分块，只处理第一个 choice
+func (s *ResponsesStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.response == nil {
+		events = append(events, s.start(chunk)...)
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			if s.reasoningIndex < 0 {
+				var added dto.ResponsesStreamResponse
+				item := dto.ResponsesOutput{Type: "reasoning", ID: newResponsesItemID("rs"), Summary: []dto.ResponsesOutputContent{}}
+				s.reasoningIndex, added = s.addItem(item)
+				s.reasoning.Reset()
+				partAdded := s.itemEvent("response.reasoning_summary_part.added", s.reasoningIndex, item.ID)
+				partAdded.SummaryIndex = common.GetPointer(0)
+				partAdded.Part = &dto.ResponsesOutputContent{Type: "summary_text"}
+				events = append(events, added, partAdded)
+			}
+			s.reasoning.WriteString(reasoning)
+			delta := s.itemEvent("response.reasoning_summary_text.delta", s.reasoningIndex, s.response.Output[s.reasoningIndex].ID)
+			delta.SummaryIndex = common.GetPointer(0)
+			delta.Delta = reasoning
+			events = append(events, delta)
+		}
+		if text := choice.Delta.GetContentString(); text != "" {
+			events = append(events, s.closeReasoning()...)
+			if s.messageIndex < 0 {
+				var added dto.ResponsesStreamResponse
+				item := dto.ResponsesOutput{Type: "message", ID: newResponsesItemID("msg"), Status: "in_progress", Role: "assistant", Content: []dto.ResponsesOutputContent{}}
+				s.messageIndex, added = s.addItem(item)
+				s.text.Reset()
+				partAdded := s.itemEvent("response.content_part.added", s.messageIndex, item.ID)
+				partAdded.ContentIndex = common.GetPointer(0)
+				partAdded.Part = &dto.ResponsesOutputContent{Type: "output_text", Annotations: []interface{}{}}
+				events = append(events, added, partAdded)
+			}
+			s.text.WriteString(text)
+			delta := s.itemEvent("response.output_text.delta", s.messageIndex, s.response.Output[s.messageIndex].ID)
+			delta.ContentIndex = common.GetPointer(0)
+			delta.Delta = text
+			events = append(events, delta)
+		}
+		for i, toolCall := range choice.Delta.ToolCalls {
+			events = append(events, s.closeReasoning()...)
+			events = append(events, s.closeMessage()...)
+			key := i
+			if toolCall.Index != nil {
+				key = *toolCall.Index
+			}
+			call, ok := s.toolCalls[key]
+			if !ok {
+				call = &responsesStreamToolCall{itemID: newResponsesItemID("fc"), callId: toolCall.ID, name: toolCall.Function.Name}
+				var added dto.ResponsesStreamResponse
+				call.outputIndex, added = s.addItem(dto.ResponsesOutput{
+					Type:   "function_call",
+					ID:     call.itemID,
+					Status: "in_progress",
+					CallId: call.callId,
+					Name:   call.name,
+				})
+				s.toolCalls[key] = call
+				s.toolCallOrder = append(s.toolCallOrder, key)
+				events = append(events, added)
+			}
+			if toolCall.Function.Arguments != "" {
+				call.arguments.WriteString(toolCall.Function.Arguments)
+				delta := s.itemEvent("response.function_call_arguments.delta", call.outputIndex, call.itemID)
+				delta.Delta = toolCall.Function.Arguments
+				events = append(events, delta)
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish 关闭所有未结束的输出项并生成 response.completed（或 response.incomplete）事件
+func (s *ResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.response == nil {
+		events = append(events, s.start(&dto.ChatCompletionsStreamResponse{})...)
+	}
+	events = append(events, s.closeReasoning()...)
+	events = append(events, s.closeMessage()...)
+	events = append(events, s.closeToolCalls()...)
+	s.response.Status, s.response.IncompleteDetails = responsesStatus(s.finishReason)
+	s.response.Usage = responsesUsage(usage)
+	eventType := "response.completed"
+	if s.response.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	completed := s.event(eventType)
+	completed.Response = s.snapshot()
+	return append(events, completed)
+}
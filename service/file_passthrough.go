@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"strings"
+)
+
+// 文件透传与微调任务仅支持 OpenAI 兼容的渠道
+func ChannelSupportsFiles(channel *model.Channel) bool {
+	switch channel.Type {
+	case constant.ChannelTypeOpenAI, constant.ChannelTypeCustom:
+		return true
+	default:
+		return false
+	}
+}
+
+func channelOpenAIURL(channel *model.Channel, path string) string {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+func channelKeyByIndex(channel *model.Channel, index int) string {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key
+	}
+	keys := channel.GetKeys()
+	if index < 0 || index >= len(keys) {
+		return channel.Key
+	}
+	return keys[index]
+}
+
+func doChannelOpenAIRequest(channel *model.Channel, keyIndex int, method string, url string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+channelKeyByIndex(channel, keyIndex))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+	}
+	client := GetHttpClient()
+	if proxy := channel.GetSetting().Proxy; proxy != "" {
+		client, err = NewProxyHttpClient(proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("upstream status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
+
+func decodeChannelOpenAIResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return common.Unmarshal(body, v)
+}
+
+// UploadFileToChannel 将文件上传到渠道，返回上游文件对象
+func UploadFileToChannel(channel *model.Channel, keyIndex int, filename string, purpose string, content io.Reader) (*dto.OpenAIFile, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("purpose", purpose); err != nil {
+		return nil, err
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(part, content); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodPost, channelOpenAIURL(channel, "/v1/files"), &buf, writer.FormDataContentType())
+	if err != nil {
+		return nil, err
+	}
+	var file dto.OpenAIFile
+	if err = decodeChannelOpenAIResponse(resp, &file); err != nil {
+		return nil, err
+	}
+	if file.Id == "" {
+		return nil, fmt.Errorf("upstream file api returned empty file id")
+	}
+	return &file, nil
+}
+
+func RetrieveChannelFile(channel *model.Channel, keyIndex int, upstreamFileId string) (*dto.OpenAIFile, error) {
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodGet, channelOpenAIURL(channel, "/v1/files/"+upstreamFileId), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var file dto.OpenAIFile
+	if err = decodeChannelOpenAIResponse(resp, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// OpenChannelFileContent 调用方负责关闭返回的 Body
+func OpenChannelFileContent(channel *model.Channel, keyIndex int, upstreamFileId string) (*http.Response, error) {
+	return doChannelOpenAIRequest(channel, keyIndex, http.MethodGet, channelOpenAIURL(channel, "/v1/files/"+upstreamFileId+"/content"), nil, "")
+}
+
+func DeleteChannelFile(channel *model.Channel, keyIndex int, upstreamFileId string) error {
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodDelete, channelOpenAIURL(channel, "/v1/files/"+upstreamFileId), nil, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"one-api/setting/system_setting"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStorage 文件内容的存储后端，key 由调用方生成且只包含安全字符
+type FileStorage interface {
+	Save(key string, reader io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+type FileStorageFactory func(setting *system_setting.FileSetting) (FileStorage, error)
+
+var (
+	fileStorageLock      sync.RWMutex
+	fileStorageFactories = map[string]FileStorageFactory{
+		"local": newLocalFileStorage,
+	}
+)
+
+// RegisterFileStorage 注册自定义的存储后端，storage_type 配置为 name 时启用
+func RegisterFileStorage(name string, factory FileStorageFactory) {
+	fileStorageLock.Lock()
+	defer fileStorageLock.Unlock()
+	fileStorageFactories[name] = factory
+}
+
+func GetFileStorage() (FileStorage, error) {
+	setting := system_setting.GetFileSetting()
+	storageType := setting.StorageType
+	if storageType == "" {
+		storageType = "local"
+	}
+	fileStorageLock.RLock()
+	factory, ok := fileStorageFactories[storageType]
+	fileStorageLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported file storage type: %s", storageType)
+	}
+	return factory(setting)
+}
+
+type localFileStorage struct {
+	root string
+}
+
+func newLocalFileStorage(setting *system_setting.FileSetting) (FileStorage, error) {
+	root := setting.LocalPath
+	if root == "" {
+		root = "./data/files"
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &localFileStorage{root: root}, nil
+}
+
+func (s *localFileStorage) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.ContainsAny(key, `/\`) {
+		return "", errors.New("invalid file storage key")
+	}
+	return filepath.Join(s.root, key), nil
+}
+
+func (s *localFileStorage) Save(key string, reader io.Reader) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, reader)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(p)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *localFileStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *localFileStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
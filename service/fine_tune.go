@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+)
+
+const fineTuningJobsPath = "/v1/fine_tuning/jobs"
+
+func decodeFineTuningJob(resp *http.Response) (*dto.FineTuningJob, error) {
+	var job dto.FineTuningJob
+	if err := decodeChannelOpenAIResponse(resp, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CreateChannelFineTuningJob 请求体中的文件 id 需已替换为上游文件 id
+func CreateChannelFineTuningJob(channel *model.Channel, keyIndex int, request map[string]any) (*dto.FineTuningJob, error) {
+	body, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodPost, channelOpenAIURL(channel, fineTuningJobsPath), bytes.NewReader(body), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	return decodeFineTuningJob(resp)
+}
+
+func RetrieveChannelFineTuningJob(channel *model.Channel, keyIndex int, jobId string) (*dto.FineTuningJob, error) {
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodGet, channelOpenAIURL(channel, fineTuningJobsPath+"/"+jobId), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return decodeFineTuningJob(resp)
+}
+
+func CancelChannelFineTuningJob(channel *model.Channel, keyIndex int, jobId string) (*dto.FineTuningJob, error) {
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodPost, channelOpenAIURL(channel, fineTuningJobsPath+"/"+jobId+"/cancel"), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return decodeFineTuningJob(resp)
+}
+
+// ListChannelFineTuningEvents 事件内容不含文件 id，原样返回上游响应
+func ListChannelFineTuningEvents(channel *model.Channel, keyIndex int, jobId string, rawQuery string) ([]byte, error) {
+	url := channelOpenAIURL(channel, fineTuningJobsPath+"/"+jobId+"/events")
+	if rawQuery != "" {
+		url += "?" + rawQuery
+	}
+	resp, err := doChannelOpenAIRequest(channel, keyIndex, http.MethodGet, url, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
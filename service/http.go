@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/logger"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +27,12 @@ func IOCopyBytesGracefully(c *gin.Context, src *http.Response, data []byte) {
 		return
 	}
 
+	// 非流式响应在返回客户端前还原敏感信息占位符并检测模型输出
+	if src == nil || (src.StatusCode == http.StatusOK && strings.Contains(src.Header.Get("Content-Type"), "json")) {
+		data = RestorePIIBody(c, data)
+		data = ModerateCompletionBody(c, data)
+	}
+
 	body := io.NopCloser(bytes.NewBuffer(data))
 
 	// We shouldn't set the header before we parse the response body, because the parse part may fail.
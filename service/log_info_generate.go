@@ -5,6 +5,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -34,6 +35,22 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_system_prompt_overwritten"] = true
 	}
 
+	if hedgeInfo := relayInfo.HedgeLogInfo(); hedgeInfo != nil {
+		other["hedge"] = hedgeInfo
+	}
+
+	if common.GetContextKeyBool(ctx, constant.ContextKeyResponseCacheHit) {
+		other["response_cache_hit"] = true
+		other["response_cache_hit_ratio"] = operation_setting.GetResponseCacheSetting().HitRatio
+	}
+
+	if batchId := common.GetContextKeyString(ctx, constant.ContextKeyBatchId); batchId != "" {
+		other["batch_id"] = batchId
+		if discount, ok := common.GetContextKeyType[float64](ctx, constant.ContextKeyBatchDiscountRatio); ok {
+			other["batch_discount_ratio"] = discount
+		}
+	}
+
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
 	isMultiKey := common.GetContextKeyBool(ctx, constant.ContextKeyChannelIsMultiKey)
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModerationResult 单个检测方式的结果
+type ModerationResult struct {
+	Flagged    bool     `json:"flagged"`
+	Matches    []string `json:"matches,omitempty"`    // 命中的敏感词或规则
+	Categories []string `json:"categories,omitempty"` // 审核模型标记的类别
+}
+
+// ModerationProvider 内容审核的检测方式
+type ModerationProvider interface {
+	Name() string
+	Check(c *gin.Context, text string) (*ModerationResult, error)
+	// Redact 返回替换命中内容后的文本，不支持替换时返回 false
+	Redact(text string) (string, bool)
+}
+
+// ModerationVerdict 记录在日志 other.moderation 中的审核结果
+type ModerationVerdict struct {
+	Provider   string   `json:"provider"`
+	Policy     string   `json:"policy"`
+	Action     string   `json:"action"` // block / flag / redact
+	Matches    []string `json:"matches,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+type wordListModerationProvider struct{}
+
+func (p wordListModerationProvider) Name() string {
+	return operation_setting.ModerationProviderWordList
+}
+
+func (p wordListModerationProvider) Check(c *gin.Context, text string) (*ModerationResult, error) {
+	contains, words := SensitiveWordContains(text)
+	return &ModerationResult{Flagged: contains, Matches: words}, nil
+}
+
+func (p wordListModerationProvider) Redact(text string) (string, bool) {
+	_, _, replaced := SensitiveWordReplace(text, false)
+	return replaced, true
+}
+
+type regexModerationProvider struct{}
+
+func (p regexModerationProvider) Name() string {
+	return operation_setting.ModerationProviderRegex
+}
+
+func (p regexModerationProvider) Check(c *gin.Context, text string) (*ModerationResult, error) {
+	result := &ModerationResult{}
+	for _, re := range operation_setting.GetModerationSetting().GetRegexRules() {
+		if re.MatchString(text) {
+			result.Flagged = true
+			result.Matches = append(result.Matches, re.String())
+		}
+	}
+	return result, nil
+}
+
+func (p regexModerationProvider) Redact(text string) (string, bool) {
+	for _, re := range operation_setting.GetModerationSetting().GetRegexRules() {
+		text = re.ReplaceAllString(text, sensitiveWordMask)
+	}
+	return text, true
+}
+
+// modelModerationProvider 通过渠道调用兼容 OpenAI /v1/moderations 的审核模型
+type modelModerationProvider struct{}
+
+type moderationModelResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+func (p modelModerationProvider) Name() string {
+	return operation_setting.ModerationProviderModel
+}
+
+func (p modelModerationProvider) Check(c *gin.Context, text string) (*ModerationResult, error) {
+	s := operation_setting.GetModerationSetting()
+	if s.ModelName == "" {
+		return nil, errors.New("moderation model is not configured")
+	}
+	var channel *model.Channel
+	var err error
+	if s.ModelChannelId > 0 {
+		channel, err = model.CacheGetChannel(s.ModelChannelId)
+	} else {
+		// 使用副本选择渠道，避免自动分组写入用户请求的上下文
+		channel, _, err = model.CacheGetRandomSatisfiedChannel(c.Copy(), s.ModelGroup, s.ModelName, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("no available channel for moderation model %s", s.ModelName)
+	}
+	// 审核调用不计入渠道密钥的用量与轮询
+	key, newAPIError := channel.PickEnabledKey()
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	payload, err := common.Marshal(map[string]any{
+		"model": s.ModelName,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(s.ModelTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/v1/moderations", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	client := GetHttpClient()
+	if proxy := channel.GetSetting().Proxy; proxy != "" {
+		client, err = NewProxyHttpClient(proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation model returned status %d", resp.StatusCode)
+	}
+	var response moderationModelResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	result := &ModerationResult{}
+	for _, r := range response.Results {
+		if !r.Flagged {
+			continue
+		}
+		result.Flagged = true
+		for category, flagged := range r.Categories {
+			if flagged {
+				result.Categories = append(result.Categories, category)
+			}
+		}
+	}
+	result.Categories = RemoveDuplicate(result.Categories)
+	return result, nil
+}
+
+func (p modelModerationProvider) Redact(text string) (string, bool) {
+	return text, false
+}
+
+var moderationProviders = map[string]ModerationProvider{
+	operation_setting.ModerationProviderWordList: wordListModerationProvider{},
+	operation_setting.ModerationProviderRegex:    regexModerationProvider{},
+	operation_setting.ModerationProviderModel:    modelModerationProvider{},
+}
+
+// getModerationPipeline 返回本次请求使用的检测方式与处理方式。
+// 未开启内容审核时沿用原有的提示词敏感词检测
+func getModerationPipeline(c *gin.Context, info *relaycommon.RelayInfo) ([]ModerationProvider, string) {
+	s := operation_setting.GetModerationSetting()
+	if !s.Enabled {
+		if setting.ShouldCheckPromptSensitive() {
+			return []ModerationProvider{wordListModerationProvider{}}, operation_setting.ModerationPolicyBlock
+		}
+		return nil, operation_setting.ModerationPolicyNone
+	}
+	policy := s.GetModerationPolicy(info.UsingGroup, common.GetContextKeyString(c, constant.ContextKeyTokenModerationPolicy))
+	if policy == operation_setting.ModerationPolicyNone {
+		return nil, policy
+	}
+	providers := make([]ModerationProvider, 0, len(s.Providers))
+	for _, name := range s.Providers {
+		if provider, ok := moderationProviders[name]; ok {
+			providers = append(providers, provider)
+		}
+	}
+	return providers, policy
+}
+
+// ModerateRequest 在转发前审核请求内容，按处理方式拦截、记录或替换命中的内容。
+// 替换了请求内容时返回 true，调用方需要重新计算 token；检测出错时放行并记录日志
+func ModerateRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request, text string) (bool, *types.NewAPIError) {
+	if text == "" {
+		return false, nil
+	}
+	providers, policy := getModerationPipeline(c, info)
+	verdicts := make([]ModerationVerdict, 0)
+	redacted := false
+	for _, provider := range providers {
+		result, err := provider.Check(c, text)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("moderation provider %s failed: %s", provider.Name(), err.Error()))
+			continue
+		}
+		if !result.Flagged {
+			continue
+		}
+		verdict := ModerationVerdict{
+			Provider:   provider.Name(),
+			Policy:     policy,
+			Action:     policy,
+			Matches:    result.Matches,
+			Categories: result.Categories,
+		}
+		logger.LogWarn(c, fmt.Sprintf("request flagged by moderation provider %s: %s", provider.Name(), strings.Join(slices.Concat(result.Matches, result.Categories), ", ")))
+		if policy == operation_setting.ModerationPolicyRedact {
+			if err := redactRequest(c, request, provider); err != nil {
+				logger.LogWarn(c, fmt.Sprintf("failed to redact request: %s", err.Error()))
+				verdict.Action = operation_setting.ModerationPolicyBlock
+			} else {
+				redacted = true
+				// 后续的检测方式检查替换后的内容
+				text, _ = provider.Redact(text)
+			}
+		}
+		verdicts = append(verdicts, verdict)
+		if verdict.Action == operation_setting.ModerationPolicyBlock {
+			common.SetContextKey(c, constant.ContextKeyModerationVerdicts, verdicts)
+			newAPIError := types.NewErrorWithStatusCode(fmt.Errorf("请求内容未通过审核（%s）", provider.Name()), types.ErrorCodeSensitiveWordsDetected, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+			recordModerationBlockLog(c, info, newAPIError)
+			return redacted, newAPIError
+		}
+	}
+	if len(verdicts) > 0 {
+		common.SetContextKey(c, constant.ContextKeyModerationVerdicts, verdicts)
+	}
+	return redacted, nil
+}
+
+func recordModerationBlockLog(c *gin.Context, info *relaycommon.RelayInfo, err *types.NewAPIError) {
+	if !constant.ErrorLogEnabled {
+		return
+	}
+	other := map[string]interface{}{
+		"error_type":  err.GetErrorType(),
+		"error_code":  err.GetErrorCode(),
+		"status_code": err.StatusCode,
+	}
+	model.RecordErrorLog(c, info.UserId, 0, info.OriginModelName, c.GetString("token_name"), err.Error(), info.TokenId, 0, info.IsStream, info.UsingGroup, other)
+}
+
+// moderationSkipKeys 替换请求内容时跳过的结构字段
+var moderationSkipKeys = map[string]bool{
+	"model":        true,
+	"role":         true,
+	"type":         true,
+	"id":           true,
+	"tool_call_id": true,
+	"image_url":    true,
+	"url":          true,
+	"data":         true,
+}
+
+func redactJSONValue(v any, redact func(string) string) any {
+	switch value := v.(type) {
+	case string:
+		return redact(value)
+	case []any:
+		for i := range value {
+			value[i] = redactJSONValue(value[i], redact)
+		}
+	case map[string]any:
+		for key, item := range value {
+			if !moderationSkipKeys[key] {
+				value[key] = redactJSONValue(item, redact)
+			}
+		}
+	}
+	return v
+}
+
+// redactRequest 替换请求体中所有文本字段的命中内容，并重新解析到 request，只支持 JSON 请求
+func redactRequest(c *gin.Context, request dto.Request, provider ModerationProvider) error {
+	if _, ok := provider.Redact(""); !ok {
+		return fmt.Errorf("moderation provider %s does not support redaction", provider.Name())
+	}
+	if !strings.Contains(c.Request.Header.Get("Content-Type"), "json") {
+		return errors.New("only json request can be redacted")
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	value = redactJSONValue(value, func(text string) string {
+		replaced, _ := provider.Redact(text)
+		return replaced
+	})
+	body, err = common.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := common.Unmarshal(body, request); err != nil {
+		return err
+	}
+	c.Set(common.KeyRequestBody, body)
+	return nil
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const piiPlaceholderPrefix = "[PII_"
+
+// PIIScrubber 记录一次上游请求中敏感信息与占位符的对应关系，同一内容使用同一个占位符
+type PIIScrubber struct {
+	placeholders map[string]string // 原始内容 -> 占位符
+	originals    map[string]string // 占位符 -> 原始内容
+	counts       map[string]int
+	restorer     *strings.Replacer
+	// jsonRestorer 用于工具调用参数等 JSON 文本，原始内容按 JSON 字符串转义后写入
+	jsonRestorer *strings.Replacer
+	// 流式响应中被截断的占位符，按文本字段暂存到下一个分块
+	carry map[string]string
+	// carryChunk 最近一次暂存时的分块，流结束时以它为模板发送剩余内容
+	carryChunk []byte
+}
+
+func newPIIScrubber() *PIIScrubber {
+	return &PIIScrubber{
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[string]int),
+		carry:        make(map[string]string),
+	}
+}
+
+func (s *PIIScrubber) placeholder(piiType string, value string) string {
+	if placeholder, ok := s.placeholders[value]; ok {
+		return placeholder
+	}
+	s.counts[piiType]++
+	placeholder := fmt.Sprintf("%s%s_%d]", piiPlaceholderPrefix, strings.ToUpper(piiType), s.counts[piiType])
+	s.placeholders[value] = placeholder
+	s.originals[placeholder] = value
+	return placeholder
+}
+
+// luhnValid 校验银行卡号，减少把普通数字识别为银行卡号
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	digits := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		ch := number[i]
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		d := int(ch - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+func (s *PIIScrubber) scrub(text string, patterns []operation_setting.PIIPattern) string {
+	for _, pattern := range patterns {
+		text = pattern.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if pattern.Type == operation_setting.PIITypeCreditCard && !luhnValid(match) {
+				return match
+			}
+			return s.placeholder(pattern.Type, match)
+		})
+	}
+	return text
+}
+
+func (s *PIIScrubber) scrubJSONValue(v any, patterns []operation_setting.PIIPattern) any {
+	switch value := v.(type) {
+	case string:
+		return s.scrub(value, patterns)
+	case []any:
+		for i := range value {
+			value[i] = s.scrubJSONValue(value[i], patterns)
+		}
+	case map[string]any:
+		for key, item := range value {
+			// 与内容审核替换时跳过相同的结构字段
+			if !moderationSkipKeys[key] {
+				value[key] = s.scrubJSONValue(item, patterns)
+			}
+		}
+	}
+	return v
+}
+
+// ScrubRequestPII 在转换为上游请求前，将请求中的敏感信息替换为占位符。
+// 替换只对本次上游请求生效，返回的函数用于还原请求，重试其他渠道时按该渠道的设置重新判断
+func ScrubRequestPII(c *gin.Context, info *relaycommon.RelayInfo) (func(), *types.NewAPIError) {
+	channelSetting, _ := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting)
+	tokenEnabled := common.GetContextKeyBool(c, constant.ContextKeyTokenPIIScrub)
+	if !operation_setting.IsPIIScrubEnabled(info.UsingGroup, channelSetting.PIIScrubEnabled, tokenEnabled) {
+		return nil, nil
+	}
+	if info.Request == nil || !strings.Contains(c.Request.Header.Get("Content-Type"), "json") {
+		return nil, nil
+	}
+	patterns := operation_setting.GetPIISetting().GetPatterns()
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	scrubber := newPIIScrubber()
+	value = scrubber.scrubJSONValue(value, patterns)
+	if len(scrubber.originals) == 0 {
+		return nil, nil
+	}
+	scrubbedBody, err := common.Marshal(value)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+	}
+	request := reflect.New(reflect.TypeOf(info.Request).Elem()).Interface().(dto.Request)
+	if err := common.Unmarshal(scrubbedBody, request); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	logger.LogInfo(c, fmt.Sprintf("replaced %d pii values with placeholders", len(scrubber.originals)))
+
+	originalRequest := info.Request
+	info.Request = request
+	c.Set(common.KeyRequestBody, scrubbedBody)
+	c.Request.Body = io.NopCloser(bytes.NewReader(scrubbedBody))
+	if operation_setting.GetPIISetting().RestoreResponse {
+		scrubber.buildRestorers()
+		common.SetContextKey(c, constant.ContextKeyPIIScrubber, scrubber)
+	}
+	return func() {
+		info.Request = originalRequest
+		c.Set(common.KeyRequestBody, body)
+		common.SetContextKey(c, constant.ContextKeyPIIScrubber, (*PIIScrubber)(nil))
+	}, nil
+}
+
+// buildRestorers 按占位符与原始内容的对应关系生成还原响应用的 Replacer
+func (s *PIIScrubber) buildRestorers() {
+	pairs := make([]string, 0, len(s.originals)*2)
+	jsonPairs := make([]string, 0, len(s.originals)*2)
+	for placeholder, original := range s.originals {
+		pairs = append(pairs, placeholder, original)
+		quoted, _ := common.Marshal(original)
+		jsonPairs = append(jsonPairs, placeholder, string(quoted[1:len(quoted)-1]))
+	}
+	s.restorer = strings.NewReplacer(pairs...)
+	s.jsonRestorer = strings.NewReplacer(jsonPairs...)
+}
+
+// GetPIIScrubber 需要在响应中还原占位符时返回本次请求的 PIIScrubber
+func GetPIIScrubber(c *gin.Context) *PIIScrubber {
+	scrubber, _ := common.GetContextKeyType[*PIIScrubber](c, constant.ContextKeyPIIScrubber)
+	return scrubber
+}
+
+// piiRestorePaths 在输出文本之外加上工具调用参数的路径，模型可能把占位符写入工具调用参数。
+// jsonPaths 中的路径为 JSON 文本（OpenAI 的 arguments、Claude 的 partial_json），还原时需要转义；
+// 参数为对象时（Claude 的 tool_use.input、Gemini 的 functionCall.args）逐个加入其中的字符串值
+func piiRestorePaths(data []byte) (paths []string, jsonPaths map[string]bool) {
+	paths = completionTextPaths(data)
+	jsonPaths = make(map[string]bool)
+	addJSONText := func(path string) {
+		if gjson.GetBytes(data, path).Type == gjson.String {
+			paths = append(paths, path)
+			jsonPaths[path] = true
+		}
+	}
+	var addStringLeaves func(path string, value gjson.Result)
+	addStringLeaves = func(path string, value gjson.Result) {
+		switch {
+		case value.Type == gjson.String:
+			paths = append(paths, path)
+		case value.IsObject() || value.IsArray():
+			value.ForEach(func(key, child gjson.Result) bool {
+				addStringLeaves(path+"."+gjson.Escape(key.String()), child)
+				return true
+			})
+		}
+	}
+	root := gjson.ParseBytes(data)
+	root.Get("choices").ForEach(func(key, _ gjson.Result) bool {
+		for _, field := range []string{"delta", "message"} {
+			prefix := "choices." + key.String() + "." + field
+			root.Get(prefix + ".tool_calls").ForEach(func(index, _ gjson.Result) bool {
+				addJSONText(prefix + ".tool_calls." + index.String() + ".function.arguments")
+				return true
+			})
+			addJSONText(prefix + ".function_call.arguments")
+		}
+		return true
+	})
+	root.Get("candidates").ForEach(func(key, candidate gjson.Result) bool {
+		candidate.Get("content.parts").ForEach(func(index, part gjson.Result) bool {
+			if args := part.Get("functionCall.args"); args.Exists() {
+				addStringLeaves(fmt.Sprintf("candidates.%s.content.parts.%s.functionCall.args", key.String(), index.String()), args)
+			}
+			return true
+		})
+		return true
+	})
+	addToolUseInputs := func(prefix string) {
+		root.Get(prefix + "content").ForEach(func(key, block gjson.Result) bool {
+			if block.Get("type").String() == "tool_use" {
+				addStringLeaves(prefix+"content."+key.String()+".input", block.Get("input"))
+			}
+			return true
+		})
+	}
+	addFunctionCallArguments := func(prefix string, item gjson.Result) {
+		if item.Get("type").String() == "function_call" {
+			addJSONText(prefix + "arguments")
+		}
+	}
+	switch eventType := root.Get("type").String(); {
+	case eventType == "message":
+		addToolUseInputs("")
+	case eventType == "content_block_start":
+		addStringLeaves("content_block.input", root.Get("content_block.input"))
+	case eventType == "content_block_delta":
+		addJSONText("delta.partial_json")
+	case eventType == "response.function_call_arguments.delta":
+		addJSONText("delta")
+	case eventType == "response.function_call_arguments.done":
+		addJSONText("arguments")
+	case strings.HasPrefix(eventType, "response.output_item."):
+		addFunctionCallArguments("item.", root.Get("item"))
+	}
+	for _, prefix := range []string{"output", "response.output"} {
+		root.Get(prefix).ForEach(func(key, item gjson.Result) bool {
+			addFunctionCallArguments(prefix+"."+key.String()+".", item)
+			return true
+		})
+	}
+	return paths, jsonPaths
+}
+
+func (s *PIIScrubber) restore(text string, isJSON bool) string {
+	if isJSON {
+		return s.jsonRestorer.Replace(text)
+	}
+	return s.restorer.Replace(text)
+}
+
+// RestorePIIBody 在非流式响应中将占位符还原为原始内容
+func RestorePIIBody(c *gin.Context, data []byte) []byte {
+	scrubber := GetPIIScrubber(c)
+	if scrubber == nil {
+		return data
+	}
+	paths, jsonPaths := piiRestorePaths(data)
+	if len(paths) == 0 {
+		return data
+	}
+	texts := getTextValues(data, paths)
+	for i, path := range paths {
+		texts[i] = scrubber.restore(texts[i], jsonPaths[path])
+	}
+	return setTextValues(data, paths, texts)
+}
+
+// partialPlaceholderIndex 文本以不完整的占位符结尾时返回其起始位置，否则返回 -1
+func partialPlaceholderIndex(text string) int {
+	index := strings.LastIndex(text, "[")
+	if index < 0 {
+		return -1
+	}
+	suffix := text[index:]
+	if strings.Contains(suffix, "]") || len(suffix) > 64 {
+		return -1
+	}
+	if strings.HasPrefix(suffix, piiPlaceholderPrefix) || strings.HasPrefix(piiPlaceholderPrefix, suffix) {
+		return index
+	}
+	return -1
+}
+
+// RestoreStreamChunk 在流式分块中还原占位符。占位符可能被拆分到多个分块，
+// 分块末尾不完整的占位符会暂存，拼接到下一个分块的同一文本字段中再还原
+func (s *PIIScrubber) RestoreStreamChunk(data string) string {
+	raw := []byte(data)
+	paths, jsonPaths := piiRestorePaths(raw)
+	if len(paths) == 0 {
+		return data
+	}
+	texts := getTextValues(raw, paths)
+	for i, path := range paths {
+		text := s.carry[path] + texts[i]
+		delete(s.carry, path)
+		if index := partialPlaceholderIndex(text); index >= 0 {
+			s.carry[path] = text[index:]
+			s.carryChunk = append([]byte(nil), raw...)
+			text = text[:index]
+		}
+		texts[i] = s.restore(text, jsonPaths[path])
+	}
+	return string(setTextValues(raw, paths, texts))
+}
+
+// Flush 流结束时返回包含暂存内容的分块，暂存的文本不是完整的占位符，按原样输出；没有暂存内容时返回空字符串
+func (s *PIIScrubber) Flush() string {
+	if len(s.carry) == 0 || s.carryChunk == nil {
+		return ""
+	}
+	paths, jsonPaths := piiRestorePaths(s.carryChunk)
+	data := setTextValues(s.carryChunk, paths, make([]string, len(paths)))
+	for path, text := range s.carry {
+		if updated, err := sjson.SetBytes(data, path, s.restore(text, jsonPaths[path])); err == nil {
+			data = updated
+		}
+	}
+	s.carry = make(map[string]string)
+	s.carryChunk = nil
+	return string(data)
+}
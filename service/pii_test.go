@@ -0,0 +1,61 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func newTestPIIScrubber(values map[string]string) *PIIScrubber {
+	scrubber := newPIIScrubber()
+	for piiType, value := range values {
+		scrubber.placeholder(piiType, value)
+	}
+	scrubber.buildRestorers()
+	return scrubber
+}
+
+func TestRestoreStreamChunkSplitToolCallArguments(t *testing.T) {
+	scrubber := newTestPIIScrubber(map[string]string{"email": `a"b@example.com`})
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"send_email","arguments":"{\"to\":\"[PII_EM"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"AIL_1]\"}"}}]}}]}`,
+	}
+	var arguments strings.Builder
+	for _, chunk := range chunks {
+		restored := scrubber.RestoreStreamChunk(chunk)
+		arguments.WriteString(gjson.Get(restored, "choices.0.delta.tool_calls.0.function.arguments").String())
+	}
+	if flushed := scrubber.Flush(); flushed != "" {
+		t.Fatalf("unexpected flushed chunk: %s", flushed)
+	}
+	if got := gjson.Get(arguments.String(), "to").String(); got != `a"b@example.com` {
+		t.Fatalf("arguments not restored: %s", arguments.String())
+	}
+}
+
+func TestRestoreStreamChunkSplitClaudeInputJSONDelta(t *testing.T) {
+	scrubber := newTestPIIScrubber(map[string]string{"phone": "13800138000"})
+	chunks := []string{
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"phone\": \"[PII_"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"PHONE_1]\"}"}}`,
+	}
+	var input strings.Builder
+	for _, chunk := range chunks {
+		input.WriteString(gjson.Get(scrubber.RestoreStreamChunk(chunk), "delta.partial_json").String())
+	}
+	if got := gjson.Get(input.String(), "phone").String(); got != "13800138000" {
+		t.Fatalf("partial_json not restored: %s", input.String())
+	}
+}
+
+func TestRestoreStreamChunkGeminiFunctionCallArgs(t *testing.T) {
+	scrubber := newTestPIIScrubber(map[string]string{"email": "user@example.com"})
+	chunk := `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"send_email","args":{"to":"[PII_EMAIL_1]","cc":["[PII_EMAIL_1]"]}}}]}}]}`
+	restored := scrubber.RestoreStreamChunk(chunk)
+	args := gjson.Get(restored, "candidates.0.content.parts.0.functionCall.args")
+	if args.Get("to").String() != "user@example.com" || args.Get("cc.0").String() != "user@example.com" {
+		t.Fatalf("functionCall.args not restored: %s", restored)
+	}
+}
@@ -89,7 +89,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := GetBillingQuota(relayInfo)
 	if err != nil {
 		return err
 	}
@@ -155,6 +155,9 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
+	if relayInfo.IsReplay {
+		return
+	}
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -211,6 +214,8 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
+	ReconcileTokenRateLimit(ctx, totalTokens)
+	RecordChannelKeyTokens(relayInfo, totalTokens)
 
 	logModel := modelName
 	if extraContent != "" {
@@ -235,6 +240,14 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	if relayInfo.IsReplay {
+		logger.LogInfo(ctx, "replayed request, skip billing")
+		return
+	}
+	if !relayInfo.HedgeClaim() {
+		logger.LogInfo(ctx, "hedged request lost, skip billing")
+		return
+	}
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
@@ -319,6 +332,8 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 			logger.LogError(ctx, "error consuming token remain quota: "+err.Error())
 		}
 	}
+	ReconcileTokenRateLimit(ctx, totalTokens)
+	RecordChannelKeyTokens(relayInfo, totalTokens)
 
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio, cacheCreationTokens, cacheCreationRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
@@ -361,6 +376,14 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	if relayInfo.IsReplay {
+		logger.LogInfo(ctx, "replayed request, skip billing")
+		return
+	}
+	if !relayInfo.HedgeClaim() {
+		logger.LogInfo(ctx, "hedged request lost, skip billing")
+		return
+	}
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -440,6 +463,8 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 			logger.LogError(ctx, "error consuming token remain quota: "+err.Error())
 		}
 	}
+	ReconcileTokenRateLimit(ctx, totalTokens)
+	RecordChannelKeyTokens(relayInfo, totalTokens)
 
 	logModel := relayInfo.OriginModelName
 	if extraContent != "" {
@@ -487,12 +512,59 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	return nil
 }
 
+// GetBillingQuota 返回本次请求可用的额度，组织令牌使用成员可用的组织额度
+func GetBillingQuota(relayInfo *relaycommon.RelayInfo) (int, error) {
+	if relayInfo.OrgId != 0 {
+		return model.GetOrganizationAvailableQuota(relayInfo.OrgId, relayInfo.UserId)
+	}
+	return model.GetUserQuota(relayInfo.UserId, false)
+}
+
+func decreaseBillingQuota(relayInfo *relaycommon.RelayInfo, quota int, source model.QuotaLedgerSource) error {
+	if relayInfo.OrgId != 0 {
+		return model.DecreaseOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, quota, source)
+	}
+	return model.DecreaseUserQuota(relayInfo.UserId, quota, source)
+}
+
+func increaseBillingQuota(relayInfo *relaycommon.RelayInfo, quota int, source model.QuotaLedgerSource) error {
+	if relayInfo.OrgId != 0 {
+		return model.IncreaseOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, quota, source)
+	}
+	return model.IncreaseUserQuota(relayInfo.UserId, quota, false, source)
+}
+
+// quotaLedgerSource 请求扣费或退还时写入账本的来源，令牌额度与用户额度同时变化
+func quotaLedgerSource(relayInfo *relaycommon.RelayInfo, quota int, ledgerType int) model.QuotaLedgerSource {
+	source := model.QuotaLedgerSource{
+		Type:      ledgerType,
+		TokenId:   relayInfo.TokenId,
+		SourceRef: relayInfo.RequestId,
+	}
+	if !relayInfo.IsPlayground {
+		source.TokenUsedDelta = quota
+	}
+	return source
+}
+
+// RecordChannelKeyTokens 结算时按实际消耗累计多Key渠道所用密钥的 token 数，供密钥的 TPM 限制使用，回放请求不计入
+func RecordChannelKeyTokens(relayInfo *relaycommon.RelayInfo, tokens int) {
+	if relayInfo.IsReplay || !relayInfo.ChannelIsMultiKey {
+		return
+	}
+	model.RecordChannelKeyTokens(relayInfo.ChannelId, relayInfo.ChannelMultiKeyIndex, tokens)
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
+	return postConsumeQuota(relayInfo, quota, preConsumedQuota, sendEmail, model.QuotaLedgerTypeConsume)
+}
 
+func postConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool, ledgerType int) (err error) {
+	source := quotaLedgerSource(relayInfo, quota, ledgerType)
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = decreaseBillingQuota(relayInfo, quota, source)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = increaseBillingQuota(relayInfo, -quota, source)
 	}
 	if err != nil {
 		return err
@@ -519,6 +591,10 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 }
 
 func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int) {
+	// 组织令牌消费的是组织额度，不按成员个人的预警阈值通知成员
+	if relayInfo.OrgId != 0 {
+		return
+	}
 	gopool.Go(func() {
 		userSetting := relayInfo.UserSetting
 		threshold := common.QuotaRemindThreshold
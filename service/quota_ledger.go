@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QuotaReconcileReport 一次账本核对的结果
+type QuotaReconcileReport struct {
+	StartedAt   int64              `json:"started_at"`
+	FinishedAt  int64              `json:"finished_at"`
+	UserDrifts  []model.QuotaDrift `json:"user_drifts"`
+	TokenDrifts []model.QuotaDrift `json:"token_drifts"`
+	OrgDrifts   []model.QuotaDrift `json:"org_drifts"`
+	// 开启批量更新时核对前会先写入本节点暂存的变动，其他节点暂存的变动仍可能造成短暂的差异
+	BatchUpdateEnabled bool `json:"batch_update_enabled"`
+}
+
+func (r *QuotaReconcileReport) HasDrift() bool {
+	return len(r.UserDrifts) > 0 || len(r.TokenDrifts) > 0 || len(r.OrgDrifts) > 0
+}
+
+var lastQuotaReconcileReport atomic.Pointer[QuotaReconcileReport]
+
+// GetLastQuotaReconcileReport 返回本节点最近一次核对的结果，尚未核对时返回 nil
+func GetLastQuotaReconcileReport() *QuotaReconcileReport {
+	return lastQuotaReconcileReport.Load()
+}
+
+// ReconcileQuota 比较账本合计与 User.Quota、Token.UsedQuota，记录并返回存在差异的用户与令牌
+func ReconcileQuota() (*QuotaReconcileReport, error) {
+	report := &QuotaReconcileReport{
+		StartedAt:          common.GetTimestamp(),
+		BatchUpdateEnabled: common.BatchUpdateEnabled,
+	}
+	if common.BatchUpdateEnabled {
+		model.FlushBatchUpdate()
+	}
+	var err error
+	report.UserDrifts, err = model.GetUserQuotaDrifts()
+	if err != nil {
+		return nil, err
+	}
+	report.TokenDrifts, err = model.GetTokenUsedQuotaDrifts()
+	if err != nil {
+		return nil, err
+	}
+	report.OrgDrifts, err = model.GetOrganizationQuotaDrifts()
+	if err != nil {
+		return nil, err
+	}
+	report.FinishedAt = common.GetTimestamp()
+	lastQuotaReconcileReport.Store(report)
+
+	if report.HasDrift() {
+		common.SysLog(fmt.Sprintf("quota reconciliation found %d user drifts, %d token drifts and %d organization drifts", len(report.UserDrifts), len(report.TokenDrifts), len(report.OrgDrifts)))
+	}
+	return report, nil
+}
+
+// maxNotifiedQuotaDrifts 通知中最多列出的差异条数
+const maxNotifiedQuotaDrifts = 10
+
+func formatQuotaDrifts(report *QuotaReconcileReport) string {
+	lines := make([]string, 0, maxNotifiedQuotaDrifts)
+	for _, drift := range report.UserDrifts {
+		if len(lines) >= maxNotifiedQuotaDrifts {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("用户 #%d：账本 %s，实际 %s，差额 %s", drift.UserId, logger.FormatQuota(drift.Expected), logger.FormatQuota(drift.Actual), logger.FormatQuota(drift.Drift)))
+	}
+	for _, drift := range report.TokenDrifts {
+		if len(lines) >= maxNotifiedQuotaDrifts {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("令牌 #%d（用户 #%d）已用额度：账本 %s，实际 %s，差额 %s", drift.TokenId, drift.UserId, logger.FormatQuota(drift.Expected), logger.FormatQuota(drift.Actual), logger.FormatQuota(drift.Drift)))
+	}
+	for _, drift := range report.OrgDrifts {
+		if len(lines) >= maxNotifiedQuotaDrifts {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("组织 #%d：账本 %s，实际 %s，差额 %s", drift.OrgId, logger.FormatQuota(drift.Expected), logger.FormatQuota(drift.Actual), logger.FormatQuota(drift.Drift)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func quotaDriftKey(drift model.QuotaDrift) [3]int {
+	return [3]int{drift.UserId, drift.OrgId, drift.TokenId}
+}
+
+// persistentQuotaDrifts 返回在上一次核对中也存在的差异
+func persistentQuotaDrifts(previous []model.QuotaDrift, current []model.QuotaDrift) []model.QuotaDrift {
+	seen := make(map[[3]int]bool, len(previous))
+	for _, drift := range previous {
+		seen[quotaDriftKey(drift)] = true
+	}
+	drifts := make([]model.QuotaDrift, 0)
+	for _, drift := range current {
+		if seen[quotaDriftKey(drift)] {
+			drifts = append(drifts, drift)
+		}
+	}
+	return drifts
+}
+
+var reconcileQuotaOnce sync.Once
+
+// AutomaticallyReconcileQuota 按设置的间隔核对账本，只在主节点运行。
+// 开启批量更新时只通知连续两次核对都存在的差异，忽略其他节点尚未写入造成的短暂差异
+func AutomaticallyReconcileQuota() {
+	reconcileQuotaOnce.Do(func() {
+		var previous *QuotaReconcileReport
+		for {
+			s := operation_setting.GetQuotaLedgerSetting()
+			interval := s.ReconcileInterval
+			if interval <= 0 {
+				interval = 24 * 60
+			}
+			time.Sleep(time.Duration(interval) * time.Minute)
+			if !s.ReconcileEnabled {
+				continue
+			}
+			report, err := ReconcileQuota()
+			if err != nil {
+				common.SysLog("failed to reconcile quota: " + err.Error())
+				continue
+			}
+			notified := report
+			if report.BatchUpdateEnabled {
+				notified = &QuotaReconcileReport{}
+				if previous != nil {
+					notified.UserDrifts = persistentQuotaDrifts(previous.UserDrifts, report.UserDrifts)
+					notified.TokenDrifts = persistentQuotaDrifts(previous.TokenDrifts, report.TokenDrifts)
+					notified.OrgDrifts = persistentQuotaDrifts(previous.OrgDrifts, report.OrgDrifts)
+				}
+			}
+			previous = report
+			if notified.HasDrift() && s.NotifyRoot {
+				subject := fmt.Sprintf("额度核对发现 %d 个用户、%d 个令牌、%d 个组织存在差异", len(notified.UserDrifts), len(notified.TokenDrifts), len(notified.OrgDrifts))
+				NotifyRootUser(dto.NotifyTypeQuotaDrift, subject, formatQuotaDrifts(notified))
+			}
+		}
+	})
+}
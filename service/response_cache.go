@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ResponseCacheEntry 缓存的非流式响应，流式请求命中时由它重新生成 SSE
+type ResponseCacheEntry struct {
+	Response  dto.OpenAITextResponse `json:"response"`
+	Usage     dto.Usage              `json:"usage"`
+	CreatedAt int64                  `json:"created_at"`
+}
+
+type memoryCacheItem struct {
+	data     string
+	expireAt time.Time
+}
+
+var responseMemoryCache = make(map[string]memoryCacheItem)
+var responseMemoryCacheLock sync.Mutex
+
+// GetResponseCache 未命中时返回 nil；启用 Redis 时使用 Redis，否则使用内存缓存
+func GetResponseCache(key string) (*ResponseCacheEntry, error) {
+	var data string
+	if common.RedisEnabled {
+		val, err := common.RedisGet(key)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		data = val
+	} else {
+		responseMemoryCacheLock.Lock()
+		item, ok := responseMemoryCache[key]
+		if ok && time.Now().After(item.expireAt) {
+			delete(responseMemoryCache, key)
+			ok = false
+		}
+		responseMemoryCacheLock.Unlock()
+		if !ok {
+			return nil, nil
+		}
+		data = item.data
+	}
+	var entry ResponseCacheEntry
+	if err := common.UnmarshalJsonStr(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry) error {
+	data, err := common.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	ttl := operation_setting.GetResponseCacheTTL()
+	if common.RedisEnabled {
+		return common.RedisSet(key, string(data), ttl)
+	}
+	now := time.Now()
+	maxEntries := operation_setting.GetResponseCacheSetting().MemoryMaxEntries
+	responseMemoryCacheLock.Lock()
+	defer responseMemoryCacheLock.Unlock()
+	if maxEntries > 0 && len(responseMemoryCache) >= maxEntries {
+		for k, item := range responseMemoryCache {
+			if now.After(item.expireAt) {
+				delete(responseMemoryCache, k)
+			}
+		}
+		// 仍然没有空间时随机淘汰，map 的遍历顺序本身是随机的
+		for k := range responseMemoryCache {
+			if len(responseMemoryCache) < maxEntries {
+				break
+			}
+			delete(responseMemoryCache, k)
+		}
+	}
+	responseMemoryCache[key] = memoryCacheItem{data: string(data), expireAt: now.Add(ttl)}
+	return nil
+}
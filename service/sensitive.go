@@ -4,7 +4,9 @@ import (
 	"errors"
 	"one-api/dto"
 	"one-api/setting"
+	"sort"
 	"strings"
+	"unicode"
 )
 
 func CheckSensitiveMessages(messages []dto.Message) ([]string, error) {
@@ -47,30 +49,97 @@ func SensitiveWordContains(text string) (bool, []string) {
 	return AcSearch(checkText, setting.SensitiveWords, true)
 }
 
+// sensitiveWordMask 替换敏感词使用的文本
+const sensitiveWordMask = "**###**"
+
+// sensitiveWordRanges 返回敏感词在文本中的 rune 区间（按位置排序，重叠的区间会合并）与命中的敏感词
+func sensitiveWordRanges(runes []rune) ([][2]int, []string) {
+	if len(setting.SensitiveWords) == 0 || len(runes) == 0 {
+		return nil, nil
+	}
+	m := getOrBuildAC(setting.SensitiveWords)
+	if m == nil {
+		return nil, nil
+	}
+	// 逐个字符转换小写，保证位置与原文一致
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	hits := m.MultiPatternSearch(lower, false)
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Pos < hits[j].Pos
+	})
+	ranges := make([][2]int, 0, len(hits))
+	words := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		start, end := hit.Pos, hit.Pos+len(hit.Word)
+		words = append(words, string(hit.Word))
+		if n := len(ranges); n > 0 && start <= ranges[n-1][1] {
+			ranges[n-1][1] = max(ranges[n-1][1], end)
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, RemoveDuplicate(words)
+}
+
 // SensitiveWordReplace 敏感词替换，返回是否包含敏感词和替换后的文本
 func SensitiveWordReplace(text string, returnImmediately bool) (bool, []string, string) {
-	if len(setting.SensitiveWords) == 0 {
+	runes := []rune(text)
+	ranges, words := sensitiveWordRanges(runes)
+	if len(ranges) == 0 {
 		return false, nil, text
 	}
-	checkText := strings.ToLower(text)
-	m := getOrBuildAC(setting.SensitiveWords)
-	hits := m.MultiPatternSearch([]rune(checkText), returnImmediately)
-	if len(hits) > 0 {
-		words := make([]string, 0, len(hits))
-		var builder strings.Builder
-		builder.Grow(len(text))
-		lastPos := 0
+	if returnImmediately {
+		ranges = ranges[:1]
+		words = []string{strings.ToLower(string(runes[ranges[0][0]:ranges[0][1]]))}
+	}
+	var builder strings.Builder
+	builder.Grow(len(text))
+	lastPos := 0
+	for _, r := range ranges {
+		builder.WriteString(string(runes[lastPos:r[0]]))
+		builder.WriteString(sensitiveWordMask)
+		lastPos = r[1]
+	}
+	builder.WriteString(string(runes[lastPos:]))
+	return true, words, builder.String()
+}
 
-		for _, hit := range hits {
-			pos := hit.Pos
-			word := string(hit.Word)
-			builder.WriteString(text[lastPos:pos])
-			builder.WriteString("**###**")
-			lastPos = pos + len(word)
-			words = append(words, word)
+// sensitiveWordReplaceSegments 将多段文本拼接为整体检测，敏感词可以跨越多段。
+// 替换标记写入敏感词开始的那一段，其余段中属于敏感词的部分直接删除，拼接后与 SensitiveWordReplace 的结果一致
+func sensitiveWordReplaceSegments(segments []string) ([]string, []string) {
+	all := make([]rune, 0)
+	offsets := make([]int, len(segments)+1)
+	for i, segment := range segments {
+		all = append(all, []rune(segment)...)
+		offsets[i+1] = len(all)
+	}
+	ranges, words := sensitiveWordRanges(all)
+	if len(ranges) == 0 {
+		return segments, nil
+	}
+	replaced := make([]string, len(segments))
+	r := 0
+	for i := range segments {
+		var builder strings.Builder
+		for pos := offsets[i]; pos < offsets[i+1]; pos++ {
+			for r < len(ranges) && ranges[r][1] <= pos {
+				r++
+			}
+			if r < len(ranges) && pos >= ranges[r][0] {
+				if pos == ranges[r][0] {
+					builder.WriteString(sensitiveWordMask)
+				}
+				continue
+			}
+			builder.WriteRune(all[pos])
 		}
-		builder.WriteString(text[lastPos:])
-		return true, words, builder.String()
+		replaced[i] = builder.String()
 	}
-	return false, nil, text
+	return replaced, words
 }
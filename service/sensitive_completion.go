@@ -0,0 +1,247 @@
+package service
+
+import (
+	"fmt"
+	"one-api/constant"
+	"one-api/logger"
+	"one-api/setting"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// completionTextPaths 返回响应或流式分块中模型输出文本字段的 JSON 路径，
+// 兼容 OpenAI Chat Completions、Completions、Responses、Claude 与 Gemini 格式
+func completionTextPaths(data []byte) []string {
+	paths := make([]string, 0)
+	addString := func(path string) {
+		if gjson.GetBytes(data, path).Type == gjson.String {
+			paths = append(paths, path)
+		}
+	}
+	root := gjson.ParseBytes(data)
+	root.Get("choices").ForEach(func(key, _ gjson.Result) bool {
+		prefix := "choices." + key.String()
+		addString(prefix + ".delta.reasoning_content")
+		addString(prefix + ".delta.content")
+		addString(prefix + ".message.reasoning_content")
+		addString(prefix + ".message.content")
+		addString(prefix + ".text")
+		return true
+	})
+	root.Get("candidates").ForEach(func(key, candidate gjson.Result) bool {
+		candidate.Get("content.parts").ForEach(func(index, _ gjson.Result) bool {
+			addString(fmt.Sprintf("candidates.%s.content.parts.%s.text", key.String(), index.String()))
+			return true
+		})
+		return true
+	})
+	addContentTexts := func(prefix string) {
+		root.Get(prefix + "content").ForEach(func(key, _ gjson.Result) bool {
+			addString(prefix + "content." + key.String() + ".thinking")
+			addString(prefix + "content." + key.String() + ".text")
+			return true
+		})
+	}
+	switch eventType := root.Get("type").String(); {
+	case eventType == "content_block_delta":
+		addString("delta.thinking")
+		addString("delta.text")
+	case eventType == "message":
+		addContentTexts("")
+	case strings.HasSuffix(eventType, "text.delta"):
+		addString("delta")
+	case strings.HasSuffix(eventType, "text.done"):
+		addString("text")
+	case strings.HasPrefix(eventType, "response.content_part."):
+		addString("part.text")
+	case strings.HasPrefix(eventType, "response.output_item."):
+		addContentTexts("item.")
+	}
+	// Responses 的完整响应，流式中的 response.completed 等事件也会携带
+	for _, prefix := range []string{"output", "response.output"} {
+		root.Get(prefix).ForEach(func(key, _ gjson.Result) bool {
+			addContentTexts(prefix + "." + key.String() + ".")
+			return true
+		})
+	}
+	return paths
+}
+
+func getTextValues(data []byte, paths []string) []string {
+	texts := make([]string, len(paths))
+	for i, path := range paths {
+		texts[i] = gjson.GetBytes(data, path).String()
+	}
+	return texts
+}
+
+func setTextValues(data []byte, paths []string, texts []string) []byte {
+	for i, path := range paths {
+		if updated, err := sjson.SetBytes(data, path, texts[i]); err == nil {
+			data = updated
+		}
+	}
+	return data
+}
+
+// markContentFiltered 清空输出文本，并按各格式的方式标记内容被过滤
+func markContentFiltered(data []byte, paths []string) []byte {
+	data = setTextValues(data, paths, make([]string, len(paths)))
+	set := func(path string, value string) {
+		if updated, err := sjson.SetBytes(data, path, value); err == nil {
+			data = updated
+		}
+	}
+	root := gjson.ParseBytes(data)
+	root.Get("choices").ForEach(func(key, _ gjson.Result) bool {
+		set("choices."+key.String()+".finish_reason", constant.FinishReasonContentFilter)
+		return true
+	})
+	root.Get("candidates").ForEach(func(key, _ gjson.Result) bool {
+		set("candidates."+key.String()+".finishReason", "SAFETY")
+		return true
+	})
+	if root.Get("type").String() == "message" {
+		set("stop_reason", "refusal")
+	}
+	if root.Get("object").String() == "response" {
+		set("status", "incomplete")
+		set("incomplete_details.reason", constant.FinishReasonContentFilter)
+	}
+	return data
+}
+
+// ModerateCompletionBody 检测非流式响应中模型输出的敏感词，按设置替换敏感词或清空输出并标记内容被过滤
+func ModerateCompletionBody(c *gin.Context, data []byte) []byte {
+	if !setting.ShouldCheckCompletionSensitive() {
+		return data
+	}
+	paths := completionTextPaths(data)
+	if len(paths) == 0 {
+		return data
+	}
+	replaced, words := sensitiveWordReplaceSegments(getTextValues(data, paths))
+	if len(words) == 0 {
+		return data
+	}
+	logger.LogWarn(c, fmt.Sprintf("completion sensitive words detected: %s", strings.Join(words, ", ")))
+	if setting.StopOnSensitiveEnabled {
+		return markContentFiltered(data, paths)
+	}
+	return setTextValues(data, paths, replaced)
+}
+
+type moderatedChunk struct {
+	data  []byte
+	paths []string
+}
+
+// CompletionModerator 流式输出的敏感词检测。
+// 最近的 StreamCacheQueueLength 个分块会先缓存再发送，与已发送文本的末尾一起作为滑动窗口检测，
+// 跨分块的敏感词在其余部分发送前即可被发现
+type CompletionModerator struct {
+	c           *gin.Context
+	queueLength int
+	pending     []moderatedChunk
+	stopped     bool
+	// sentTail 已发送文本的最后 tailLength 个字符，tailLength 为最长敏感词的长度减一
+	sentTail   []rune
+	tailLength int
+}
+
+// NewCompletionModerator 未开启输出检测时返回 nil
+func NewCompletionModerator(c *gin.Context) *CompletionModerator {
+	if !setting.ShouldCheckCompletionSensitive() {
+		return nil
+	}
+	maxWordLength := 0
+	for _, word := range setting.SensitiveWords {
+		maxWordLength = max(maxWordLength, utf8.RuneCountInString(word))
+	}
+	return &CompletionModerator{
+		c:           c,
+		queueLength: max(setting.StreamCacheQueueLength, 0),
+		tailLength:  max(maxWordLength-1, 0),
+	}
+}
+
+// appendSent 记录发送的分块文本，只保留末尾可能组成敏感词前半部分的字符
+func (m *CompletionModerator) appendSent(chunk moderatedChunk) {
+	if m.tailLength == 0 {
+		return
+	}
+	for _, text := range getTextValues(chunk.data, chunk.paths) {
+		m.sentTail = append(m.sentTail, []rune(text)...)
+	}
+	if len(m.sentTail) > m.tailLength {
+		m.sentTail = append([]rune(nil), m.sentTail[len(m.sentTail)-m.tailLength:]...)
+	}
+}
+
+// Push 加入一个上游分块，返回可以发送的分块；stop 为 true 时发送返回的分块后应结束流
+func (m *CompletionModerator) Push(data string) (ready []string, stop bool) {
+	if m.stopped {
+		return nil, true
+	}
+	chunk := moderatedChunk{data: []byte(data)}
+	chunk.paths = completionTextPaths(chunk.data)
+	m.pending = append(m.pending, chunk)
+
+	// 第一段为已发送文本的末尾，只用于检测，不再修改
+	segments := []string{string(m.sentTail)}
+	for _, pending := range m.pending {
+		segments = append(segments, getTextValues(pending.data, pending.paths)...)
+	}
+	replaced, words := sensitiveWordReplaceSegments(segments)
+	if len(words) > 0 {
+		logger.LogWarn(m.c, fmt.Sprintf("completion sensitive words detected: %s", strings.Join(words, ", ")))
+		if setting.StopOnSensitiveEnabled {
+			// 缓存中的分块都还没有发送，清空其中的文本后发送，最后一个分块标记内容被过滤
+			last := len(m.pending) - 1
+			for i, pending := range m.pending {
+				if i == last {
+					ready = append(ready, string(markContentFiltered(pending.data, pending.paths)))
+				} else {
+					ready = append(ready, string(setTextValues(pending.data, pending.paths, make([]string, len(pending.paths)))))
+				}
+			}
+			m.pending = nil
+			m.stopped = true
+			return ready, true
+		}
+		if replaced[0] != segments[0] {
+			// 敏感词从已发送的文本开始，替换标记写入剩余部分所在的第一段
+			for i := 1; i < len(segments); i++ {
+				if replaced[i] != segments[i] {
+					replaced[i] = sensitiveWordMask + replaced[i]
+					break
+				}
+			}
+		}
+		offset := 1
+		for i, pending := range m.pending {
+			m.pending[i].data = setTextValues(pending.data, pending.paths, replaced[offset:offset+len(pending.paths)])
+			offset += len(pending.paths)
+		}
+	}
+	for len(m.pending) > m.queueLength {
+		m.appendSent(m.pending[0])
+		ready = append(ready, string(m.pending[0].data))
+		m.pending = m.pending[1:]
+	}
+	return ready, false
+}
+
+// Flush 上游输出结束后返回缓存中剩余的分块
+func (m *CompletionModerator) Flush() []string {
+	ready := make([]string, 0, len(m.pending))
+	for _, pending := range m.pending {
+		ready = append(ready, string(pending.data))
+	}
+	m.pending = nil
+	return ready
+}
@@ -0,0 +1,59 @@
+package service
+
+import "github.com/gin-gonic/gin"
+
+// StreamOutputFilter 依次还原流式分块中的敏感信息占位符并检测输出敏感词，
+// 供不经过 StreamScannerHandler 的渠道（如 AWS SDK）复用同样的处理
+type StreamOutputFilter struct {
+	piiScrubber *PIIScrubber
+	moderator   *CompletionModerator
+}
+
+// NewStreamOutputFilter 两项处理都未开启时返回 nil，nil 的 Push 与 Flush 原样返回分块
+func NewStreamOutputFilter(c *gin.Context) *StreamOutputFilter {
+	piiScrubber := GetPIIScrubber(c)
+	moderator := NewCompletionModerator(c)
+	if piiScrubber == nil && moderator == nil {
+		return nil
+	}
+	return &StreamOutputFilter{piiScrubber: piiScrubber, moderator: moderator}
+}
+
+// Push 加入一个上游分块，返回可以发送的分块；stop 为 true 时发送返回的分块后应结束流
+func (f *StreamOutputFilter) Push(data string) (ready []string, stop bool) {
+	if f == nil {
+		return []string{data}, false
+	}
+	if f.piiScrubber != nil {
+		data = f.piiScrubber.RestoreStreamChunk(data)
+	}
+	if f.moderator == nil {
+		return []string{data}, false
+	}
+	return f.moderator.Push(data)
+}
+
+// Flush 上游输出结束后返回暂存的占位符分块与检测缓存中剩余的分块
+func (f *StreamOutputFilter) Flush() []string {
+	if f == nil {
+		return nil
+	}
+	var ready []string
+	if f.piiScrubber != nil {
+		data := f.piiScrubber.Flush()
+		if data != "" && f.moderator == nil {
+			return []string{data}
+		}
+		if data != "" {
+			chunks, stop := f.moderator.Push(data)
+			ready = append(ready, chunks...)
+			if stop {
+				return ready
+			}
+		}
+	}
+	if f.moderator != nil {
+		ready = append(ready, f.moderator.Flush()...)
+	}
+	return ready
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/common/limiter"
+	"one-api/constant"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type tpmBucket struct {
+	key   string
+	limit int
+}
+
+// TokenRateLimitLease 一次请求占用的 TPM 与并发名额，请求结束时释放
+type TokenRateLimitLease struct {
+	reserved        int
+	buckets         []tpmBucket
+	concurrencyKeys []string
+	reconciled      atomic.Bool
+	released        atomic.Bool
+}
+
+func tpmRatePerSecond(limit int) float64 {
+	return float64(limit) / 60
+}
+
+func formatRateLimitReset(seconds float64) string {
+	if seconds <= 0 {
+		return "0s"
+	}
+	return (time.Duration(math.Ceil(seconds*1000)) * time.Millisecond).String()
+}
+
+func rateLimitError(c *gin.Context, message string, retryAfter float64) *types.NewAPIError {
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter))))
+	}
+	return types.NewErrorWithStatusCode(fmt.Errorf("%s", message), types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+}
+
+// tokenRateLimit 返回令牌的限制，令牌上单独设置的 TPM 不受全局开关影响，并覆盖全局的令牌限制
+func tokenRateLimit(c *gin.Context) operation_setting.TokenRateLimit {
+	var limit operation_setting.TokenRateLimit
+	if setting := operation_setting.GetTokenRateLimitSetting(); setting.Enabled {
+		limit = setting.Token
+	}
+	if tpm := common.GetContextKeyInt(c, constant.ContextKeyTokenTpmLimit); tpm > 0 {
+		limit.TPM = tpm
+	}
+	return limit
+}
+
+// CheckTokenTPMLimit 在转发前检查令牌的 TPM 桶是否已经透支，不预扣 token。
+// 异步任务等无法预估 token 数的请求也要经过这一检查，透支期间拒绝请求
+func CheckTokenTPMLimit(c *gin.Context) *types.NewAPIError {
+	limit := tokenRateLimit(c)
+	if limit.TPM <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("rateLimit:tpm:token:%d", common.GetContextKeyInt(c, constant.ContextKeyTokenId))
+	rate := tpmRatePerSecond(limit.TPM)
+	allowed, remaining, err := limiter.TakeTokens(context.Background(), key, 0, int64(limit.TPM), rate, false)
+	if err != nil {
+		common.SysLog("check token tpm limit failed: " + err.Error())
+		return nil
+	}
+	if !allowed {
+		return rateLimitError(c, fmt.Sprintf("token tokens per minute limit exceeded: limit %d", limit.TPM), float64(-remaining)/rate)
+	}
+	return nil
+}
+
+// AcquireTokenRateLimit 按用户、令牌、分组检查并发数与 TPM，以及令牌的流式并发数，estimatedTokens 为 CountRequestToken 的预估值。
+// 通过后写入 x-ratelimit-* 响应头，请求结束时需调用 ReleaseTokenRateLimit
+func AcquireTokenRateLimit(c *gin.Context, info *relaycommon.RelayInfo, estimatedTokens int) *types.NewAPIError {
+	if info.IsReplay {
+		return nil
+	}
+	setting := operation_setting.GetTokenRateLimitSetting()
+	type scope struct {
+		name  string
+		key   string
+		limit operation_setting.TokenRateLimit
+	}
+	var scopes []scope
+	if setting.Enabled {
+		scopes = append(scopes, scope{name: "user", key: fmt.Sprintf("user:%d", info.UserId), limit: setting.User})
+		if groupLimit, ok := setting.Groups[info.UsingGroup]; ok {
+			scopes = append(scopes, scope{name: "group", key: "group:" + info.UsingGroup, limit: groupLimit})
+		}
+	}
+	tokenLimit := tokenRateLimit(c)
+	if tokenLimit.TPM > 0 || tokenLimit.Concurrency > 0 {
+		scopes = append(scopes, scope{name: "token", key: fmt.Sprintf("token:%d", info.TokenId), limit: tokenLimit})
+	}
+	if maxStreams := common.GetContextKeyInt(c, constant.ContextKeyTokenMaxStreams); maxStreams > 0 && info.IsStream {
+		scopes = append(scopes, scope{name: "token stream", key: fmt.Sprintf("stream:token:%d", info.TokenId), limit: operation_setting.TokenRateLimit{Concurrency: maxStreams}})
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	lease := &TokenRateLimitLease{reserved: estimatedTokens}
+	for _, s := range scopes {
+		key := "rateLimit:concurrency:" + s.key
+		allowed, err := limiter.AcquireConcurrency(ctx, key, s.limit.Concurrency)
+		if err != nil {
+			logger.LogError(c, "acquire concurrency failed: "+err.Error())
+			continue
+		}
+		if !allowed {
+			lease.release(ctx)
+			return rateLimitError(c, fmt.Sprintf("%s concurrency limit exceeded: at most %d requests in flight", s.name, s.limit.Concurrency), 1)
+		}
+		lease.concurrencyKeys = append(lease.concurrencyKeys, key)
+	}
+
+	// 上下文过长时按桶容量预扣，避免请求永远无法通过，超出部分在对账时补扣
+	headerLimit, headerRemaining := 0, int64(math.MaxInt64)
+	for _, s := range scopes {
+		if s.limit.TPM <= 0 {
+			continue
+		}
+		reserved := int64(min(estimatedTokens, s.limit.TPM))
+		key := "rateLimit:tpm:" + s.key
+		rate := tpmRatePerSecond(s.limit.TPM)
+		allowed, remaining, err := limiter.TakeTokens(ctx, key, reserved, int64(s.limit.TPM), rate, false)
+		if err != nil {
+			logger.LogError(c, "take tpm tokens failed: "+err.Error())
+			continue
+		}
+		if !allowed {
+			lease.release(ctx)
+			c.Header("x-ratelimit-limit-tokens", strconv.Itoa(s.limit.TPM))
+			c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(max(remaining, 0), 10))
+			c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(float64(int64(s.limit.TPM)-remaining)/rate))
+			return rateLimitError(c, fmt.Sprintf("%s tokens per minute limit exceeded: limit %d, requested %d", s.name, s.limit.TPM, reserved), float64(reserved-remaining)/rate)
+		}
+		lease.buckets = append(lease.buckets, tpmBucket{key: key, limit: s.limit.TPM})
+		if remaining < headerRemaining {
+			headerLimit, headerRemaining = s.limit.TPM, remaining
+		}
+	}
+	if headerLimit > 0 {
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(headerLimit))
+		c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(max(headerRemaining, 0), 10))
+		c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(float64(int64(headerLimit)-headerRemaining)/tpmRatePerSecond(headerLimit)))
+	}
+	common.SetContextKey(c, constant.ContextKeyTokenRateLimitLease, lease)
+	return nil
+}
+
+// take 按差值调整各个桶，delta 为负数时退还
+func (l *TokenRateLimitLease) take(ctx context.Context, actualTokens int) {
+	for _, b := range l.buckets {
+		delta := int64(actualTokens) - int64(min(l.reserved, b.limit))
+		if delta == 0 {
+			continue
+		}
+		if _, _, err := limiter.TakeTokens(ctx, b.key, delta, int64(b.limit), tpmRatePerSecond(b.limit), true); err != nil {
+			common.SysLog(fmt.Sprintf("reconcile tpm bucket %s failed: %v", b.key, err))
+		}
+	}
+}
+
+func (l *TokenRateLimitLease) release(ctx context.Context) {
+	if !l.released.CompareAndSwap(false, true) {
+		return
+	}
+	for _, key := range l.concurrencyKeys {
+		limiter.ReleaseConcurrency(ctx, key)
+	}
+	// 没有结算的请求（上游失败等）退还预扣的 token
+	if l.reconciled.CompareAndSwap(false, true) {
+		l.take(ctx, 0)
+	}
+}
+
+// ReconcileTokenRateLimit 结算后按实际消耗的 token 数修正预扣值
+func ReconcileTokenRateLimit(c *gin.Context, actualTokens int) {
+	lease, ok := common.GetContextKeyType[*TokenRateLimitLease](c, constant.ContextKeyTokenRateLimitLease)
+	if !ok || lease == nil {
+		return
+	}
+	if !lease.reconciled.CompareAndSwap(false, true) {
+		return
+	}
+	lease.take(context.Background(), actualTokens)
+}
+
+func ReleaseTokenRateLimit(c *gin.Context) {
+	lease, ok := common.GetContextKeyType[*TokenRateLimitLease](c, constant.ContextKeyTokenRateLimitLease)
+	if !ok || lease == nil {
+		return
+	}
+	lease.release(context.Background())
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type BatchSetting struct {
+	Enabled           bool    `json:"enabled"`
+	DiscountRatio     float64 `json:"discount_ratio"`      // 批处理请求计费折扣，1 表示不打折
+	Concurrency       int     `json:"concurrency"`         // 单个批处理任务的并发请求数
+	MaxRequests       int     `json:"max_requests"`        // 单个输入文件最多包含的请求行数
+	MaxRunningBatches int     `json:"max_running_batches"` // 单节点同时执行的批处理任务数
+}
+
+var batchSetting = BatchSetting{
+	Enabled:           true,
+	DiscountRatio:     0.5,
+	Concurrency:       4,
+	MaxRequests:       50000,
+	MaxRunningBatches: 2,
+}
+
+func init() {
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
+
+func GetBatchDiscountRatio() float64 {
+	if batchSetting.DiscountRatio <= 0 {
+		return 1
+	}
+	return batchSetting.DiscountRatio
+}
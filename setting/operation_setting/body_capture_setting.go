@@ -0,0 +1,47 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"regexp"
+)
+
+// BodyCaptureSetting 记录请求与响应内容用于排查问题，命中用户、令牌或采样时记录
+type BodyCaptureSetting struct {
+	Enabled        bool     `json:"enabled"`
+	UserIds        []int    `json:"user_ids"`
+	TokenIds       []int    `json:"token_ids"`
+	SampleRate     float64  `json:"sample_rate"`     // 0-1，对其他请求按比例采样
+	MaxBodyBytes   int      `json:"max_body_bytes"`  // 每段内容的最大字节数，超出部分截断
+	RetentionDays  int      `json:"retention_days"`  // 0 表示不自动清理
+	RedactPatterns []string `json:"redact_patterns"` // 保存前替换为 [REDACTED] 的正则
+}
+
+var bodyCaptureSetting = BodyCaptureSetting{
+	Enabled:       false,
+	UserIds:       []int{},
+	TokenIds:      []int{},
+	SampleRate:    0,
+	MaxBodyBytes:  64 * 1024,
+	RetentionDays: 7,
+	RedactPatterns: []string{
+		`sk-[A-Za-z0-9_\-]{16,}`,
+		`(?i)bearer\s+[A-Za-z0-9._\-]{16,}`,
+		`AKIA[0-9A-Z]{16}`,
+		`AIza[0-9A-Za-z_\-]{35}`,
+	},
+}
+
+var redactPatternCache = regexCache{name: "body capture redact pattern"}
+
+func init() {
+	config.GlobalConfig.Register("body_capture_setting", &bodyCaptureSetting)
+}
+
+func GetBodyCaptureSetting() *BodyCaptureSetting {
+	return &bodyCaptureSetting
+}
+
+// GetRedactPatterns 返回编译后的脱敏正则
+func (s *BodyCaptureSetting) GetRedactPatterns() []*regexp.Regexp {
+	return redactPatternCache.Valid(s.RedactPatterns)
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ChannelSelectModeWeighted = "weighted" // 按优先级与权重随机选择
+	ChannelSelectModeAdaptive = "adaptive" // 在权重基础上根据渠道近期成功率与延迟自动调整
+)
+
+type ChannelSelectSetting struct {
+	DefaultMode   string            `json:"default_mode"`
+	GroupModes    map[string]string `json:"group_modes"`    // 分组 -> 选择模式，未配置的分组使用 DefaultMode
+	WindowSeconds int               `json:"window_seconds"` // 统计滑动窗口长度
+	MinSamples    int               `json:"min_samples"`    // 样本数不足时不调整权重
+	MinFactor     float64           `json:"min_factor"`     // 权重最低缩放比例，保证劣化渠道仍有少量探测流量
+	LatencyWeight float64           `json:"latency_weight"` // 延迟对权重的影响程度，0 表示只看成功率
+}
+
+var channelSelectSetting = ChannelSelectSetting{
+	DefaultMode:   ChannelSelectModeWeighted,
+	GroupModes:    map[string]string{},
+	WindowSeconds: 300,
+	MinSamples:    10,
+	MinFactor:     0.05,
+	LatencyWeight: 0.5,
+}
+
+func init() {
+	config.GlobalConfig.Register("channel_select_setting", &channelSelectSetting)
+}
+
+func GetChannelSelectSetting() *ChannelSelectSetting {
+	return &channelSelectSetting
+}
+
+func GetChannelSelectMode(group string) string {
+	if mode, ok := channelSelectSetting.GroupModes[group]; ok && mode != "" {
+		return mode
+	}
+	if channelSelectSetting.DefaultMode == "" {
+		return ChannelSelectModeWeighted
+	}
+	return channelSelectSetting.DefaultMode
+}
+
+func IsChannelSelectAdaptive(group string) bool {
+	return GetChannelSelectMode(group) == ChannelSelectModeAdaptive
+}
@@ -0,0 +1,29 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type CircuitBreakerSetting struct {
+	Enabled              bool `json:"enabled"`
+	FailureThreshold     int  `json:"failure_threshold"`      // 连续失败多少次后熔断
+	CoolDownSeconds      int  `json:"cool_down_seconds"`      // 首次熔断的冷却时间，之后每次重新熔断翻倍
+	MaxCoolDownSeconds   int  `json:"max_cool_down_seconds"`  // 冷却时间上限
+	ProbeIntervalSeconds int  `json:"probe_interval_seconds"` // 半开状态下探测请求的最小间隔
+	HalfOpenSuccesses    int  `json:"half_open_successes"`    // 半开状态下连续成功多少次后恢复
+}
+
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:              true,
+	FailureThreshold:     5,
+	CoolDownSeconds:      30,
+	MaxCoolDownSeconds:   600,
+	ProbeIntervalSeconds: 5,
+	HalfOpenSuccesses:    2,
+}
+
+func init() {
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"time"
+)
+
+type HedgeSetting struct {
+	Enabled      bool           `json:"enabled"`
+	GroupDelayMs map[string]int `json:"group_delay_ms"` // 分组 -> 首字节超时毫秒数，超时后向第二个渠道发起对冲请求
+}
+
+var hedgeSetting = HedgeSetting{
+	Enabled:      false,
+	GroupDelayMs: map[string]int{},
+}
+
+func init() {
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// GetHedgeDelay 未开启对冲的分组返回 0
+func GetHedgeDelay(group string) time.Duration {
+	if !hedgeSetting.Enabled {
+		return 0
+	}
+	delayMs, ok := hedgeSetting.GroupDelayMs[group]
+	if !ok || delayMs <= 0 {
+		return 0
+	}
+	return time.Duration(delayMs) * time.Millisecond
+}
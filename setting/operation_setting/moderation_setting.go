@@ -0,0 +1,96 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"regexp"
+)
+
+// 审核命中后的处理方式
+const (
+	ModerationPolicyNone   = "none"   // 不审核
+	ModerationPolicyFlag   = "flag"   // 放行，只在日志中记录审核结果
+	ModerationPolicyRedact = "redact" // 替换命中的内容后放行，不支持替换的检测方式按拦截处理
+	ModerationPolicyBlock  = "block"  // 拦截请求
+)
+
+// 检测方式
+const (
+	ModerationProviderWordList = "word_list" // 敏感词列表
+	ModerationProviderRegex    = "regex"     // 正则规则
+	ModerationProviderModel    = "model"     // 通过渠道调用审核模型
+)
+
+// ModerationSetting 请求转发前的内容审核，按顺序执行各检测方式
+type ModerationSetting struct {
+	Enabled       bool              `json:"enabled"`
+	Providers     []string          `json:"providers"`
+	DefaultPolicy string            `json:"default_policy"`
+	GroupPolicies map[string]string `json:"group_policies"` // 分组 -> 处理方式，未配置的分组使用默认处理方式
+	RegexRules    []string          `json:"regex_rules"`
+	// 审核模型需兼容 OpenAI /v1/moderations 接口
+	ModelName      string `json:"model_name"`
+	ModelChannelId int    `json:"model_channel_id"` // 0 表示按模型在 ModelGroup 中选择渠道
+	ModelGroup     string `json:"model_group"`
+	ModelTimeout   int    `json:"model_timeout"` // 秒
+}
+
+var moderationSetting = ModerationSetting{
+	Enabled:       false,
+	Providers:     []string{ModerationProviderWordList},
+	DefaultPolicy: ModerationPolicyBlock,
+	GroupPolicies: map[string]string{},
+	RegexRules:    []string{},
+	ModelName:     "omni-moderation-latest",
+	ModelGroup:    "default",
+	ModelTimeout:  10,
+}
+
+var moderationRuleCache = regexCache{name: "moderation regex rule"}
+
+func init() {
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+func IsValidModerationPolicy(policy string) bool {
+	switch policy {
+	case ModerationPolicyNone, ModerationPolicyFlag, ModerationPolicyRedact, ModerationPolicyBlock:
+		return true
+	}
+	return false
+}
+
+func moderationPolicyLevel(policy string) int {
+	switch policy {
+	case ModerationPolicyFlag:
+		return 1
+	case ModerationPolicyRedact:
+		return 2
+	case ModerationPolicyBlock:
+		return 3
+	}
+	return 0
+}
+
+// GetModerationPolicy 返回分组的处理方式；令牌只能设置比分组更严格的处理方式
+func (s *ModerationSetting) GetModerationPolicy(group string, tokenPolicy string) string {
+	policy, ok := s.GroupPolicies[group]
+	if !ok || !IsValidModerationPolicy(policy) {
+		policy = s.DefaultPolicy
+	}
+	if !IsValidModerationPolicy(policy) {
+		policy = ModerationPolicyBlock
+	}
+	if moderationPolicyLevel(tokenPolicy) > moderationPolicyLevel(policy) {
+		policy = tokenPolicy
+	}
+	return policy
+}
+
+// GetRegexRules 返回编译后的正则规则
+func (s *ModerationSetting) GetRegexRules() []*regexp.Regexp {
+	return moderationRuleCache.Valid(s.RegexRules)
+}
@@ -9,12 +9,27 @@ import (
 type MonitorSetting struct {
 	AutoTestChannelEnabled bool `json:"auto_test_channel_enabled"`
 	AutoTestChannelMinutes int  `json:"auto_test_channel_minutes"`
+	// 测试渠道时逐个测试所有模型与端点类型，失败的模型只禁用对应的能力
+	ChannelTestMatrixEnabled bool `json:"channel_test_matrix_enabled"`
+	// 定期探测多Key渠道中被自动禁用的密钥，禁用时间超过冷却时间且测试通过后自动启用
+	AutoRecoverKeyEnabled         bool `json:"auto_recover_key_enabled"`
+	AutoRecoverKeyMinutes         int  `json:"auto_recover_key_minutes"`
+	AutoRecoverKeyCooldownMinutes int  `json:"auto_recover_key_cooldown_minutes"`
+	// 定期刷新支持余额查询的渠道余额，并检查低余额阈值
+	AutoUpdateBalanceEnabled bool `json:"auto_update_balance_enabled"`
+	AutoUpdateBalanceMinutes int  `json:"auto_update_balance_minutes"`
 }
 
 // 默认配置
 var monitorSetting = MonitorSetting{
-	AutoTestChannelEnabled: false,
-	AutoTestChannelMinutes: 10,
+	AutoTestChannelEnabled:        false,
+	AutoTestChannelMinutes:        10,
+	ChannelTestMatrixEnabled:      false,
+	AutoRecoverKeyEnabled:         false,
+	AutoRecoverKeyMinutes:         10,
+	AutoRecoverKeyCooldownMinutes: 30,
+	AutoUpdateBalanceEnabled:      false,
+	AutoUpdateBalanceMinutes:      60,
 }
 
 func init() {
@@ -30,5 +45,12 @@ func GetMonitorSetting() *MonitorSetting {
 			monitorSetting.AutoTestChannelMinutes = frequency
 		}
 	}
+	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
+		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
+		if err == nil && frequency > 0 {
+			monitorSetting.AutoUpdateBalanceEnabled = true
+			monitorSetting.AutoUpdateBalanceMinutes = frequency
+		}
+	}
 	return &monitorSetting
 }
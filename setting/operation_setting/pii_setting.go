@@ -0,0 +1,92 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"regexp"
+	"slices"
+)
+
+// 内置的敏感信息类型
+const (
+	PIITypeEmail      = "email"
+	PIITypePhone      = "phone"
+	PIITypeCreditCard = "credit_card"
+	PIITypeNationalId = "national_id"
+)
+
+// PIIRule 自定义的敏感信息规则，Name 用于占位符，如 [PII_EMPLOYEE_ID_1]
+type PIIRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// PIISetting 转发前将请求中的敏感信息替换为占位符，分组、渠道或令牌任一开启即生效
+type PIISetting struct {
+	Enabled         bool      `json:"enabled"`
+	Groups          []string  `json:"groups"`
+	Types           []string  `json:"types"` // 启用的内置类型
+	CustomRules     []PIIRule `json:"custom_rules"`
+	RestoreResponse bool      `json:"restore_response"` // 在响应中将占位符还原为原始内容
+}
+
+var piiSetting = PIISetting{
+	Enabled:         false,
+	Groups:          []string{},
+	Types:           []string{PIITypeEmail, PIITypePhone, PIITypeCreditCard, PIITypeNationalId},
+	CustomRules:     []PIIRule{},
+	RestoreResponse: true,
+}
+
+// PIIPattern 敏感信息类型及其正则
+type PIIPattern struct {
+	Type    string
+	Pattern *regexp.Regexp
+}
+
+// builtinPIIPatterns 按顺序匹配，身份证号需要在银行卡号之前，避免 18 位身份证号被识别为银行卡号
+var builtinPIIPatterns = []PIIPattern{
+	{PIITypeEmail, regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{PIITypeNationalId, regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`)},
+	{PIITypeCreditCard, regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)},
+	{PIITypePhone, regexp.MustCompile(`(?:\+\d{1,3}[ -]?)?\b1[3-9]\d{9}\b|\+\d{1,3}(?:[ -]?\d{2,4}){2,4}\b`)},
+}
+
+var piiRuleCache = regexCache{name: "pii rule"}
+
+func init() {
+	config.GlobalConfig.Register("pii_setting", &piiSetting)
+}
+
+func GetPIISetting() *PIISetting {
+	return &piiSetting
+}
+
+// IsPIIScrubEnabled 全局开关打开后，分组、渠道或令牌任一开启即替换敏感信息
+func IsPIIScrubEnabled(group string, channelEnabled bool, tokenEnabled bool) bool {
+	if !piiSetting.Enabled {
+		return false
+	}
+	return channelEnabled || tokenEnabled || slices.Contains(piiSetting.Groups, group)
+}
+
+// GetPatterns 返回启用的内置规则与自定义规则，无效的自定义规则会被忽略
+func (s *PIISetting) GetPatterns() []PIIPattern {
+	sources := make([]string, len(s.CustomRules))
+	for i, rule := range s.CustomRules {
+		sources[i] = rule.Pattern
+	}
+	compiled := piiRuleCache.Compile(sources)
+	patterns := make([]PIIPattern, 0, len(builtinPIIPatterns)+len(s.CustomRules))
+	for _, builtin := range builtinPIIPatterns {
+		if slices.Contains(s.Types, builtin.Type) {
+			patterns = append(patterns, builtin)
+		}
+	}
+	for i, rule := range s.CustomRules {
+		if rule.Name == "" || rule.Pattern == "" || compiled[i] == nil {
+			continue
+		}
+		patterns = append(patterns, PIIPattern{Type: rule.Name, Pattern: compiled[i]})
+	}
+	return patterns
+}
@@ -0,0 +1,24 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// QuotaLedgerSetting 定期核对额度账本与用户、令牌的实际额度
+type QuotaLedgerSetting struct {
+	ReconcileEnabled  bool `json:"reconcile_enabled"`
+	ReconcileInterval int  `json:"reconcile_interval"` // 分钟
+	NotifyRoot        bool `json:"notify_root"`        // 发现差异时通知管理员
+}
+
+var quotaLedgerSetting = QuotaLedgerSetting{
+	ReconcileEnabled:  true,
+	ReconcileInterval: 24 * 60,
+	NotifyRoot:        true,
+}
+
+func init() {
+	config.GlobalConfig.Register("quota_ledger_setting", &quotaLedgerSetting)
+}
+
+func GetQuotaLedgerSetting() *QuotaLedgerSetting {
+	return &quotaLedgerSetting
+}
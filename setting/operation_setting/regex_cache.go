@@ -0,0 +1,52 @@
+package operation_setting
+
+import (
+	"one-api/common"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexCache 缓存按配置编译的正则，配置变化时重新编译，无效的正则会记录日志并忽略
+type regexCache struct {
+	name     string // 用于日志
+	lock     sync.Mutex
+	source   string
+	compiled []*regexp.Regexp // 与配置一一对应，无效的正则为 nil
+	valid    []*regexp.Regexp
+}
+
+func (rc *regexCache) load(patterns []string) {
+	source := strings.Join(patterns, "\n")
+	if rc.compiled != nil && source == rc.source {
+		return
+	}
+	rc.compiled = make([]*regexp.Regexp, len(patterns))
+	rc.valid = make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			common.SysLog("invalid " + rc.name + " " + pattern + ": " + err.Error())
+			continue
+		}
+		rc.compiled[i] = re
+		rc.valid = append(rc.valid, re)
+	}
+	rc.source = source
+}
+
+// Compile 返回与 patterns 一一对应的正则，无效的为 nil
+func (rc *regexCache) Compile(patterns []string) []*regexp.Regexp {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.load(patterns)
+	return rc.compiled
+}
+
+// Valid 只返回有效的正则
+func (rc *regexCache) Valid(patterns []string) []*regexp.Regexp {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.load(patterns)
+	return rc.valid
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import (
+	"one-api/setting/config"
+	"slices"
+	"time"
+)
+
+type ResponseCacheSetting struct {
+	Enabled          bool     `json:"enabled"`
+	TTLSeconds       int      `json:"ttl_seconds"`
+	HitRatio         float64  `json:"hit_ratio"`          // 命中缓存时按正常价格的该比例计费
+	Groups           []string `json:"groups"`             // 启用缓存的分组，令牌也可以单独开启
+	MaxEntryKB       int      `json:"max_entry_kb"`       // 超过该大小的响应不缓存
+	MemoryMaxEntries int      `json:"memory_max_entries"` // 未启用 Redis 时内存缓存的最大条数
+}
+
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:          false,
+	TTLSeconds:       3600,
+	HitRatio:         0.1,
+	Groups:           []string{},
+	MaxEntryKB:       256,
+	MemoryMaxEntries: 1000,
+}
+
+func init() {
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// IsResponseCacheEnabled 全局开关打开后，分组或令牌任一开启即可使用缓存
+func IsResponseCacheEnabled(group string, tokenEnabled bool) bool {
+	if !responseCacheSetting.Enabled {
+		return false
+	}
+	return tokenEnabled || slices.Contains(responseCacheSetting.Groups, group)
+}
+
+func GetResponseCacheTTL() time.Duration {
+	if responseCacheSetting.TTLSeconds <= 0 {
+		return time.Hour
+	}
+	return time.Duration(responseCacheSetting.TTLSeconds) * time.Second
+}
@@ -0,0 +1,29 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// TokenRateLimit 0 表示不限制
+type TokenRateLimit struct {
+	TPM         int `json:"tpm"`         // 每分钟 token 数，按请求预估值预扣，结算后按实际用量对账
+	Concurrency int `json:"concurrency"` // 同时进行中的请求数
+}
+
+type TokenRateLimitSetting struct {
+	Enabled bool                      `json:"enabled"`
+	User    TokenRateLimit            `json:"user"`   // 每个用户
+	Token   TokenRateLimit            `json:"token"`  // 每个令牌
+	Groups  map[string]TokenRateLimit `json:"groups"` // 分组内所有用户合计
+}
+
+var tokenRateLimitSetting = TokenRateLimitSetting{
+	Enabled: false,
+	Groups:  map[string]TokenRateLimit{},
+}
+
+func init() {
+	config.GlobalConfig.Register("token_rate_limit_setting", &tokenRateLimitSetting)
+}
+
+func GetTokenRateLimitSetting() *TokenRateLimitSetting {
+	return &tokenRateLimitSetting
+}
@@ -0,0 +1,58 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"sync"
+)
+
+// defaultFineTuneTrainingPrice 微调训练价格，单位：美元 / 1M trained tokens
+var defaultFineTuneTrainingPrice = map[string]float64{
+	"gpt-4.1-2025-04-14":      25,
+	"gpt-4.1-mini-2025-04-14": 5,
+	"gpt-4.1-nano-2025-04-14": 1.5,
+	"gpt-4o-2024-08-06":       25,
+	"gpt-4o-mini-2024-07-18":  3,
+	"gpt-3.5-turbo-0125":      8,
+	"gpt-3.5-turbo-1106":      8,
+	"davinci-002":             6,
+	"babbage-002":             0.4,
+}
+
+var fineTuneTrainingPriceMap map[string]float64
+var fineTuneTrainingPriceMapMutex sync.RWMutex
+
+func init() {
+	fineTuneTrainingPriceMap = make(map[string]float64, len(defaultFineTuneTrainingPrice))
+	for k, v := range defaultFineTuneTrainingPrice {
+		fineTuneTrainingPriceMap[k] = v
+	}
+}
+
+func FineTuneTrainingPrice2JSONString() string {
+	fineTuneTrainingPriceMapMutex.RLock()
+	defer fineTuneTrainingPriceMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(fineTuneTrainingPriceMap)
+	if err != nil {
+		common.SysLog("error marshalling fine tune training price: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFineTuneTrainingPriceByJSONString(jsonStr string) error {
+	fineTuneTrainingPriceMapMutex.Lock()
+	defer fineTuneTrainingPriceMapMutex.Unlock()
+	fineTuneTrainingPriceMap = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &fineTuneTrainingPriceMap)
+}
+
+// GetFineTuneTrainingPrice 返回基础模型每 1M 训练 token 的价格
+func GetFineTuneTrainingPrice(name string) (float64, bool) {
+	fineTuneTrainingPriceMapMutex.RLock()
+	defer fineTuneTrainingPriceMapMutex.RUnlock()
+	price, ok := fineTuneTrainingPriceMap[name]
+	if !ok {
+		price, ok = fineTuneTrainingPriceMap[FormatMatchingModelName(name)]
+	}
+	return price, ok
+}
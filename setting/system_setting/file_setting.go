@@ -0,0 +1,25 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type FileSetting struct {
+	StorageType        string `json:"storage_type"`         // 存储后端，目前支持 local
+	LocalPath          string `json:"local_path"`           // 本地存储目录
+	MaxFileSizeMB      int    `json:"max_file_size_mb"`     // 单个文件大小上限（MB）
+	PassThroughEnabled bool   `json:"pass_through_enabled"` // 上传时同时转发至上游渠道
+}
+
+var defaultFileSetting = FileSetting{
+	StorageType:        "local",
+	LocalPath:          "./data/files",
+	MaxFileSizeMB:      512,
+	PassThroughEnabled: false,
+}
+
+func init() {
+	config.GlobalConfig.Register("file_setting", &defaultFileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &defaultFileSetting
+}